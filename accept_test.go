@@ -0,0 +1,102 @@
+package Go_rpc
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tempError 是 Temporary 为 true 的 net.Error
+type tempError struct{}
+
+func (tempError) Error() string   { return "temporary accept error" }
+func (tempError) Timeout() bool   { return false }
+func (tempError) Temporary() bool { return true }
+
+// fakeListener 第 i 次 Accept 返回 next(i) 的结果，并记录每次调用的时间
+type fakeListener struct {
+	next func(i int) (net.Conn, error)
+
+	mu    sync.Mutex
+	calls []time.Time
+}
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	i := len(l.calls)
+	l.calls = append(l.calls, time.Now())
+	l.mu.Unlock()
+	return l.next(i)
+}
+
+func (l *fakeListener) Close() error   { return nil }
+func (l *fakeListener) Addr() net.Addr { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+
+// serveFake 在 l 上运行 Accept 直到其返回
+func serveFake(t *testing.T, server *Server, l *fakeListener) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		server.Accept(l)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expect Accept to return after a permanent error")
+	}
+}
+
+func TestAcceptTemporaryErrorBackoff(t *testing.T) {
+	server := NewServer()
+	server.SetLogger(&captureLogger{})
+	const temporary = 5
+	l := &fakeListener{next: func(i int) (net.Conn, error) {
+		if i < temporary {
+			return nil, tempError{}
+		}
+		return nil, errors.New("listener closed")
+	}}
+	serveFake(t, server, l)
+
+	if len(l.calls) != temporary+1 {
+		t.Fatalf("expect Accept to retry temporary errors and stop at the permanent one, got %d calls", len(l.calls))
+	}
+	// 每次重试前的等待时间加倍，而不是立即重试
+	var last time.Duration
+	for i := 1; i < len(l.calls); i++ {
+		gap := l.calls[i].Sub(l.calls[i-1])
+		if gap < 5*time.Millisecond || gap < last {
+			t.Fatalf("expect an increasing backoff between accepts, got %v after %v", gap, last)
+		}
+		last = gap
+	}
+}
+
+func TestAcceptRateLimit(t *testing.T) {
+	server := NewServer()
+	server.SetLogger(&captureLogger{})
+	server.SetMaxAcceptsPerSecond(50)
+	const conns = 5
+	var clients []net.Conn
+	l := &fakeListener{next: func(i int) (net.Conn, error) {
+		if i == conns {
+			return nil, errors.New("listener closed")
+		}
+		serverConn, clientConn := net.Pipe()
+		clients = append(clients, clientConn)
+		return serverConn, nil
+	}}
+	serveFake(t, server, l)
+	for _, c := range clients {
+		_ = c.Close()
+	}
+
+	for i := 1; i < len(l.calls); i++ {
+		if gap := l.calls[i].Sub(l.calls[i-1]); gap < 15*time.Millisecond {
+			t.Fatalf("expect at most 50 accepts per second, got %v between accepts", gap)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package Go_rpc
+
+import "context"
+
+type tokenKey struct{}
+
+// contextWithToken 返回携带握手时客户端令牌的 ctx
+func contextWithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// TokenFromContext 返回握手时客户端在 Option.Token 中发送的令牌，
+// 可在 Authorizer、拦截器等处理请求的代码中使用
+func TokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey{}).(string)
+	return token
+}
+
+// Authorizer 在调用方法之前检查是否允许调用 serviceMethod，返回的错误作为响应返回给客户端
+type Authorizer func(ctx context.Context, serviceMethod string) error
+
+// SetAuthorizer 设置方法级别的访问控制，f 为 nil 表示不检查。
+// f 在拦截器之前调用，ctx 中可以通过 TokenFromContext 取得连接的令牌
+func (server *Server) SetAuthorizer(f Authorizer) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.authorizer = f
+}
+
+// authorize 使用 Authorizer 检查请求，没有设置时总是允许
+func (server *Server) authorize(ctx context.Context, serviceMethod string) error {
+	server.mu.Lock()
+	f := server.authorizer
+	server.mu.Unlock()
+	if f == nil {
+		return nil
+	}
+	return f(ctx, serviceMethod)
+}
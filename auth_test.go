@@ -0,0 +1,66 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerAuthFunc(t *testing.T) {
+	server, addr := startServer(t)
+	server.SetAuthFunc(func(token string) bool { return token == "secret" })
+
+	client := dialServer(t, addr, &Option{Token: "secret"})
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect an accepted token to be served, got %d, %v", reply, err)
+	}
+
+	// 令牌错误时客户端立即得到明确的错误，而不是一直等待
+	start := time.Now()
+	rejected, err := Dial("tcp", addr, &Option{Token: "wrong", ConnectTimeout: time.Second})
+	if err == nil {
+		defer func() { _ = rejected.Close() }()
+		err = rejected.Call(context.Background(), "Foo.Sum", Args{}, &reply)
+	}
+	var he *HandshakeError
+	if !errors.As(err, &he) || !strings.Contains(he.Msg, "authentication failed") {
+		t.Fatalf("expect an authentication error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expect the rejected client to fail fast, took %v", elapsed)
+	}
+}
+
+func TestServerAuthorizer(t *testing.T) {
+	server, addr := startServer(t)
+	if err := server.RegisterName("Arith", new(Foo)); err != nil {
+		t.Fatal(err)
+	}
+	server.SetAuthorizer(func(ctx context.Context, serviceMethod string) error {
+		if TokenFromContext(ctx) == "alice" && serviceMethod == "Arith.Div" {
+			return errors.New("permission denied")
+		}
+		return nil
+	})
+
+	alice := dialServer(t, addr, &Option{Token: "alice"})
+	var reply int
+	if err := alice.Call(context.Background(), "Arith.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect alice to call Arith.Sum, got %d, %v", reply, err)
+	}
+	reply = 0
+	if err := alice.Call(context.Background(), "Arith.Div", Args{Num1: 4, Num2: 2}, &reply); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expect alice to be denied Arith.Div, got %v", err)
+	}
+	if reply != 0 {
+		t.Fatalf("expect the denied method not to be called, got %d", reply)
+	}
+
+	bob := dialServer(t, addr, &Option{Token: "bob"})
+	if err := bob.Call(context.Background(), "Arith.Div", Args{Num1: 4, Num2: 2}, &reply); err != nil || reply != 2 {
+		t.Fatalf("expect bob to call Arith.Div, got %d, %v", reply, err)
+	}
+}
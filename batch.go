@@ -0,0 +1,59 @@
+package Go_rpc
+
+import "context"
+
+// BatchCall 是 CallBatch 中的一次调用，调用结束后 Error 保存结果
+type BatchCall struct {
+	ServiceMethod string
+	Args          interface{}
+	Reply         interface{}
+	Error         error
+}
+
+// CallBatch 连续发送 calls 中的所有请求而不等待响应，再按序列号收集所有响应，
+// 使多个请求只需等待一次往返。每个请求完整写入后才会写入下一个，可以与其他调用并发使用。
+// ctx 的截止时间、链路追踪标识和元数据随每个请求发送，Option.CallTimeout 与 Go 相同地对每个请求生效；
+// ctx 结束时尚未完成的调用以 ctx.Err() 结束，已经在读取响应的调用仍等待其完成。
+// 批量调用不经过客户端拦截器。返回第一个失败的调用的错误，各个调用的错误保存在 BatchCall.Error 中
+func (client *Client) CallBatch(ctx context.Context, calls []BatchCall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	done := make(chan *Call, len(calls)) // 容量足够所有调用，receive 不会阻塞
+	index := make(map[*Call]int, len(calls))
+	for i := range calls {
+		call, _ := client.start(ctx, calls[i].ServiceMethod, calls[i].Args, calls[i].Reply, done)
+		index[call] = i
+	}
+	for len(index) > 0 {
+		select {
+		case <-ctx.Done():
+			for call, i := range index {
+				// 只结束仍在 pending 中的调用；已被取出的调用马上会写入 done，
+				// 不等待它们的话返回后 receive 可能仍在写入 Reply
+				if client.cancelCall(call) {
+					calls[i].Error = ctx.Err()
+					delete(index, call)
+				}
+			}
+			for len(index) > 0 {
+				call := <-done
+				calls[index[call]].Error = call.Error
+				delete(index, call)
+			}
+		case call := <-done:
+			calls[index[call]].Error = call.Error
+			delete(index, call)
+		}
+	}
+	return firstBatchError(calls)
+}
+
+func firstBatchError(calls []BatchCall) error {
+	for i := range calls {
+		if calls[i].Error != nil {
+			return calls[i].Error
+		}
+	}
+	return nil
+}
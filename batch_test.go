@@ -0,0 +1,172 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCallBatch(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr)
+
+	// 批量调用与并发的单次调用交错写入同一连接
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var reply int
+			if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: i, Num2: i}, &reply); err != nil || reply != 2*i {
+				t.Errorf("expect concurrent call to return %d, got %d, %v", 2*i, reply, err)
+			}
+		}(i)
+	}
+	calls := make([]BatchCall, 50)
+	for i := range calls {
+		calls[i] = BatchCall{ServiceMethod: "Foo.Sum", Args: Args{Num1: i, Num2: 1}, Reply: new(int)}
+	}
+	if err := client.CallBatch(context.Background(), calls); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	for i, call := range calls {
+		if call.Error != nil || *call.Reply.(*int) != i+1 {
+			t.Fatalf("call %d: expect %d, got %d, %v", i, i+1, *call.Reply.(*int), call.Error)
+		}
+	}
+}
+
+func TestCallBatchError(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr)
+
+	calls := []BatchCall{
+		{ServiceMethod: "Foo.Div", Args: Args{Num1: 6, Num2: 3}, Reply: new(int)},
+		{ServiceMethod: "Foo.Div", Args: Args{Num1: 1}, Reply: new(int)},
+	}
+	err := client.CallBatch(context.Background(), calls)
+	if err == nil || calls[1].Error == nil || err.Error() != calls[1].Error.Error() {
+		t.Fatalf("expect the error of the failed call, got %v", err)
+	}
+	if calls[0].Error != nil || *calls[0].Reply.(*int) != 2 {
+		t.Fatalf("expect the first call to succeed, got %v", calls[0].Error)
+	}
+}
+
+func TestCallBatchContextCancel(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	calls := []BatchCall{
+		{ServiceMethod: "Foo.Sum", Args: Args{Num1: 1, Num2: 2}, Reply: new(int)},
+		{ServiceMethod: "Foo.Sleep", Args: Args{Num1: 1000}, Reply: new(int)},
+	}
+	err := client.CallBatch(ctx, calls)
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(calls[1].Error, context.DeadlineExceeded) {
+		t.Fatalf("expect the slow call to end with the ctx error, got %v, %v", err, calls[1].Error)
+	}
+	// 已完成的调用保留自己的结果
+	if calls[0].Error != nil || *calls[0].Reply.(*int) != 3 {
+		t.Fatalf("expect the completed call to keep its result, got %d, %v", *calls[0].Reply.(*int), calls[0].Error)
+	}
+}
+
+func TestCallBatchCallTimeout(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr, &Option{CallTimeout: 50 * time.Millisecond})
+
+	calls := []BatchCall{
+		{ServiceMethod: "Foo.Sum", Args: Args{Num1: 1, Num2: 2}, Reply: new(int)},
+		{ServiceMethod: "Foo.Sleep", Args: Args{Num1: 1000}, Reply: new(int)},
+	}
+	start := time.Now()
+	if err := client.CallBatch(context.Background(), calls); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expect ErrTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expect CallTimeout to end the batch, took %v", elapsed)
+	}
+	if calls[0].Error != nil {
+		t.Fatalf("expect the fast call to succeed, got %v", calls[0].Error)
+	}
+}
+
+// delayConn 将每次写入的数据延迟 delay 后再发出，写入本身不阻塞，模拟高延迟的链路
+type delayConn struct {
+	net.Conn
+	delay time.Duration
+	queue chan delayedWrite
+}
+
+type delayedWrite struct {
+	data []byte
+	at   time.Time
+}
+
+func newDelayConn(conn net.Conn, delay time.Duration) *delayConn {
+	c := &delayConn{Conn: conn, delay: delay, queue: make(chan delayedWrite, 1024)}
+	go func() {
+		for w := range c.queue {
+			time.Sleep(time.Until(w.at))
+			if _, err := c.Conn.Write(w.data); err != nil {
+				return
+			}
+		}
+	}()
+	return c
+}
+
+func (c *delayConn) Write(p []byte) (int, error) {
+	c.queue <- delayedWrite{data: append([]byte(nil), p...), at: time.Now().Add(c.delay)}
+	return len(p), nil
+}
+
+func dialDelayed(b *testing.B, addr string, delay time.Duration) *Client {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	client, err := NewClient(newDelayConn(conn, delay), DefaultOption)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+const benchBatchSize = 20
+
+func BenchmarkCallSequentialHighLatency(b *testing.B) {
+	_, addr := startServer(b)
+	client := dialDelayed(b, addr, 2*time.Millisecond)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchBatchSize; j++ {
+			var reply int
+			if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: j}, &reply); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkCallBatchHighLatency(b *testing.B) {
+	_, addr := startServer(b)
+	client := dialDelayed(b, addr, 2*time.Millisecond)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		calls := make([]BatchCall, benchBatchSize)
+		for j := range calls {
+			calls[j] = BatchCall{ServiceMethod: "Foo.Sum", Args: Args{Num1: j}, Reply: new(int)}
+		}
+		if err := client.CallBatch(context.Background(), calls); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package Go_rpc
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// ClientCacheConfig 是客户端调用结果缓存的配置
+type ClientCacheConfig struct {
+	TTL        time.Duration // 结果的有效期，小于等于 0 时结果不会过期，只按 LRU 淘汰
+	MaxEntries int           // 最多缓存的结果数，超出时淘汰最久未使用的结果；小于等于 0 时不限制
+}
+
+// CallCache 在客户端缓存幂等方法的调用结果，以 (serviceMethod, 序列化后的参数) 为键，
+// 有效期内相同的调用直接返回缓存的结果，不经过网络。
+// 只缓存通过 Cacheable 声明的方法的成功调用，通过 Use 安装：
+//
+//	cache := NewCallCache(ClientCacheConfig{TTL: time.Minute, MaxEntries: 1000})
+//	cache.Cacheable("Foo.Get")
+//	client.Use(cache.Intercept)
+type CallCache struct {
+	cfg ClientCacheConfig
+
+	mu        sync.Mutex // 保护以下字段
+	cacheable map[string]bool
+	lru       *list.List // 元素为 *cacheEntry，最近使用的在前
+	entries   map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	reply   []byte // gob 编码的结果，命中时解码到调用方的 reply，调用方修改 reply 不影响缓存
+	expires time.Time
+}
+
+// NewCallCache 创建调用结果缓存
+func NewCallCache(cfg ClientCacheConfig) *CallCache {
+	return &CallCache{
+		cfg:       cfg,
+		cacheable: make(map[string]bool),
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+	}
+}
+
+// Cacheable 将 serviceMethods 声明为可以缓存结果的方法，这些方法必须是幂等的
+func (c *CallCache) Cacheable(serviceMethods ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, sm := range serviceMethods {
+		c.cacheable[sm] = true
+	}
+}
+
+// Intercept 是客户端拦截器，命中缓存时直接返回，否则调用 next 并缓存成功的结果。
+// 参数或结果无法序列化时不缓存
+func (c *CallCache) Intercept(ctx context.Context, serviceMethod string, args, reply interface{}, next Invoker) error {
+	c.mu.Lock()
+	cacheable := c.cacheable[serviceMethod]
+	c.mu.Unlock()
+	if !cacheable {
+		return next(ctx, serviceMethod, args, reply)
+	}
+	key, err := cacheKey(serviceMethod, args)
+	if err != nil {
+		return next(ctx, serviceMethod, args, reply)
+	}
+	if data, ok := c.get(key); ok && gob.NewDecoder(bytes.NewReader(data)).Decode(reply) == nil {
+		return nil
+	}
+	if err := next(ctx, serviceMethod, args, reply); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(reply); err == nil {
+		c.add(key, buf.Bytes())
+	}
+	return nil
+}
+
+// Purge 清空缓存的所有结果
+func (c *CallCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+// Len 返回缓存的结果数，包括已过期但还没有被清理的结果
+func (c *CallCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *CallCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.reply, true
+}
+
+func (c *CallCache) add(key string, reply []byte) {
+	var expires time.Time
+	if c.cfg.TTL > 0 {
+		expires = time.Now().Add(c.cfg.TTL)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.reply, entry.expires = reply, expires
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, reply: reply, expires: expires})
+	for c.cfg.MaxEntries > 0 && c.lru.Len() > c.cfg.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove 删除 elem，调用时必须持有 mu
+func (c *CallCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// cacheKey 返回调用的缓存键。参数用 JSON 序列化，map 的键有序，相同的参数总是得到相同的键
+func cacheKey(serviceMethod string, args interface{}) (string, error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	return serviceMethod + "\x00" + string(data), nil
+}
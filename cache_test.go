@@ -0,0 +1,58 @@
+package Go_rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCallCache(t *testing.T) {
+	events := new(Events)
+	_, addr := startServer(t, events)
+	client := dialServer(t, addr)
+	cache := NewCallCache(ClientCacheConfig{TTL: 50 * time.Millisecond})
+	client.Use(cache.Intercept)
+	record := func(n int) int {
+		t.Helper()
+		var reply int
+		if err := client.Call(context.Background(), "Events.Record", Args{Num1: n}, &reply); err != nil || reply != n {
+			t.Fatalf("expect %d, got %d, %v", n, reply, err)
+		}
+		return int(events.n.Load())
+	}
+
+	// 没有声明为可缓存的方法每次都调用服务端
+	record(1)
+	if calls := record(1); calls != 2 {
+		t.Fatalf("expect an uncacheable method to reach the server, got %d calls", calls)
+	}
+	cache.Cacheable("Events.Record")
+	record(1)
+	if calls := record(1); calls != 3 {
+		t.Fatalf("expect the second identical call to be served from the cache, got %d calls", calls)
+	}
+	if calls := record(2); calls != 4 {
+		t.Fatalf("expect different args to miss the cache, got %d calls", calls)
+	}
+	time.Sleep(80 * time.Millisecond)
+	if calls := record(1); calls != 5 {
+		t.Fatalf("expect an expired result to be fetched again, got %d calls", calls)
+	}
+}
+
+func TestCallCacheLRU(t *testing.T) {
+	events := new(Events)
+	_, addr := startServer(t, events)
+	client := dialServer(t, addr)
+	cache := NewCallCache(ClientCacheConfig{MaxEntries: 2})
+	cache.Cacheable("Events.Record")
+	client.Use(cache.Intercept)
+	for _, n := range []int{1, 2, 1, 3, 1, 2} { // 3 淘汰最久未使用的 2，1 仍在缓存中
+		if err := client.Call(context.Background(), "Events.Record", Args{Num1: n}, new(int)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := events.n.Load(); calls != 4 {
+		t.Fatalf("expect 1, 2, 3 and the evicted 2 to reach the server, got %d calls", calls)
+	}
+}
@@ -0,0 +1,117 @@
+package Go_rpc
+
+import (
+	"context"
+	"io"
+)
+
+const (
+	defaultChunkSize  = 64 << 10 // ReplyWriter 默认的块大小
+	replyChunkBacklog = 4        // CallToWriter 最多缓存的未写入块数
+)
+
+// ReplyWriter 将写入的数据分块作为流式方法的消息发送，用于返回不适合整体编码的大响应，
+// 每个块是一个 []byte 消息，客户端用 CallToWriter 接收。在流式方法中使用：
+//
+//	func (t *T) Download(name string, stream ServerStream) error {
+//		w := NewReplyWriter(stream, 0)
+//		if _, err := io.Copy(w, file); err != nil {
+//			return err
+//		}
+//		return w.Flush()
+//	}
+type ReplyWriter struct {
+	stream ServerStream
+	buf    []byte // 尚未发送的数据，长度达到块大小时发送
+}
+
+var (
+	_ io.Writer     = (*ReplyWriter)(nil)
+	_ io.ReaderFrom = (*ReplyWriter)(nil)
+)
+
+// NewReplyWriter 创建向 stream 发送数据块的 ReplyWriter，chunkSize <= 0 时使用默认的 64KB。
+// 启用了 Option.MaxBodySize 时块大小不应超过该限制
+func NewReplyWriter(stream ServerStream, chunkSize int) *ReplyWriter {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	return &ReplyWriter{stream: stream, buf: make([]byte, 0, chunkSize)}
+}
+
+// Write 缓存 p，每凑满一个块就发送一次。返回前 p 已被复制，调用方可以立即重用
+func (w *ReplyWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		k := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+k]
+		n += k
+		p = p[k:]
+		if len(w.buf) == cap(w.buf) {
+			if err := w.Flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// ReadFrom 从 r 读取数据直接填充到块中发送，直到 r 返回 io.EOF，最后不足一块的数据留到 Flush 发送
+func (w *ReplyWriter) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	for {
+		n, err := r.Read(w.buf[len(w.buf):cap(w.buf)])
+		w.buf = w.buf[:len(w.buf)+n]
+		total += int64(n)
+		if len(w.buf) == cap(w.buf) {
+			if ferr := w.Flush(); ferr != nil {
+				return total, ferr
+			}
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// Flush 发送缓存中剩余的数据，流式方法返回前必须调用
+func (w *ReplyWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	// 编解码器在 Send 返回前已完成编码，缓存可以立即重用
+	err := w.stream.Send(w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
+
+// CallToWriter 调用通过 ReplyWriter 返回数据块的流式方法，将收到的数据依次写入 w，返回写入的字节数。
+// 最多缓存少量未写入的块，w 写入较慢时暂停读取连接，使内存占用与响应的总大小无关，
+// 但期间同一连接上的其他调用也会等待。w 返回错误时放弃剩余的数据并返回该错误
+func (client *Client) CallToWriter(ctx context.Context, serviceMethod string, args interface{}, w io.Writer) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // 提前返回时使 receive 不再等待，剩余的块被丢弃
+	stream, err := client.newStream(ctx, serviceMethod, args, new([]byte), replyChunkBacklog)
+	if err != nil {
+		return 0, err
+	}
+	var written int64
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+		n, err := w.Write(*msg.(*[]byte))
+		written += int64(n)
+		if err != nil {
+			client.removeCall(stream.call.Seq) // 之后到达的块直接丢弃
+			return written, err
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package Go_rpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pattern 是内容可以校验的数据源，第 i 个字节为 i % 251
+type pattern struct {
+	off, size int64
+}
+
+func (p *pattern) Read(b []byte) (int, error) {
+	if p.off >= p.size {
+		return 0, io.EOF
+	}
+	if rest := p.size - p.off; int64(len(b)) > rest {
+		b = b[:rest]
+	}
+	for i := range b {
+		b[i] = byte((p.off + int64(i)) % 251)
+	}
+	p.off += int64(len(b))
+	return len(b), nil
+}
+
+// Blob 通过 ReplyWriter 返回 size 字节的 pattern 数据
+type Blob struct{}
+
+func (Blob) Download(size int64, stream ServerStream) error {
+	w := NewReplyWriter(stream, 0)
+	if _, err := w.ReadFrom(&pattern{size: size}); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// checkWriter 校验写入的数据与 pattern 一致
+type checkWriter struct {
+	off int64
+	err error
+}
+
+func (w *checkWriter) Write(b []byte) (int, error) {
+	for i, c := range b {
+		if want := byte((w.off + int64(i)) % 251); c != want && w.err == nil {
+			w.err = fmt.Errorf("byte %d: expect %d, got %d", w.off+int64(i), want, c)
+		}
+	}
+	w.off += int64(len(b))
+	return len(b), nil
+}
+
+func TestCallToWriter(t *testing.T) {
+	_, addr := startServer(t, Blob{})
+	client := dialServer(t, addr)
+	const size = 10 << 20
+
+	// 频繁回收垃圾，使堆的大小接近实际持有的内存
+	defer debug.SetGCPercent(debug.SetGCPercent(10))
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	base := ms.HeapAlloc
+	var peak atomic.Uint64
+	stop := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		var ms runtime.MemStats
+		for {
+			runtime.ReadMemStats(&ms)
+			if ms.HeapAlloc > peak.Load() {
+				peak.Store(ms.HeapAlloc)
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+
+	w := new(checkWriter)
+	n, err := client.CallToWriter(context.Background(), "Blob.Download", int64(size), w)
+	close(stop)
+	<-sampled
+	if err != nil || n != size || w.off != size || w.err != nil {
+		t.Fatalf("expect %d bytes of the pattern, got %d (%d written), %v, %v", size, n, w.off, err, w.err)
+	}
+	if p := peak.Load(); p > base && p-base > size/4 {
+		t.Fatalf("expect the heap to stay well below the reply size, grew by %d bytes", p-base)
+	}
+}
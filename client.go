@@ -0,0 +1,586 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Call 表示一次活跃的 RPC 调用
+type Call struct {
+	Seq           uint64      // 请求序列号
+	ServiceMethod string      // format "Service.Method"
+	Args          interface{} // 方法参数
+	Reply         interface{} // 方法返回值
+	Error         error       // 调用完成后设置的错误
+	Done          chan *Call  // 调用完成时写入自身
+
+	stream   *ClientStream     // 流式调用接收消息的流，普通调用为 nil
+	deadline int64             // 随请求发送的截止时间（Unix 纳秒），0 表示没有截止时间
+	trace    traceInfo         // 随请求发送的链路追踪标识
+	meta     map[string]string // 随请求发送的元数据
+	timer    *time.Timer       // Option.CallTimeout 的定时器，调用结束时停止，由 Client.mu 保护
+}
+
+// done 通知调用方调用已结束
+func (call *Call) done() {
+	call.Done <- call
+}
+
+// Client 表示一个 RPC 客户端。
+// 一个客户端可以关联多个未完成的调用，也可以被多个 goroutine 同时使用
+type Client struct {
+	cc       codec.Codec
+	conn     *clientConn // 底层连接，用于取得服务端返回的握手错误
+	opt      *Option
+	sending  sync.Mutex   // 保证请求完整发送，和服务端的 sending 作用相同
+	header   codec.Header // 每个请求的请求头，只在发送时使用，由 sending 保护
+	mu       sync.Mutex   // 保护以下字段
+	seq      uint64
+	pending  map[uint64]*Call // 未完成的调用
+	closing  bool             // 用户调用了 Close
+	shutdown bool             // 连接出错后置为 true
+
+	interceptors []ClientInterceptor // 客户端拦截器，由 mu 保护
+	keepAlive    bool                // 是否已启动心跳，由 mu 保护
+}
+
+// ErrShutdown 表示客户端连接已关闭
+var ErrShutdown = errors.New("connection is shut down")
+
+// 调用失败的层次，客户端返回的错误可以用 errors.Is 判断属于哪一层，错误信息不受影响
+var (
+	ErrCodec     = errors.New("rpc client: codec error")     // 编码请求或解码响应失败
+	ErrTransport = errors.New("rpc client: transport error") // 建立连接或读写连接失败
+	ErrServer    = errors.New("rpc client: server error")    // 服务端返回的错误，包括 ServerError 和 *RPCError
+)
+
+// ErrConnectionLost 表示连接在调用完成前断开，未完成的调用返回包装了它和底层读取错误的错误
+var ErrConnectionLost = errors.New("rpc client: connection lost")
+
+// ErrTimeout 表示调用超过了 Option.CallTimeout，errors.Is(ErrTimeout, context.DeadlineExceeded) 为 true
+var ErrTimeout = fmt.Errorf("rpc client: call timeout: %w", context.DeadlineExceeded)
+
+// ServerError 表示服务端在响应中返回的错误，与连接错误不同，重试通常不会改变结果
+type ServerError string
+
+func (e ServerError) Error() string {
+	return string(e)
+}
+
+func (e ServerError) Is(target error) bool {
+	return target == ErrServer
+}
+
+var _ io.Closer = (*Client)(nil)
+
+// Close 关闭连接，未完成的调用以 ErrShutdown 结束，之后的调用立即返回 ErrShutdown
+func (client *Client) Close() error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.closing {
+		return ErrShutdown
+	}
+	client.closing = true
+	return client.cc.Close()
+}
+
+// IsAvailable 返回客户端是否可以继续使用
+func (client *Client) IsAvailable() bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return !client.shutdown && !client.closing
+}
+
+// SeqGenerator 为客户端的请求分配序列号，NextSeq 在持有客户端的锁时调用，同一客户端不会并发调用。
+// 返回的序列号不能为 0，也不能与未完成的调用重复，否则该调用失败
+type SeqGenerator interface {
+	NextSeq() uint64
+}
+
+// SeqGeneratorFunc 将函数适配为 SeqGenerator
+type SeqGeneratorFunc func() uint64
+
+func (f SeqGeneratorFunc) NextSeq() uint64 { return f() }
+
+// registerCall 将调用加入 pending 并分配序列号
+func (client *Client) registerCall(call *Call) (uint64, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.closing || client.shutdown {
+		return 0, ErrShutdown
+	}
+	if gen := client.opt.SeqGenerator; gen != nil {
+		seq := gen.NextSeq()
+		if _, dup := client.pending[seq]; dup || seq == 0 {
+			return 0, fmt.Errorf("rpc client: invalid seq %d from SeqGenerator", seq)
+		}
+		call.Seq = seq
+	} else {
+		call.Seq = client.seq
+		client.seq++
+	}
+	client.pending[call.Seq] = call
+	return call.Seq, nil
+}
+
+// removeCall 从 pending 中移除并返回对应的调用，同时停止调用的超时定时器
+func (client *Client) removeCall(seq uint64) *Call {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	call := client.pending[seq]
+	if call != nil {
+		client.dropCall(call)
+	}
+	return call
+}
+
+// dropCall 从 pending 中移除 call 并停止其超时定时器，调用时必须持有 mu
+func (client *Client) dropCall(call *Call) {
+	delete(client.pending, call.Seq)
+	if call.timer != nil {
+		call.timer.Stop()
+	}
+}
+
+// terminateCalls 在连接出错时结束所有未完成的调用
+func (client *Client) terminateCalls(err error) {
+	client.sending.Lock()
+	defer client.sending.Unlock()
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.shutdown = true
+	for _, call := range client.pending {
+		client.dropCall(call) // 移除后超时定时器和迟到的取消都不会再结束该调用
+		call.Error = err
+		call.done()
+	}
+}
+
+// cancelCall 在 call 仍未完成时将其从 pending 中移除并返回 true。
+// 比较调用本身而不是序列号，SeqGenerator 可能在调用结束后复用序列号
+func (client *Client) cancelCall(call *Call) bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.pending[call.Seq] != call {
+		return false
+	}
+	client.dropCall(call)
+	return true
+}
+
+// timeoutCall 以 ErrTimeout 结束 call，call 已经结束时什么也不做
+func (client *Client) timeoutCall(call *Call) {
+	if client.cancelCall(call) {
+		call.Error = ErrTimeout
+		call.done()
+	}
+}
+
+// receive 在后台循环读取响应，并交给对应的调用
+func (client *Client) receive() {
+	var err error
+	for err == nil {
+		var h codec.Header
+		if err = client.cc.ReadHeader(&h); err != nil {
+			break
+		}
+		if stream := client.streamOf(&h); stream != nil {
+			stream.receive(client.cc)
+			continue
+		}
+		call := client.removeCall(h.Seq)
+		switch {
+		case call == nil:
+			// 调用已被取消移除，或服务端返回了未知的序列号，丢弃响应体而不交给其他调用
+			getLogger().Debugf("rpc client: drop response %s with unknown seq %d", h.ServiceMethod, h.Seq)
+			err = client.cc.Discard()
+		case h.ServiceMethod == pongMethod:
+			// 心跳响应没有有效的响应体
+			err = client.cc.Discard()
+			call.done()
+		case h.Error != "":
+			call.Error = errorFromHeader(&h)
+			err = client.cc.Discard()
+			call.done()
+		case h.StreamEnd:
+			// 流式调用的结束标记没有有效的响应体
+			err = client.cc.Discard()
+			call.done()
+		default:
+			// 编解码器在解码失败时已消费掉整个消息体，只需结束本次调用
+			if rerr := client.cc.ReadBody(call.Reply); rerr != nil {
+				call.Error = withLayer(errors.New("reading body "+rerr.Error()), layerOf(rerr))
+			}
+			call.done()
+		}
+	}
+	// 包装读取错误，使调用方可以用 errors.Is(err, ErrConnectionLost) 区分连接断开和服务端返回的错误
+	err = fmt.Errorf("%w: %w", ErrConnectionLost, withLayer(err, layerOf(err)))
+	if client.conn != nil && client.conn.err != nil {
+		err = client.conn.err // 服务端拒绝了握手，使用它返回的原因
+	}
+	client.mu.Lock()
+	if client.closing {
+		err = ErrShutdown // 用户主动关闭，而不是连接出错
+	}
+	client.mu.Unlock()
+	client.terminateCalls(err)
+}
+
+// send 注册调用并发送请求，返回请求是否已完整写入连接
+func (client *Client) send(call *Call) (written bool) {
+	client.sending.Lock()
+	defer client.sending.Unlock()
+
+	seq, err := client.registerCall(call)
+	if err != nil {
+		call.Error = err
+		call.done()
+		return false
+	}
+
+	client.header.ServiceMethod = call.ServiceMethod
+	client.header.Seq = seq
+	client.header.Error = ""
+	client.header.Deadline = call.deadline
+	client.header.TraceID, client.header.SpanID = call.trace.traceID, call.trace.spanID
+	client.header.OneWay = false
+	client.header.Meta = call.meta
+	client.header.Nonce, client.header.Timestamp = "", 0
+
+	if err := client.cc.Write(&client.header, call.Args); err != nil {
+		// 请求可能只写入了一部分，编解码器已关闭连接，之后的调用立即返回 ErrShutdown
+		client.mu.Lock()
+		client.shutdown = true
+		client.mu.Unlock()
+		// 写入失败时调用可能已被 receive 结束
+		if call := client.removeCall(seq); call != nil {
+			call.Error = withLayer(err, layerOf(err))
+			call.done()
+		}
+		return false
+	}
+	return true
+}
+
+// Go 异步调用指定的方法，返回表示该调用的 Call。
+// done 为 nil 时会分配一个新的带缓冲 channel，否则 done 必须带缓冲。
+// 设置了 Option.CallTimeout 时，超时未完成的调用以 ErrTimeout 结束
+func (client *Client) Go(serviceMethod string, args, reply interface{}, done chan *Call) *Call {
+	if done == nil {
+		done = make(chan *Call, 10)
+	} else if cap(done) == 0 {
+		log.Panic("rpc client: done channel is unbuffered")
+	}
+	call, _ := client.start(context.Background(), serviceMethod, args, reply, done)
+	return call
+}
+
+// start 创建调用并发送请求，是 Go、Call 和 CallBatch 共同的实现，返回请求是否已完整写入连接。
+// ctx 的截止时间、链路追踪标识和元数据随请求发送；设置了 Option.CallTimeout 且早于 ctx 的截止时间时，
+// 超时未完成的调用以 ErrTimeout 结束
+func (client *Client) start(ctx context.Context, serviceMethod string, args, reply interface{}, done chan *Call) (call *Call, written bool) {
+	call = &Call{
+		ServiceMethod: serviceMethod,
+		Args:          args,
+		Reply:         reply,
+		Done:          done,
+		deadline:      deadlineOf(ctx),
+	}
+	call.trace.traceID, call.trace.spanID = TraceFromContext(ctx)
+	call.meta = MetaFromContext(ctx)
+	d := client.opt.CallTimeout
+	end := time.Now().Add(d).UnixNano()
+	if d <= 0 || (call.deadline != 0 && end >= call.deadline) {
+		return call, client.send(call)
+	}
+	call.deadline = end
+	written = client.send(call)
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.pending[call.Seq] == call { // 调用已结束时不再需要定时器
+		call.timer = time.AfterFunc(d, func() { client.timeoutCall(call) })
+	}
+	return call, written
+}
+
+// Call 调用指定的方法并等待其完成，返回错误状态。
+// ctx 被取消或超时时，调用从 pending 中移除并返回 ctx.Err()，迟到的响应会被 receive 丢弃。
+// 设置了 Option.CallTimeout 且早于 ctx 的截止时间时，超时后同样移除调用并返回 ErrTimeout。
+// ctx 的截止时间会随请求发送给服务端，服务端超过截止时间后不再等待方法返回；
+// ctx 中由 ContextWithTrace 设置的链路追踪标识也会随请求发送。
+// 拦截器对 ctx 的修改对发送的截止时间和追踪标识同样生效。
+// 调用会依次经过 Use 添加的拦截器
+func (client *Client) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	return client.invoke(ctx, serviceMethod, args, reply, client.call)
+}
+
+// call 发送请求并等待响应，是拦截器链最内层的 Invoker
+func (client *Client) call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	_, err := client.roundTrip(ctx, serviceMethod, args, reply)
+	return err
+}
+
+// roundTrip 与 Go 相同地发送请求并等待响应，written 表示请求是否已完整写入连接，
+// 为 false 时服务端一定没有收到请求
+func (client *Client) roundTrip(ctx context.Context, serviceMethod string, args, reply interface{}) (written bool, err error) {
+	call, written := client.start(ctx, serviceMethod, args, reply, make(chan *Call, 1))
+	select {
+	case <-ctx.Done():
+		if client.cancelCall(call) {
+			return written, ctx.Err()
+		}
+		// 调用已被 receive 取出，等待它完成，避免返回后 receive 仍在写入 reply
+		<-call.Done
+		return written, call.Error
+	case call := <-call.Done:
+		return written, call.Error
+	}
+}
+
+// deadlineOf 返回 ctx 的截止时间（Unix 纳秒），没有截止时间时返回 0
+func deadlineOf(ctx context.Context) int64 {
+	if d, ok := ctx.Deadline(); ok {
+		return d.UnixNano()
+	}
+	return 0
+}
+
+// Notify 发送一个单向请求，请求完整写入后立即返回，不等待也不接收响应。
+// 服务端执行方法但不发送响应，方法返回的错误只记录在服务端的日志中。
+// ctx 的截止时间、链路追踪标识和元数据随请求发送。单向请求不经过客户端拦截器
+func (client *Client) Notify(ctx context.Context, serviceMethod string, args interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	client.sending.Lock()
+	defer client.sending.Unlock()
+	client.mu.Lock()
+	unavailable := client.closing || client.shutdown
+	client.mu.Unlock()
+	if unavailable {
+		return ErrShutdown
+	}
+
+	client.header.ServiceMethod = serviceMethod
+	client.header.Seq = 0 // 单向请求不注册到 pending，旧服务端的响应会因序列号未知而被丢弃
+	client.header.Error = ""
+	client.header.Deadline = deadlineOf(ctx)
+	client.header.TraceID, client.header.SpanID = TraceFromContext(ctx)
+	client.header.OneWay = true
+	client.header.Meta = MetaFromContext(ctx)
+	client.header.Nonce, client.header.Timestamp = newNonce(), time.Now().UnixNano() // 供服务端的重放保护使用
+	if err := client.cc.Write(&client.header, args); err != nil {
+		client.mu.Lock()
+		client.shutdown = true // 与 send 相同，连接已被编解码器关闭
+		client.mu.Unlock()
+		return withLayer(err, layerOf(err))
+	}
+	return nil
+}
+
+// parseOptions 解析可选的 Option 参数，未设置的字段使用默认值
+func parseOptions(opts ...*Option) (*Option, error) {
+	if len(opts) == 0 || opts[0] == nil {
+		return DefaultOption, nil
+	}
+	if len(opts) != 1 {
+		return nil, errors.New("number of options is more than 1")
+	}
+	opt := *opts[0]
+	opt.MagicNumber = DefaultOption.MagicNumber
+	if opt.CodecType == "" {
+		opt.CodecType = DefaultOption.CodecType
+	}
+	return &opt, nil
+}
+
+// checkCodec 检查 opt 指定的编解码器是否可用，设置了 FallbackCodecs 时只需其中之一可用
+func checkCodec(opt *Option) error {
+	var err error
+	for _, t := range append([]codec.Type{opt.CodecType}, opt.FallbackCodecs...) {
+		o := *opt
+		o.CodecType = t
+		if _, err = newCodecFunc(&o); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("rpc client: %w (registered codecs: %v)", err, codec.Codecs())
+}
+
+// NewClient 在已建立的连接上完成 Option 握手并创建客户端
+func NewClient(conn net.Conn, opt *Option) (*Client, error) {
+	if len(opt.FallbackCodecs) > 0 {
+		return newNegotiatedClient(conn, opt)
+	}
+	f, err := newCodecFunc(opt)
+	if err != nil {
+		getLogger().Errorf("rpc client: codec error: %v", err)
+		return nil, err
+	}
+	// 先发送 Option 与服务端协商编解码方式
+	if err := json.NewEncoder(conn).Encode(opt); err != nil {
+		getLogger().Errorf("rpc client: options error: %v", err)
+		_ = conn.Close()
+		return nil, err
+	}
+	cconn := newClientConn(conn)
+	return newClientCodec(f(cconn), cconn, opt), nil
+}
+
+func newClientCodec(cc codec.Codec, conn *clientConn, opt *Option) *Client {
+	client := &Client{
+		seq:     1, // 序列号从 1 开始，0 表示无效调用
+		cc:      cc,
+		conn:    conn,
+		opt:     opt,
+		pending: make(map[uint64]*Call),
+	}
+	go client.receive()
+	return client
+}
+
+type clientResult struct {
+	client *Client
+	err    error
+}
+
+type newClientFunc func(conn net.Conn, opt *Option) (client *Client, err error)
+
+// dialTimeout 建立连接并用 f 完成握手，整个过程受 opt.ConnectTimeout 限制
+func dialTimeout(f newClientFunc, network, address string, opts ...*Option) (client *Client, err error) {
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkCodec(opt); err != nil { // 编解码器不可用时不必建立连接
+		return nil, err
+	}
+	conn, err := dialConn(opt, network, address)
+	if err != nil {
+		return nil, withLayer(err, ErrTransport)
+	}
+	defer func() {
+		if err != nil {
+			_ = conn.Close()
+		}
+	}()
+	if opt.TLSConfig != nil {
+		conn = tls.Client(conn, tlsClientConfig(opt.TLSConfig, address))
+	}
+	ch := make(chan clientResult, 1) // 带缓冲，超时返回后握手 goroutine 不会阻塞
+	go func() {
+		// TLS 握手必须在发送 Option 之前完成
+		if tlsConn, ok := conn.(*tls.Conn); ok {
+			if err := tlsConn.Handshake(); err != nil {
+				ch <- clientResult{err: withLayer(err, ErrTransport)}
+				return
+			}
+		}
+		client, err := f(conn, opt)
+		ch <- clientResult{client: client, err: err}
+	}()
+	if opt.ConnectTimeout == 0 {
+		result := <-ch
+		return result.client, result.err
+	}
+	select {
+	case <-time.After(opt.ConnectTimeout):
+		return nil, withLayer(fmt.Errorf("rpc client: connect timeout: expect within %s", opt.ConnectTimeout), ErrTransport)
+	case result := <-ch:
+		return result.client, result.err
+	}
+}
+
+// dialConn 使用 opt.Dialer 建立连接，未设置时使用 net.DialTimeout
+func dialConn(opt *Option, network, address string) (net.Conn, error) {
+	if opt.Dialer == nil {
+		return net.DialTimeout(network, address, opt.ConnectTimeout)
+	}
+	ctx := context.Background()
+	if opt.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.ConnectTimeout)
+		defer cancel()
+	}
+	return opt.Dialer(ctx, network, address)
+}
+
+// Dial 连接指定网络地址上的 RPC 服务器
+func Dial(network, address string, opts ...*Option) (*Client, error) {
+	return dialTimeout(NewClient, network, address, opts...)
+}
+
+// NewHTTPClient 通过 HTTP CONNECT 建立 RPC 连接
+func NewHTTPClient(conn net.Conn, opt *Option) (*Client, error) {
+	_, _ = io.WriteString(conn, fmt.Sprintf("CONNECT %s HTTP/1.0\n\n", defaultRPCPath))
+
+	// 切换到 RPC 协议之前需要先收到成功的 HTTP 响应
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err == nil && resp.Status == connected {
+		return NewClient(conn, opt)
+	}
+	if err == nil {
+		err = errors.New("unexpected HTTP response: " + resp.Status)
+	}
+	return nil, err
+}
+
+// DialHTTP 连接监听在默认 HTTP RPC 路径上的服务器
+func DialHTTP(network, address string, opts ...*Option) (*Client, error) {
+	return dialTimeout(NewHTTPClient, network, address, opts...)
+}
+
+// tlsClientConfig 未设置 ServerName 时，和 tls.Dial 一样使用地址中的主机名
+func tlsClientConfig(cfg *tls.Config, address string) *tls.Config {
+	if cfg.ServerName != "" || cfg.InsecureSkipVerify {
+		return cfg
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	c := cfg.Clone()
+	c.ServerName = host
+	return c
+}
+
+// DialTLS 通过 TLS 连接指定网络地址上的 RPC 服务器
+func DialTLS(network, address string, cfg *tls.Config, opts ...*Option) (*Client, error) {
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	o := *opt
+	o.TLSConfig = cfg
+	return Dial(network, address, &o)
+}
+
+// XDial 根据 rpcAddr 的协议部分选择连接方式。
+// rpcAddr 的格式为 protocol@addr，例如 http@10.0.0.1:7001、tcp@10.0.0.1:9999、unix@/tmp/gorpc.sock
+func XDial(rpcAddr string, opts ...*Option) (*Client, error) {
+	parts := strings.Split(rpcAddr, "@")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("rpc client err: wrong format '%s', expect protocol@addr", rpcAddr)
+	}
+	protocol, addr := parts[0], parts[1]
+	switch protocol {
+	case "http":
+		return DialHTTP("tcp", addr, opts...)
+	default:
+		// tcp, unix 或其他传输协议
+		return Dial(protocol, addr, opts...)
+	}
+}
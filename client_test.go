@@ -0,0 +1,664 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientCallDefaultServer(t *testing.T) {
+	if err := RegisterName("Arith", new(Foo)); err != nil {
+		t.Fatal(err)
+	}
+	l := listenTCP(t)
+	go Accept(l)
+
+	client := dialServer(t, l.Addr().String())
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var reply int
+			if err := client.Call(context.Background(), "Arith.Sum", Args{Num1: i, Num2: i}, &reply); err != nil || reply != 2*i {
+				t.Errorf("expect %d, got %d, %v", 2*i, reply, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	var reply int
+	if err := client.Call(context.Background(), "Arith.Div", Args{Num1: 1}, &reply); err == nil {
+		t.Fatal("expect the method error to be returned")
+	}
+}
+
+func TestClientGo(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr)
+
+	done := make(chan *Call, 3)
+	for i := 0; i < 3; i++ {
+		client.Go("Foo.Sum", Args{Num1: i, Num2: 1}, new(int), done)
+	}
+	sum := 0
+	for i := 0; i < 3; i++ {
+		call := <-done
+		if call.Error != nil {
+			t.Fatalf("call %d failed: %v", call.Seq, call.Error)
+		}
+		sum += *call.Reply.(*int)
+	}
+	if sum != 6 {
+		t.Fatalf("expect sum of replies 6, got %d", sum)
+	}
+
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect Call to return 3, got %d, %v", reply, err)
+	}
+}
+
+func TestClientGoConcurrent(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr)
+
+	calls := make([]*Call, 100)
+	var wg sync.WaitGroup
+	for i := range calls {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			calls[i] = client.Go("Foo.Sum", Args{Num1: i, Num2: 1}, new(int), nil)
+		}(i)
+	}
+	wg.Wait()
+	for i, call := range calls {
+		<-call.Done
+		if call.Error != nil {
+			t.Fatal(call.Error)
+		}
+		if reply := *call.Reply.(*int); reply != i+1 || call.Args.(Args).Num1 != i {
+			t.Fatalf("call %d: expect reply %d, got %d", i, i+1, reply)
+		}
+	}
+}
+
+func TestClientGoUnbufferedDone(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expect Go to panic on an unbuffered done channel")
+		}
+	}()
+	client.Go("Foo.Sum", Args{}, new(int), make(chan *Call))
+}
+
+func TestClientCallTimeout(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr, &Option{CallTimeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	var reply int
+	err := client.Call(context.Background(), "Foo.Sleep", Args{Num1: 500}, &reply)
+	if !isTimeout(err) {
+		t.Fatalf("expect a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Fatalf("expect the call to time out after about 50ms, took %v", elapsed)
+	}
+
+	call := <-client.Go("Foo.Sleep", Args{Num1: 500}, new(int), nil).Done
+	if !isTimeout(call.Error) {
+		t.Fatalf("expect Go to time out, got %v", call.Error)
+	}
+}
+
+// isTimeout 返回 err 是否表示调用超时。CallTimeout 同时作为截止时间发送给服务端，
+// 服务端可能先于客户端的定时器回复超时错误
+func isTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout) || (errors.Is(err, ErrServer) && strings.Contains(err.Error(), "deadline exceeded"))
+}
+
+func TestClientCallTimeoutAfterConnectionLost(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr, &Option{CallTimeout: 50 * time.Millisecond})
+
+	done := make(chan *Call, 2)
+	client.Go("Foo.Sleep", Args{Num1: 500}, new(int), done)
+	time.Sleep(10 * time.Millisecond)
+	_ = client.Close() // 连接在 CallTimeout 之前断开
+	call := <-done
+	if !errors.Is(call.Error, ErrShutdown) {
+		t.Fatalf("expect ErrShutdown, got %v", call.Error)
+	}
+	time.Sleep(100 * time.Millisecond) // 等待 CallTimeout 过去
+	if n := len(done); n != 0 {
+		t.Fatalf("expect the call to complete exactly once, got %d more completions", n)
+	}
+}
+
+func TestClientCallContextDeadline(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := client.Call(ctx, "Foo.Sleep", Args{Num1: 200}, new(int))
+	if !errors.Is(err, context.DeadlineExceeded) && !isTimeout(err) {
+		t.Fatalf("expect the ctx deadline to end the call, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Fatalf("expect Call to return with ctx, took %v", elapsed)
+	}
+	client.mu.Lock()
+	pending := len(client.pending)
+	client.mu.Unlock()
+	if pending != 0 {
+		t.Fatalf("expect the cancelled call to be removed, got %d pending", pending)
+	}
+
+	// 被取消的调用的响应稍后到达时被丢弃，不影响之后的调用
+	time.Sleep(200 * time.Millisecond)
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, got %d, %v", reply, err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := client.Call(ctx, "Foo.Sum", Args{}, &reply); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+}
+
+// silentListener 接受连接但从不读写，返回监听地址
+func silentListener(t *testing.T) string {
+	l := listenTCP(t)
+	done := make(chan struct{})
+	go func() {
+		var conns []net.Conn
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				break
+			}
+			conns = append(conns, conn)
+		}
+		<-done
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+	t.Cleanup(func() {
+		close(done)
+		_ = l.Close()
+	})
+	return l.Addr().String()
+}
+
+func TestDialConnectTimeout(t *testing.T) {
+	addr := silentListener(t)
+	start := time.Now()
+	_, err := DialHTTP("tcp", addr, &Option{ConnectTimeout: 100 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "connect timeout") || !errors.Is(err, ErrTransport) {
+		t.Fatalf("expect a connect timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expect Dial to give up after about 100ms, took %v", elapsed)
+	}
+
+	slow := func(conn net.Conn, opt *Option) (*Client, error) {
+		time.Sleep(500 * time.Millisecond)
+		return nil, errors.New("unreachable")
+	}
+	if _, err := dialTimeout(slow, "tcp", addr, &Option{ConnectTimeout: 50 * time.Millisecond}); err == nil || !strings.Contains(err.Error(), "connect timeout") {
+		t.Fatalf("expect a slow handshake to time out, got %v", err)
+	}
+	if _, err := dialTimeout(slow, "tcp", addr, &Option{ConnectTimeout: time.Second}); err == nil || err.Error() != "unreachable" {
+		t.Fatalf("expect the handshake error within the timeout, got %v", err)
+	}
+}
+
+// Waiter 的方法等待 ctx 结束，并把 ctx 的截止时间和错误发送给测试
+type Waiter struct {
+	seen chan error
+}
+
+func (w *Waiter) Wait(ctx context.Context, args Args, reply *int) error {
+	if _, ok := ctx.Deadline(); !ok {
+		w.seen <- errors.New("no deadline")
+		return nil
+	}
+	<-ctx.Done()
+	w.seen <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestClientDeadlinePropagation(t *testing.T) {
+	waiter := &Waiter{seen: make(chan error, 1)}
+	_, addr := startServer(t, waiter)
+	client := dialServer(t, addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := client.Call(ctx, "Waiter.Wait", Args{}, new(int)); err == nil {
+		t.Fatal("expect the call to time out")
+	}
+	select {
+	case err := <-waiter.seen:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expect the method to see the client deadline, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect the method to stop at the client deadline")
+	}
+
+	// 截止时间已过的请求直接返回错误，不调用方法
+	cc := dialRaw(t, addr, DefaultOption)
+	past := time.Now().Add(-time.Second).UnixNano()
+	if err := cc.Write(&codec.Header{ServiceMethod: "Waiter.Wait", Seq: 1, Deadline: past}, Args{}); err != nil {
+		t.Fatal(err)
+	}
+	var h codec.Header
+	if err := cc.ReadHeader(&h); err != nil {
+		t.Fatal(err)
+	}
+	_ = cc.ReadBody(nil)
+	if !strings.Contains(h.Error, "deadline exceeded") {
+		t.Fatalf("expect a deadline exceeded error, got %q", h.Error)
+	}
+	select {
+	case err := <-waiter.seen:
+		t.Fatalf("expect the method not to be called, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestClientMsgpackCodec(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr, &Option{MagicNumber: MagicNumber, CodecType: codec.MsgpackType})
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3 over msgpack, got %d, %v", reply, err)
+	}
+	if err := client.Call(context.Background(), "Foo.Div", Args{Num1: 1}, &reply); err == nil || !strings.Contains(err.Error(), "divide by zero") {
+		t.Fatalf("expect the error response over msgpack, got %v", err)
+	}
+}
+
+func TestDialCodecType(t *testing.T) {
+	_, addr := startServer(t)
+	for _, typ := range []codec.Type{codec.GobType, codec.JsonType} {
+		client := dialServer(t, addr, &Option{CodecType: typ})
+		var reply int
+		if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+			t.Fatalf("expect 3 over %s, got %d, %v", typ, reply, err)
+		}
+	}
+
+	// 未注册的编解码器在建立连接之前就返回错误
+	dialed := false
+	opt := &Option{CodecType: "application/bogus", Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = true
+		return net.Dial(network, address)
+	}}
+	_, err := Dial("tcp", addr, opt)
+	if err == nil || !strings.Contains(err.Error(), "application/bogus") || !strings.Contains(err.Error(), "registered codecs") {
+		t.Fatalf("expect an error naming the unknown codec, got %v", err)
+	}
+	if dialed {
+		t.Fatal("expect the unknown codec to fail before connecting")
+	}
+}
+
+func TestClientCustomDialer(t *testing.T) {
+	server := newTestServer(t)
+	var dialed string
+	opt := &Option{Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = network + "@" + address
+		serverConn, clientConn := net.Pipe()
+		go server.ServeConn(serverConn)
+		return clientConn, nil
+	}}
+	client, err := Dial("tcp", "in-memory:0", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3 over the pipe, got %d, %v", reply, err)
+	}
+	if dialed != "tcp@in-memory:0" {
+		t.Fatalf("expect the dialer to get the network and address, got %s", dialed)
+	}
+
+	failing := &Option{Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, errors.New("proxy unavailable")
+	}}
+	if _, err := Dial("tcp", "in-memory:0", failing); err == nil || !strings.Contains(err.Error(), "proxy unavailable") {
+		t.Fatalf("expect the dialer error, got %v", err)
+	}
+}
+
+// readerConn 从 r 读取，写入 Conn，用于读取 Option 后在同一个缓冲上继续读取
+type readerConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *readerConn) Read(p []byte) (int, error) { return c.r.Read(p) }
+
+func TestClientDropsUnknownSeq(t *testing.T) {
+	l := listenTCP(t)
+	// 模拟的服务端对每个请求先回复一个未知的 Seq，再回复正确的结果，最后重复回复一次
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		rc := &readerConn{Conn: conn, r: bufio.NewReader(conn)}
+		if _, err := rc.r.ReadBytes('\n'); err != nil { // Option
+			return
+		}
+		cc := codec.NewGobCodec(rc)
+		defer func() { _ = cc.Close() }()
+		for want := uint64(1); ; want++ {
+			var h codec.Header
+			var args Args
+			if cc.ReadHeader(&h) != nil || cc.ReadBody(&args) != nil {
+				return
+			}
+			if h.Seq != want { // 客户端的序列号从 1 开始递增
+				_ = cc.Write(&codec.Header{ServiceMethod: h.ServiceMethod, Seq: h.Seq, Error: fmt.Sprintf("expect seq %d", want)}, nil)
+				continue
+			}
+			sum := args.Num1 + args.Num2
+			_ = cc.Write(&codec.Header{ServiceMethod: h.ServiceMethod, Seq: h.Seq + 100}, -1)
+			_ = cc.Write(&codec.Header{ServiceMethod: h.ServiceMethod, Seq: h.Seq}, sum)
+			_ = cc.Write(&codec.Header{ServiceMethod: h.ServiceMethod, Seq: h.Seq}, -2)
+		}
+	}()
+
+	client := dialServer(t, l.Addr().String())
+	for i := 1; i <= 3; i++ {
+		var reply int
+		if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: i, Num2: i}, &reply); err != nil || reply != 2*i {
+			t.Fatalf("expect call %d to get its own reply %d, got %d, %v", i, 2*i, reply, err)
+		}
+	}
+	if !client.IsAvailable() {
+		t.Fatal("expect the client to survive unknown and duplicate seqs")
+	}
+}
+
+func TestClientCloseFailsPendingCalls(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr)
+	calls := make([]*Call, 5)
+	for i := range calls {
+		calls[i] = client.Go("Foo.Sleep", Args{Num1: 200}, new(int), nil)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i, call := range calls {
+		select {
+		case <-call.Done:
+			if !errors.Is(call.Error, ErrShutdown) {
+				t.Fatalf("expect call %d to end with ErrShutdown, got %v", i, call.Error)
+			}
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("expect call %d to end when the client is closed", i)
+		}
+	}
+
+	if client.IsAvailable() {
+		t.Fatal("expect a closed client to be unavailable")
+	}
+	if err := client.Close(); !errors.Is(err, ErrShutdown) {
+		t.Fatalf("expect closing twice to return ErrShutdown, got %v", err)
+	}
+	if err := client.Call(context.Background(), "Foo.Sum", Args{}, new(int)); !errors.Is(err, ErrShutdown) {
+		t.Fatalf("expect Call after Close to return ErrShutdown, got %v", err)
+	}
+	if call := <-client.Go("Foo.Sum", Args{}, new(int), nil).Done; !errors.Is(call.Error, ErrShutdown) {
+		t.Fatalf("expect Go after Close to return ErrShutdown, got %v", call.Error)
+	}
+}
+
+func TestClientConnectionLost(t *testing.T) {
+	server, l := newTestServer(t), listenTCP(t)
+	tl := &trackingListener{Listener: l}
+	go server.Accept(tl)
+
+	client := dialServer(t, l.Addr().String())
+	call := client.Go("Foo.Sleep", Args{Num1: 1000}, new(int), nil)
+	time.Sleep(20 * time.Millisecond) // 请求已发送，响应还没有返回
+	tl.closeAll()
+	select {
+	case <-call.Done:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("expect the pending call to fail when the connection drops")
+	}
+	if !errors.Is(call.Error, ErrConnectionLost) || errors.Is(call.Error, ErrServer) {
+		t.Fatalf("expect a connection error rather than a server error, got %v", call.Error)
+	}
+	if client.IsAvailable() {
+		t.Fatal("expect the client to be unavailable after the connection drops")
+	}
+}
+
+func TestClientCallTimeoutPrecedence(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr, &Option{CallTimeout: 300 * time.Millisecond})
+
+	// 更短的 ctx 截止时间优先于 CallTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := client.Call(ctx, "Foo.Sleep", Args{Num1: 500}, new(int))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect the ctx deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("expect the shorter ctx deadline to take precedence, took %v", elapsed)
+	}
+
+	// 更长的 ctx 截止时间不会延长 CallTimeout
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start = time.Now()
+	if err := client.Call(ctx, "Foo.Sleep", Args{Num1: 1000}, new(int)); !isTimeout(err) {
+		t.Fatalf("expect CallTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 800*time.Millisecond {
+		t.Fatalf("expect CallTimeout to end the call, took %v", elapsed)
+	}
+
+	// CallTimeout 为 0 时不限制调用时间
+	unlimited := dialServer(t, addr)
+	var reply int
+	if err := unlimited.Call(context.Background(), "Foo.Sleep", Args{Num1: 100, Num2: 1}, &reply); err != nil || reply != 101 {
+		t.Fatalf("expect the call to complete without a timeout, got %d, %v", reply, err)
+	}
+}
+
+var errWriteFailed = errors.New("write failed")
+
+// failConn 在 budget 不小于 0 时只再写入 budget 个字节，之后的写入返回包装了 errWriteFailed 的 *net.OpError
+type failConn struct {
+	net.Conn
+	mu     sync.Mutex
+	budget int
+}
+
+func (c *failConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.budget < 0 || len(p) <= c.budget {
+		if c.budget >= 0 {
+			c.budget -= len(p)
+		}
+		return c.Conn.Write(p)
+	}
+	n, _ := c.Conn.Write(p[:c.budget])
+	c.budget = 0
+	return n, &net.OpError{Op: "write", Net: "tcp", Err: errWriteFailed}
+}
+
+// failAfter 限制 c 之后只能再写入 n 个字节
+func (c *failConn) failAfter(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget = n
+}
+
+func TestClientWriteFailure(t *testing.T) {
+	_, addr := startServer(t)
+	var conns []*failConn
+	opt := &Option{Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		fc := &failConn{Conn: conn, budget: -1}
+		conns = append(conns, fc)
+		return fc, nil
+	}}
+	client, err := Dial("tcp", addr, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+
+	// 请求只写入 10 个字节后连接出错，调用以写入错误结束，不会一直等待响应
+	conns[0].failAfter(10)
+	err = client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+	if !errors.Is(err, errWriteFailed) || !errors.Is(err, ErrTransport) {
+		t.Fatalf("expect the write error, got %v", err)
+	}
+	client.mu.Lock()
+	pending := len(client.pending)
+	client.mu.Unlock()
+	if pending != 0 || client.IsAvailable() {
+		t.Fatalf("expect the failed call to be removed and the client unavailable, got %d pending", pending)
+	}
+	if err := client.Call(context.Background(), "Foo.Sum", Args{}, &reply); !errors.Is(err, ErrShutdown) {
+		t.Fatalf("expect later calls to fail fast with ErrShutdown, got %v", err)
+	}
+
+	// 重新连接后可以继续调用
+	client, err = Dial("tcp", addr, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 2, Num2: 3}, &reply); err != nil || reply != 5 {
+		t.Fatalf("expect 5 after reconnecting, got %d, %v", reply, err)
+	}
+}
+
+// Events 记录收到的通知数
+type Events struct{ n atomic.Int32 }
+
+func (e *Events) Record(args Args, reply *int) error {
+	e.n.Add(1)
+	*reply = args.Num1
+	return nil
+}
+
+func TestClientNotify(t *testing.T) {
+	events := new(Events)
+	_, addr := startServer(t, events)
+	client := dialServer(t, addr)
+	for i := 0; i < 3; i++ {
+		if err := client.Notify(context.Background(), "Events.Record", Args{Num1: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.mu.Lock()
+	pending := len(client.pending)
+	client.mu.Unlock()
+	if pending != 0 {
+		t.Fatalf("expect one-way calls not to be pending, got %d", pending)
+	}
+	waitFor(t, "the notifications to be executed", func() bool { return events.n.Load() == 3 })
+
+	// 服务端不回复单向请求，收到的第一个响应属于之后的普通请求
+	cc := dialRaw(t, addr, DefaultOption)
+	if err := cc.Write(&codec.Header{ServiceMethod: "Events.Record", OneWay: true}, Args{Num1: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.Write(&codec.Header{ServiceMethod: "Events.Record", Seq: 7}, Args{Num1: 2}); err != nil {
+		t.Fatal(err)
+	}
+	var h codec.Header
+	var reply int
+	if err := cc.ReadHeader(&h); err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.ReadBody(&reply); err != nil || h.Seq != 7 || reply != 2 {
+		t.Fatalf("expect only the response to seq 7, got %+v, %d, %v", h, reply, err)
+	}
+	waitFor(t, "the one-way request to be executed", func() bool { return events.n.Load() == 5 })
+}
+
+func TestClientSeqGenerator(t *testing.T) {
+	server, addr := startServer(t)
+	var mu sync.Mutex
+	var seen []uint64
+	server.Use(func(ctx context.Context, h *codec.Header, argv interface{}, next Handler) (interface{}, error) {
+		mu.Lock()
+		seen = append(seen, h.Seq)
+		mu.Unlock()
+		return next(ctx, h, argv)
+	})
+	seqs := []uint64{100, 7, 42, 42, 42, 0}
+	next := 0
+	client := dialServer(t, addr, &Option{SeqGenerator: SeqGeneratorFunc(func() uint64 {
+		seq := seqs[next]
+		next++
+		return seq
+	})})
+
+	want := seqs[:3]
+	for i, seq := range want {
+		call := client.Go("Foo.Sum", Args{Num1: i, Num2: 1}, new(int), nil)
+		<-call.Done
+		if call.Error != nil || call.Seq != seq || *call.Reply.(*int) != i+1 {
+			t.Fatalf("expect call %d to complete with seq %d, got seq %d, %v", i, seq, call.Seq, call.Error)
+		}
+	}
+	mu.Lock()
+	got := fmt.Sprint(seen)
+	mu.Unlock()
+	if got != fmt.Sprint(want) {
+		t.Fatalf("expect the server to see seqs %v, got %s", want, got)
+	}
+
+	// 已完成调用的序列号可以复用，与未完成的调用重复的序列号和 0 都会使调用失败
+	pending := client.Go("Foo.Sleep", Args{Num1: 50}, new(int), nil)
+	for _, seq := range seqs[4:] {
+		if err := client.Call(context.Background(), "Foo.Sum", Args{}, new(int)); err == nil || !strings.Contains(err.Error(), "invalid seq") {
+			t.Fatalf("expect seq %d to be rejected, got %v", seq, err)
+		}
+	}
+	if (<-pending.Done).Error != nil {
+		t.Fatalf("expect the pending call to be unaffected, got %v", pending.Error)
+	}
+}
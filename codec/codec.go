@@ -1,6 +1,11 @@
 package codec
 
-import "io"
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
 
 // 消息编解码相关的代码都放到 codec 子目录
 
@@ -8,12 +13,28 @@ type Header struct {
 	ServiceMethod string // format "Service.Method"
 	Seq           uint64 // 客户端选择的序列号
 	Error         string
+	ErrorCode     int    // Error 对应的错误码，没有错误时为 0
+	Compressed    bool   // 消息体是否被压缩，仅分帧编码使用
+	StreamIndex   uint64 // 流式响应中消息的序号，从 1 开始，普通响应为 0
+	StreamEnd     bool   // 流式响应的结束标记，该响应没有有效的消息体
+	Deadline      int64  // 请求的截止时间（Unix 纳秒），由客户端根据 ctx 设置，0 表示没有截止时间
+	TraceID       string // 链路追踪 ID，不使用时为空
+	SpanID        string // 链路追踪的 span ID，不使用时为空
+	OneWay        bool   // 单向请求，服务端执行方法但不发送响应，Seq 为 0
+	Nonce         string // 单向请求的随机数，服务端启用重放保护时用于识别重复的请求，不使用时为空
+	Timestamp     int64  // 单向请求的发送时间（Unix 纳秒），与 Nonce 一起使用，不使用时为 0
+
+	Meta map[string]string // 可扩展的元数据，由客户端的 ctx 或服务端拦截器设置，不使用时为 nil
 }
 
+// Codec 定义消息的编解码接口。
+// ReadBody 出错时实现需保证该消息体已被完整消费，使下一次 ReadHeader 不会错位；
+// Discard 用于跳过一个尚未读取的消息体
 type Codec interface {
 	io.Closer
 	ReadHeader(*Header) error
 	ReadBody(interface{}) error
+	Discard() error
 	Write(*Header, interface{}) error
 }
 
@@ -23,12 +44,83 @@ type Type string
 
 const (
 	GobType  Type = "application/gob"
-	JsonType Type = "application/json" // not implemented
+	JsonType Type = "application/json"
+
+	ProtobufType Type = "application/protobuf" // 参数和返回值必须实现 proto.Message
+	MsgpackType  Type = "application/msgpack"
 )
 
-var NewCodecFuncMap map[Type]NewCodecFunc
+var (
+	codecMu      sync.RWMutex
+	newCodecFunc = make(map[Type]NewCodecFunc)
+	marshalers   = make(map[Type]Marshaler) // 支持分帧编码的类型
+)
 
 func init() {
-	NewCodecFuncMap = make(map[Type]NewCodecFunc)
-	NewCodecFuncMap[GobType] = NewGobCodec
+	_ = RegisterCodec(GobType, NewGobCodec)
+	_ = RegisterCodec(JsonType, NewJSONCodec)
+	RegisterMarshaler(GobType, GobMarshaler{})
+	RegisterMarshaler(JsonType, JsonMarshaler{})
+	_ = RegisterCodec(ProtobufType, NewProtobufCodec)
+	RegisterMarshaler(ProtobufType, ProtobufMarshaler{})
+	_ = RegisterCodec(MsgpackType, NewMsgpackCodec)
+	RegisterMarshaler(MsgpackType, MsgpackMarshaler{})
+}
+
+// RegisterCodec 注册编解码器构造函数，该类型已注册时返回错误
+func RegisterCodec(t Type, f NewCodecFunc) error {
+	if f == nil {
+		return fmt.Errorf("codec: register nil NewCodecFunc for %s", t)
+	}
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	if _, dup := newCodecFunc[t]; dup {
+		return fmt.Errorf("codec: type %s already registered", t)
+	}
+	newCodecFunc[t] = f
+	return nil
+}
+
+// ReplaceCodec 注册编解码器构造函数，该类型已注册时覆盖原有的构造函数
+func ReplaceCodec(t Type, f NewCodecFunc) error {
+	if f == nil {
+		return fmt.Errorf("codec: register nil NewCodecFunc for %s", t)
+	}
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	newCodecFunc[t] = f
+	return nil
+}
+
+// GetCodecFunc 返回类型 t 对应的构造函数，未注册时返回 nil
+func GetCodecFunc(t Type) NewCodecFunc {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	return newCodecFunc[t]
+}
+
+// Codecs 返回所有已注册的编解码类型，按名称排序
+func Codecs() []Type {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	types := make([]Type, 0, len(newCodecFunc))
+	for t := range newCodecFunc {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// RegisterMarshaler 注册类型 t 在分帧编码下使用的 Marshaler，已存在时覆盖
+func RegisterMarshaler(t Type, m Marshaler) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	marshalers[t] = m
+}
+
+// GetMarshaler 返回类型 t 对应的 Marshaler，不支持分帧编码时返回 nil
+func GetMarshaler(t Type) Marshaler {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	return marshalers[t]
 }
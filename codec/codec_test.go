@@ -0,0 +1,87 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegisterCodecDuplicate(t *testing.T) {
+	if err := RegisterCodec(GobType, NewJSONCodec); err == nil {
+		t.Fatal("expect registering an existing type to fail")
+	}
+	if GetCodecFunc(GobType) == nil {
+		t.Fatal("expect the gob codec to stay registered")
+	}
+	if err := RegisterCodec("test/nil", nil); err == nil {
+		t.Fatal("expect registering a nil NewCodecFunc to fail")
+	}
+
+	const typ Type = "test/replace"
+	var used string
+	first := func(conn io.ReadWriteCloser) Codec { used = "first"; return NewGobCodec(conn) }
+	second := func(conn io.ReadWriteCloser) Codec { used = "second"; return NewGobCodec(conn) }
+	if err := RegisterCodec(typ, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterCodec(typ, second); err == nil {
+		t.Fatal("expect registering the type twice to fail")
+	}
+	if err := ReplaceCodec(typ, second); err != nil {
+		t.Fatalf("expect ReplaceCodec to replace the codec, got %v", err)
+	}
+	GetCodecFunc(typ)(&bufferConn{})
+	if used != "second" {
+		t.Fatalf("expect the replaced codec to be used, got %s", used)
+	}
+}
+
+func TestRegisterCodecConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// 每个类型注册两次，只有一次能成功
+			errs <- RegisterCodec(Type(fmt.Sprintf("test/concurrent-%d", i/2)), NewGobCodec)
+			_ = Codecs()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	failed := 0
+	for err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed != 50 {
+		t.Fatalf("expect 50 duplicate registrations to fail, got %d", failed)
+	}
+	registered := 0
+	for _, typ := range Codecs() {
+		if strings.HasPrefix(string(typ), "test/concurrent-") {
+			registered++
+		}
+	}
+	if registered != 50 {
+		t.Fatalf("expect 50 registered types, got %d", registered)
+	}
+}
+
+func TestCodecsSorted(t *testing.T) {
+	types := Codecs()
+	for i := 1; i < len(types); i++ {
+		if types[i-1] >= types[i] {
+			t.Fatalf("expect Codecs to be sorted, got %v", types)
+		}
+	}
+	for _, want := range []Type{GobType, JsonType, ProtobufType, MsgpackType} {
+		if GetCodecFunc(want) == nil {
+			t.Fatalf("expect %s to be registered", want)
+		}
+	}
+}
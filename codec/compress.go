@@ -0,0 +1,88 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compressor 压缩和解压消息体。
+// limit 大于 0 时 Unzip 解压出的数据超过 limit 字节就停止解压并返回包装了 ErrBodyTooLarge 的错误，
+// 避免很小的压缩数据解压出巨大的消息体耗尽内存
+type Compressor interface {
+	Zip(data []byte) ([]byte, error)
+	Unzip(data []byte, limit int64) ([]byte, error)
+}
+
+// CompressionCounter 是编解码器可选实现的接口，报告经过压缩算法处理的消息体在压缩前后的累计字节数，
+// 包括读取和写入的消息体。可以与读写并发调用，没有协商压缩算法时都为 0
+type CompressionCounter interface {
+	CompressionCounts() (original, compressed uint64)
+}
+
+// CompressType 是压缩算法的名称，在 Option 握手中协商
+type CompressType string
+
+const (
+	Gzip CompressType = "gzip"
+)
+
+var (
+	compressorMu sync.RWMutex
+	compressors  = map[CompressType]Compressor{
+		Gzip: GzipCompressor{},
+	}
+)
+
+// RegisterCompressor 注册压缩算法，已存在时覆盖
+func RegisterCompressor(t CompressType, c Compressor) {
+	compressorMu.Lock()
+	defer compressorMu.Unlock()
+	compressors[t] = c
+}
+
+// GetCompressor 返回名称为 t 的压缩算法，未注册时返回 nil
+func GetCompressor(t CompressType) Compressor {
+	compressorMu.RLock()
+	defer compressorMu.RUnlock()
+	return compressors[t]
+}
+
+// GzipCompressor 使用 gzip 压缩
+type GzipCompressor struct{}
+
+var _ Compressor = GzipCompressor{}
+
+func (GzipCompressor) Zip(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (GzipCompressor) Unzip(data []byte, limit int64) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	// 多读一个字节，用于判断解压后的数据是否超过 limit
+	out, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > limit {
+		return nil, fmt.Errorf("%w: more than %d bytes after unzip", ErrBodyTooLarge, limit)
+	}
+	return out, nil
+}
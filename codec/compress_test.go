@@ -0,0 +1,75 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// bufferConn 是基于内存缓冲区的 io.ReadWriteCloser，写入的数据可以被依次读出
+type bufferConn struct {
+	bytes.Buffer
+}
+
+func (*bufferConn) Close() error { return nil }
+
+func TestGzipCompressor(t *testing.T) {
+	data := bytes.Repeat([]byte("go-rpc "), 1000)
+	zipped, err := GzipCompressor{}.Zip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zipped) >= len(data) {
+		t.Fatalf("expect compressed size below %d, got %d", len(data), len(zipped))
+	}
+	out, err := GzipCompressor{}.Unzip(zipped, 0)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Fatalf("expect round trip to return the original data, got %d bytes, %v", len(out), err)
+	}
+	if out, err = (GzipCompressor{}).Unzip(zipped, int64(len(data))); err != nil || !bytes.Equal(out, data) {
+		t.Fatalf("expect data exactly at the limit to be accepted, got %v", err)
+	}
+}
+
+func TestGzipUnzipLimit(t *testing.T) {
+	bomb, err := GzipCompressor{}.Zip(make([]byte, 16<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (GzipCompressor{}).Unzip(bomb, 1<<20); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("expect ErrBodyTooLarge, got %v", err)
+	}
+}
+
+func TestFramedCodecCompressedBodyLimit(t *testing.T) {
+	conn := &bufferConn{}
+	w := NewFramedCodec(conn, GobMarshaler{}, FramedConfig{Compressor: GzipCompressor{}})
+	if err := w.Write(&Header{ServiceMethod: "Foo.Bomb", Seq: 1}, make([]byte, 16<<20)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(&Header{ServiceMethod: "Foo.Sum", Seq: 2}, "next"); err != nil {
+		t.Fatal(err)
+	}
+	if conn.Len() > 1<<20 {
+		t.Fatalf("expect the compressed frame to be small, got %d bytes", conn.Len())
+	}
+
+	r := NewFramedCodec(conn, GobMarshaler{}, FramedConfig{MaxBodySize: 1 << 20, Compressor: GzipCompressor{}})
+	var h Header
+	if err := r.ReadHeader(&h); err != nil {
+		t.Fatal(err)
+	}
+	var body []byte
+	if err := r.ReadBody(&body); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("expect ErrBodyTooLarge for a body that unzips past the limit, got %v", err)
+	}
+	// 被拒绝的消息体不影响之后的帧
+	var next string
+	var nh Header
+	if err := r.ReadHeader(&nh); err != nil || nh.Seq != 2 {
+		t.Fatalf("expect the next header, got seq %d, %v", nh.Seq, err)
+	}
+	if err := r.ReadBody(&next); err != nil || next != "next" {
+		t.Fatalf("expect the next body, got %q, %v", next, err)
+	}
+}
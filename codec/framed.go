@@ -0,0 +1,244 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+)
+
+// 分帧格式：每条消息是一个帧，
+//
+//	| 帧长度 uint32 | header 长度 uint32 | header | body |
+//
+// 长度均为大端序，帧长度不包含自身的 4 字节。读取 header 后即可得知 body 的大小，
+// 因此可以在不分配内存的情况下跳过过大或损坏的消息体
+
+// maxHeaderSize 是 header 的长度上限，超过时认为流已损坏
+const maxHeaderSize = 1 << 20
+
+// ErrBodyTooLarge 表示消息体超过了允许的最大长度，该消息体已被跳过
+var ErrBodyTooLarge = errors.New("codec: body too large")
+
+// Marshaler 把单个值编码为独立的字节块，分帧编解码器用它编码 header 和 body
+type Marshaler interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// GobMarshaler 每次使用新的 gob 编码器，使每个字节块都携带完整的类型信息
+type GobMarshaler struct{}
+
+func (GobMarshaler) Marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(v)
+	return b.Bytes(), err
+}
+
+func (GobMarshaler) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// JsonMarshaler 使用 encoding/json 编码
+type JsonMarshaler struct{}
+
+func (JsonMarshaler) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JsonMarshaler) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// minCompressSize 是压缩消息体的最小长度，更小的消息体压缩收益不大，按原样发送
+const minCompressSize = 1024
+
+// FramedConfig 是分帧编解码器的配置
+type FramedConfig struct {
+	MaxBodySize int64      // 消息体的最大字节数，0 表示不限制
+	Compressor  Compressor // 不为 nil 时压缩较大的消息体
+}
+
+// FramedCodec 是分帧的编解码器，具体编码方式由 Marshaler 决定
+type FramedCodec struct {
+	conn       io.ReadWriteCloser
+	r          *bufio.Reader
+	buf        *bufio.Writer
+	m          Marshaler
+	cfg        FramedConfig
+	bodyLeft   int64 // 当前帧中尚未读取的 body 字节数
+	compressed bool  // 当前帧的 body 是否被压缩
+	bodySize   int   // 最近一次读取的 body 字节数（压缩后的大小）
+	writeSize  int
+
+	// 协商了压缩算法时统计的消息体字节数，小于 minCompressSize 而没有压缩的消息体两者相同
+	originalBytes   atomic.Uint64
+	compressedBytes atomic.Uint64
+}
+
+var _ Codec = (*FramedCodec)(nil)
+var _ Sizer = (*FramedCodec)(nil)
+var _ CompressionCounter = (*FramedCodec)(nil)
+
+// NewFramedCodec 创建分帧的编解码器
+func NewFramedCodec(conn io.ReadWriteCloser, m Marshaler, cfg FramedConfig) Codec {
+	return &FramedCodec{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		buf:  bufio.NewWriter(conn),
+		m:    m,
+		cfg:  cfg,
+	}
+}
+
+// NewFramedGobCodec 创建使用 gob 编码的分帧编解码器，maxBodySize 为 0 表示不限制消息体大小
+func NewFramedGobCodec(conn io.ReadWriteCloser, maxBodySize int64) Codec {
+	return NewFramedCodec(conn, GobMarshaler{}, FramedConfig{MaxBodySize: maxBodySize})
+}
+
+func (c *FramedCodec) ReadHeader(h *Header) error {
+	// 上一条消息的 body 没有被读取时先跳过，保证从帧的边界开始
+	if err := c.Discard(); err != nil {
+		return err
+	}
+	var prefix [8]byte
+	if _, err := io.ReadFull(c.r, prefix[:4]); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(c.r, prefix[4:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	frameSize := int64(binary.BigEndian.Uint32(prefix[:4]))
+	headerSize := int64(binary.BigEndian.Uint32(prefix[4:]))
+	if headerSize > maxHeaderSize || headerSize > frameSize-4 {
+		return fmt.Errorf("codec: invalid frame: frame %d bytes, header %d bytes", frameSize, headerSize)
+	}
+	data := make([]byte, headerSize)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return unexpectedEOF(err)
+	}
+	c.bodyLeft = frameSize - 4 - headerSize
+	if err := c.m.Unmarshal(data, h); err != nil {
+		return err
+	}
+	c.compressed = h.Compressed
+	return nil
+}
+
+// ReadBody 读取消息体，超过 maxBodySize 时跳过并返回 ErrBodyTooLarge
+func (c *FramedCodec) ReadBody(body interface{}) error {
+	c.bodySize = int(c.bodyLeft)
+	limit := c.cfg.MaxBodySize
+	if limit > 0 && c.bodyLeft > limit {
+		n := c.bodyLeft
+		if err := c.Discard(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %d bytes exceeds limit %d", ErrBodyTooLarge, n, limit)
+	}
+	data := make([]byte, c.bodyLeft)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return unexpectedEOF(err)
+	}
+	c.bodyLeft = 0
+	if body == nil {
+		return nil
+	}
+	if c.compressed {
+		if c.cfg.Compressor == nil {
+			return errors.New("codec: compressed body but no compressor negotiated")
+		}
+		var err error
+		if data, err = c.cfg.Compressor.Unzip(data, limit); err != nil {
+			return err
+		}
+	}
+	c.countCompression(len(data), c.bodySize)
+	return c.m.Unmarshal(data, body)
+}
+
+func (c *FramedCodec) BodySize() int  { return c.bodySize }
+func (c *FramedCodec) WriteSize() int { return c.writeSize }
+
+func (c *FramedCodec) CompressionCounts() (original, compressed uint64) {
+	return c.originalBytes.Load(), c.compressedBytes.Load()
+}
+
+// countCompression 记录一个消息体压缩前后的大小，没有协商压缩算法时什么也不做
+func (c *FramedCodec) countCompression(original, compressed int) {
+	if c.cfg.Compressor == nil {
+		return
+	}
+	c.originalBytes.Add(uint64(original))
+	c.compressedBytes.Add(uint64(compressed))
+}
+
+// Discard 跳过当前帧中剩余的消息体
+func (c *FramedCodec) Discard() error {
+	if c.bodyLeft == 0 {
+		return nil
+	}
+	_, err := io.CopyN(io.Discard, c.r, c.bodyLeft)
+	c.bodyLeft = 0
+	return unexpectedEOF(err)
+}
+
+func (c *FramedCodec) Write(h *Header, body interface{}) (err error) {
+	defer func() {
+		if ferr := c.buf.Flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+		if err != nil {
+			_ = c.Close()
+		}
+	}()
+	c.writeSize = 0
+	bb, err := c.m.Marshal(body)
+	if err != nil {
+		log.Println("rpc: framed codec error encoding body:", err)
+		return
+	}
+	h.Compressed = false
+	original := len(bb)
+	if c.cfg.Compressor != nil && len(bb) >= minCompressSize {
+		if bb, err = c.cfg.Compressor.Zip(bb); err != nil {
+			log.Println("rpc: framed codec error compressing body:", err)
+			return
+		}
+		h.Compressed = true
+	}
+	c.countCompression(original, len(bb))
+	hb, err := c.m.Marshal(h)
+	if err != nil {
+		log.Println("rpc: framed codec error encoding header:", err)
+		return
+	}
+	var prefix [8]byte
+	binary.BigEndian.PutUint32(prefix[:4], uint32(4+len(hb)+len(bb)))
+	binary.BigEndian.PutUint32(prefix[4:], uint32(len(hb)))
+	for _, b := range [][]byte{prefix[:], hb, bb} {
+		if _, err = c.buf.Write(b); err != nil {
+			return
+		}
+		c.writeSize += len(b)
+	}
+	return
+}
+
+func (c *FramedCodec) Close() error {
+	return c.conn.Close()
+}
+
+// 帧读取到一半时遇到 EOF 说明连接异常断开
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestFramedCodecSkipsBodies(t *testing.T) {
+	conn := &bufferConn{}
+	w := NewFramedGobCodec(conn, 0)
+	for seq := uint64(1); seq <= 3; seq++ {
+		if err := w.Write(&Header{ServiceMethod: "Foo.Sum", Seq: seq}, int(seq)*10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := binary.BigEndian.Uint32(conn.Bytes()[:4]); int(n)+4 > conn.Len() {
+		t.Fatalf("expect a 4-byte length prefix, got %d for %d bytes", n, conn.Len())
+	}
+
+	r := NewFramedGobCodec(conn, 0)
+	var h Header
+	if err := r.ReadHeader(&h); err != nil || h.Seq != 1 {
+		t.Fatalf("expect seq 1, got %+v, %v", h, err)
+	}
+	if err := r.Discard(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ReadHeader(&h); err != nil || h.Seq != 2 {
+		t.Fatalf("expect seq 2, got %+v, %v", h, err)
+	}
+	// 没有读取 seq 2 的消息体，ReadHeader 自动跳过
+	if err := r.ReadHeader(&h); err != nil || h.Seq != 3 {
+		t.Fatalf("expect seq 3 after skipping an unread body, got %+v, %v", h, err)
+	}
+	var body int
+	if err := r.ReadBody(&body); err != nil || body != 30 {
+		t.Fatalf("expect 30, got %d, %v", body, err)
+	}
+}
+
+func TestFramedCodecMaxBodySize(t *testing.T) {
+	conn := &bufferConn{}
+	w := NewFramedGobCodec(conn, 0)
+	_ = w.Write(&Header{Seq: 1}, make([]byte, 4<<10))
+	_ = w.Write(&Header{Seq: 2}, []byte("ok"))
+
+	r := NewFramedGobCodec(conn, 1<<10)
+	var h Header
+	if err := r.ReadHeader(&h); err != nil {
+		t.Fatal(err)
+	}
+	var body []byte
+	if err := r.ReadBody(&body); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("expect ErrBodyTooLarge, got %v", err)
+	}
+	if err := r.ReadHeader(&h); err != nil || h.Seq != 2 {
+		t.Fatalf("expect the next frame after an oversized body, got %+v, %v", h, err)
+	}
+	if err := r.ReadBody(&body); err != nil || string(body) != "ok" {
+		t.Fatalf("expect ok, got %q, %v", body, err)
+	}
+}
+
+func TestFramedCodecInvalidFrame(t *testing.T) {
+	conn := &bufferConn{}
+	var prefix [8]byte
+	binary.BigEndian.PutUint32(prefix[:4], 8)
+	binary.BigEndian.PutUint32(prefix[4:], 100) // header 比整个帧还长
+	conn.Write(prefix[:])
+	if err := NewFramedGobCodec(conn, 0).ReadHeader(new(Header)); err == nil {
+		t.Fatal("expect an invalid frame to be rejected")
+	}
+}
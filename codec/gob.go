@@ -5,6 +5,7 @@ import (
 	"encoding/gob"
 	"io"
 	"log"
+	"reflect"
 )
 
 type GobCodec struct {
@@ -12,17 +13,26 @@ type GobCodec struct {
 	buf  *bufio.Writer
 	dec  *gob.Decoder
 	enc  *gob.Encoder
+
+	r        *countingReader // 统计读取的字节数
+	w        *countingWriter // 统计写入的字节数
+	bodySize int
 }
 
 var _ Codec = (*GobCodec)(nil)
+var _ Sizer = (*GobCodec)(nil)
 
 func NewGobCodec(conn io.ReadWriteCloser) Codec {
 	buf := bufio.NewWriter(conn)
+	r := &countingReader{r: bufio.NewReader(conn)}
+	w := &countingWriter{w: buf}
 	return &GobCodec{
 		conn: conn,
 		buf:  buf,
-		dec:  gob.NewDecoder(conn),
-		enc:  gob.NewEncoder(buf),
+		dec:  gob.NewDecoder(r),
+		enc:  gob.NewEncoder(w),
+		r:    r,
+		w:    w,
 	}
 }
 
@@ -31,16 +41,30 @@ func (c *GobCodec) ReadHeader(h *Header) error {
 }
 
 func (c *GobCodec) ReadBody(body interface{}) error {
-	return c.dec.Decode(body)
+	start := c.r.n
+	err := c.dec.Decode(body)
+	c.bodySize = c.r.n - start
+	return err
+}
+
+func (c *GobCodec) BodySize() int  { return c.bodySize }
+func (c *GobCodec) WriteSize() int { return c.w.n }
+
+// Discard 跳过下一个消息体，gob 解码到零值 reflect.Value 时会丢弃该值
+func (c *GobCodec) Discard() error {
+	return c.dec.DecodeValue(reflect.Value{})
 }
 
 func (c *GobCodec) Write(h *Header, body interface{}) (err error) {
 	defer func() {
-		_ = c.buf.Flush()
+		if ferr := c.buf.Flush(); ferr != nil && err == nil {
+			err = ferr
+		}
 		if err != nil {
 			_ = c.Close()
 		}
 	}()
+	c.w.n = 0
 	if err = c.enc.Encode(h); err != nil {
 		log.Println("rpc: gob error encoding header:", err)
 		return
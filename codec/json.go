@@ -12,17 +12,23 @@ type JSONCodec struct {
 	buf  *bufio.Writer
 	dec  *json.Decoder
 	enc  *json.Encoder
+
+	w        *countingWriter // 统计写入的字节数
+	bodySize int
 }
 
 var _ Codec = (*JSONCodec)(nil)
+var _ Sizer = (*JSONCodec)(nil)
 
 func NewJSONCodec(conn io.ReadWriteCloser) Codec {
 	buf := bufio.NewWriter(conn)
+	w := &countingWriter{w: buf}
 	return &JSONCodec{
 		conn: conn,
 		buf:  buf,
 		dec:  json.NewDecoder(conn),
-		enc:  json.NewEncoder(buf),
+		enc:  json.NewEncoder(w),
+		w:    w,
 	}
 }
 
@@ -30,17 +36,34 @@ func (c *JSONCodec) ReadHeader(h *Header) error {
 	return c.dec.Decode(h)
 }
 
+// ReadBody 读取消息体。类型不匹配时 json.Decoder 仍会消费完整的值，
+// 因此解码出错后流不会错位，可以继续读取下一个 header
 func (c *JSONCodec) ReadBody(body interface{}) error {
-	return c.dec.Decode(body)
+	start := c.dec.InputOffset()
+	err := c.dec.Decode(body)
+	c.bodySize = int(c.dec.InputOffset() - start)
+	return err
+}
+
+func (c *JSONCodec) BodySize() int  { return c.bodySize }
+func (c *JSONCodec) WriteSize() int { return c.w.n }
+
+// Discard 跳过下一个消息体
+func (c *JSONCodec) Discard() error {
+	var raw json.RawMessage
+	return c.dec.Decode(&raw)
 }
 
 func (c *JSONCodec) Write(h *Header, body interface{}) (err error) {
 	defer func() {
-		_ = c.buf.Flush()
+		if ferr := c.buf.Flush(); ferr != nil && err == nil {
+			err = ferr
+		}
 		if err != nil {
 			_ = c.Close()
 		}
 	}()
+	c.w.n = 0
 	if err = c.enc.Encode(h); err != nil {
 		log.Println("rpc: json error encoding header:", err)
 		return
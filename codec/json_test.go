@@ -0,0 +1,67 @@
+package codec
+
+import (
+	"net"
+	"testing"
+)
+
+type jsonArgs struct {
+	Num1, Num2 int
+	Name       string
+}
+
+func TestJSONCodecRoundTrip(t *testing.T) {
+	client, server := net.Pipe()
+	w, r := NewJSONCodec(client), NewJSONCodec(server)
+	defer func() { _ = w.Close() }()
+	defer func() { _ = r.Close() }()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- w.Write(&Header{ServiceMethod: "Foo.Sum", Seq: 1}, jsonArgs{Num1: 1, Num2: 2, Name: "a"})
+	}()
+	var h Header
+	if err := r.ReadHeader(&h); err != nil {
+		t.Fatal(err)
+	}
+	var body jsonArgs
+	if err := r.ReadBody(&body); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if h.ServiceMethod != "Foo.Sum" || h.Seq != 1 {
+		t.Fatalf("unexpected header %+v", h)
+	}
+	if body != (jsonArgs{Num1: 1, Num2: 2, Name: "a"}) {
+		t.Fatalf("unexpected body %+v", body)
+	}
+}
+
+func TestJSONCodecBodyErrorKeepsStream(t *testing.T) {
+	client, server := net.Pipe()
+	w, r := NewJSONCodec(client), NewJSONCodec(server)
+	defer func() { _ = w.Close() }()
+	defer func() { _ = r.Close() }()
+
+	go func() {
+		_ = w.Write(&Header{ServiceMethod: "Foo.Sum", Seq: 1}, map[string]string{"Num1": "not a number"})
+		_ = w.Write(&Header{ServiceMethod: "Foo.Sum", Seq: 2}, jsonArgs{Num1: 3})
+	}()
+	var h Header
+	if err := r.ReadHeader(&h); err != nil {
+		t.Fatal(err)
+	}
+	var body jsonArgs
+	if err := r.ReadBody(&body); err == nil {
+		t.Fatal("expect a decode error for a mismatched body")
+	}
+	if err := r.ReadHeader(&h); err != nil || h.Seq != 2 {
+		t.Fatalf("expect the next header to be read after a body error, got %+v, %v", h, err)
+	}
+	body = jsonArgs{}
+	if err := r.ReadBody(&body); err != nil || body.Num1 != 3 {
+		t.Fatalf("expect the next body to be read, got %+v, %v", body, err)
+	}
+}
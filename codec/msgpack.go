@@ -0,0 +1,94 @@
+package codec
+
+import (
+	"bufio"
+	"io"
+	"log"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type MsgpackCodec struct {
+	conn io.ReadWriteCloser
+	buf  *bufio.Writer
+	dec  *msgpack.Decoder
+	enc  *msgpack.Encoder
+
+	w        *countingWriter // 统计写入的字节数
+	bodySize int
+}
+
+var _ Codec = (*MsgpackCodec)(nil)
+var _ Sizer = (*MsgpackCodec)(nil)
+
+func NewMsgpackCodec(conn io.ReadWriteCloser) Codec {
+	buf := bufio.NewWriter(conn)
+	w := &countingWriter{w: buf}
+	return &MsgpackCodec{
+		conn: conn,
+		buf:  buf,
+		dec:  msgpack.NewDecoder(bufio.NewReader(conn)),
+		enc:  msgpack.NewEncoder(w),
+		w:    w,
+	}
+}
+
+func (c *MsgpackCodec) ReadHeader(h *Header) error {
+	return c.dec.Decode(h)
+}
+
+// ReadBody 先读出完整的消息体再解码，类型不匹配时流不会错位；body 为 nil 时丢弃消息体
+func (c *MsgpackCodec) ReadBody(body interface{}) error {
+	raw, err := c.dec.DecodeRaw()
+	c.bodySize = len(raw)
+	if err != nil || body == nil {
+		return err
+	}
+	return msgpack.Unmarshal(raw, body)
+}
+
+func (c *MsgpackCodec) BodySize() int  { return c.bodySize }
+func (c *MsgpackCodec) WriteSize() int { return c.w.n }
+
+func (c *MsgpackCodec) Discard() error {
+	return c.dec.Skip()
+}
+
+func (c *MsgpackCodec) Write(h *Header, body interface{}) (err error) {
+	defer func() {
+		if ferr := c.buf.Flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+		if err != nil {
+			_ = c.Close()
+		}
+	}()
+	c.w.n = 0
+	if err = c.enc.Encode(h); err != nil {
+		log.Println("rpc: msgpack error encoding header:", err)
+		return
+	}
+	if err = c.enc.Encode(body); err != nil {
+		log.Println("rpc: msgpack error encoding body:", err)
+		return
+	}
+	return
+}
+
+func (c *MsgpackCodec) Close() error {
+	return c.conn.Close()
+}
+
+// MsgpackMarshaler 使用 MessagePack 编码
+type MsgpackMarshaler struct{}
+
+func (MsgpackMarshaler) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (MsgpackMarshaler) Unmarshal(data []byte, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	return msgpack.Unmarshal(data, v)
+}
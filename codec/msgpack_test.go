@@ -0,0 +1,46 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+)
+
+type msgpackBody struct {
+	Name  string
+	Tags  []string
+	Attrs map[string]int
+}
+
+func TestMsgpackCodecRoundTrip(t *testing.T) {
+	conn := &bufferConn{}
+	cc := NewMsgpackCodec(conn)
+	body := msgpackBody{Name: "a", Tags: []string{"x", "y"}, Attrs: map[string]int{"one": 1, "two": 2}}
+	if err := cc.Write(&Header{ServiceMethod: "Foo.Echo", Seq: 1, Meta: map[string]string{"k": "v"}}, body); err != nil {
+		t.Fatal(err)
+	}
+	// 错误响应的消息体为 nil
+	if err := cc.Write(&Header{ServiceMethod: "Foo.Echo", Seq: 2, Error: "failed"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var h Header
+	if err := cc.ReadHeader(&h); err != nil {
+		t.Fatal(err)
+	}
+	if h.ServiceMethod != "Foo.Echo" || h.Seq != 1 || h.Meta["k"] != "v" {
+		t.Fatalf("unexpected header %+v", h)
+	}
+	var got msgpackBody
+	if err := cc.ReadBody(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, body) {
+		t.Fatalf("expect %+v, got %+v", body, got)
+	}
+	if err := cc.ReadHeader(&h); err != nil || h.Seq != 2 || h.Error != "failed" {
+		t.Fatalf("expect the error response, got %+v, %v", h, err)
+	}
+	if err := cc.ReadBody(nil); err != nil {
+		t.Fatalf("expect the nil body to be discarded, got %v", err)
+	}
+}
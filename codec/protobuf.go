@@ -0,0 +1,303 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtobufCodec 使用 protobuf 编码，header 和 body 各自带 uvarint 长度前缀：
+//
+//	| header 长度 | header | body 长度 | body |
+//
+// 消息体必须实现 proto.Message；struct{} 和 nil 编码为空的消息体，用于错误响应、心跳等
+type ProtobufCodec struct {
+	conn io.ReadWriteCloser
+	r    *bufio.Reader
+	buf  *bufio.Writer
+
+	bodySize  int // 最近一次读取的消息体字节数，包括长度前缀
+	writeSize int
+}
+
+var _ Codec = (*ProtobufCodec)(nil)
+var _ Sizer = (*ProtobufCodec)(nil)
+
+// maxProtobufBlock 是单个 header 或 body 的长度上限，超过时认为流已损坏
+const maxProtobufBlock = 1 << 30
+
+func NewProtobufCodec(conn io.ReadWriteCloser) Codec {
+	return &ProtobufCodec{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		buf:  bufio.NewWriter(conn),
+	}
+}
+
+func (c *ProtobufCodec) ReadHeader(h *Header) error {
+	data, err := c.readBlock()
+	if err != nil {
+		return err
+	}
+	return unmarshalProtoHeader(data, h)
+}
+
+// ReadBody 读取消息体，body 没有实现 proto.Message 时消息体仍会被消费
+func (c *ProtobufCodec) ReadBody(body interface{}) error {
+	data, err := c.readBlock()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	c.bodySize = protowire.SizeVarint(uint64(len(data))) + len(data)
+	return ProtobufMarshaler{}.Unmarshal(data, body)
+}
+
+func (c *ProtobufCodec) BodySize() int  { return c.bodySize }
+func (c *ProtobufCodec) WriteSize() int { return c.writeSize }
+
+func (c *ProtobufCodec) Discard() error {
+	_, err := c.readBlock()
+	return unexpectedEOF(err)
+}
+
+func (c *ProtobufCodec) readBlock() ([]byte, error) {
+	n, err := binary.ReadUvarint(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxProtobufBlock {
+		return nil, fmt.Errorf("codec: protobuf block too large: %d bytes", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return data, nil
+}
+
+func (c *ProtobufCodec) Write(h *Header, body interface{}) (err error) {
+	defer func() {
+		if ferr := c.buf.Flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+		if err != nil {
+			_ = c.Close()
+		}
+	}()
+	c.writeSize = 0
+	hb, _ := ProtobufMarshaler{}.Marshal(h)
+	bb, err := ProtobufMarshaler{}.Marshal(body)
+	if err != nil {
+		log.Println("rpc: protobuf codec error encoding body:", err)
+		return
+	}
+	for _, b := range [][]byte{hb, bb} {
+		if _, err = c.buf.Write(protowire.AppendVarint(nil, uint64(len(b)))); err != nil {
+			return
+		}
+		if _, err = c.buf.Write(b); err != nil {
+			return
+		}
+		c.writeSize += protowire.SizeVarint(uint64(len(b))) + len(b)
+	}
+	return
+}
+
+func (c *ProtobufCodec) Close() error {
+	return c.conn.Close()
+}
+
+// ProtobufMarshaler 使用 protobuf 编码，也可用于分帧编解码器
+type ProtobufMarshaler struct{}
+
+var _ Marshaler = ProtobufMarshaler{}
+
+func (ProtobufMarshaler) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case *Header:
+		return marshalProtoHeader(v), nil
+	case proto.Message:
+		return proto.Marshal(v)
+	case nil, struct{}, *struct{}:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("codec: protobuf: type %T does not implement proto.Message", v)
+	}
+}
+
+func (ProtobufMarshaler) Unmarshal(data []byte, v interface{}) error {
+	switch v := v.(type) {
+	case *Header:
+		return unmarshalProtoHeader(data, v)
+	case proto.Message:
+		return proto.Unmarshal(data, v)
+	case nil, *struct{}:
+		return nil
+	default:
+		return fmt.Errorf("codec: protobuf: type %T does not implement proto.Message", v)
+	}
+}
+
+// Header 在 protobuf 中的字段编号，新增字段时追加新的编号，不能复用已有编号
+const (
+	headerServiceMethod protowire.Number = iota + 1
+	headerSeq
+	headerError
+	headerCompressed
+	headerStreamIndex
+	headerStreamEnd
+	headerDeadline
+	headerTraceID
+	headerSpanID
+	headerOneWay
+	headerMeta // 每个键值对编码为一个嵌套消息，与 protobuf 的 map 字段相同
+	headerErrorCode
+	headerNonce
+	headerTimestamp
+)
+
+// Header.Meta 中键值对的字段编号
+const (
+	metaEntryKey protowire.Number = iota + 1
+	metaEntryValue
+)
+
+// marshalProtoHeader 将 Header 编码为 protobuf 消息，零值字段不编码
+func marshalProtoHeader(h *Header) []byte {
+	var b []byte
+	appendString := func(num protowire.Number, s string) {
+		if s != "" {
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, s)
+		}
+	}
+	appendVarint := func(num protowire.Number, v uint64) {
+		if v != 0 {
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, v)
+		}
+	}
+	appendString(headerServiceMethod, h.ServiceMethod)
+	appendVarint(headerSeq, h.Seq)
+	appendString(headerError, h.Error)
+	appendVarint(headerCompressed, protowire.EncodeBool(h.Compressed))
+	appendVarint(headerStreamIndex, h.StreamIndex)
+	appendVarint(headerStreamEnd, protowire.EncodeBool(h.StreamEnd))
+	appendVarint(headerDeadline, uint64(h.Deadline))
+	appendString(headerTraceID, h.TraceID)
+	appendString(headerSpanID, h.SpanID)
+	appendVarint(headerOneWay, protowire.EncodeBool(h.OneWay))
+	for k, v := range h.Meta {
+		var entry []byte
+		entry = protowire.AppendTag(entry, metaEntryKey, protowire.BytesType)
+		entry = protowire.AppendString(entry, k)
+		entry = protowire.AppendTag(entry, metaEntryValue, protowire.BytesType)
+		entry = protowire.AppendString(entry, v)
+		b = protowire.AppendTag(b, headerMeta, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	appendVarint(headerErrorCode, uint64(h.ErrorCode))
+	appendString(headerNonce, h.Nonce)
+	appendVarint(headerTimestamp, uint64(h.Timestamp))
+	return b
+}
+
+var errInvalidProtoHeader = errors.New("codec: invalid protobuf header")
+
+// unmarshalProtoHeader 解码 Header，忽略未知字段
+func unmarshalProtoHeader(b []byte, h *Header) error {
+	*h = Header{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errInvalidProtoHeader
+		}
+		b = b[n:]
+		var v uint64
+		var s string
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			s, n = protowire.ConsumeString(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return errInvalidProtoHeader
+		}
+		b = b[n:]
+		switch num {
+		case headerServiceMethod:
+			h.ServiceMethod = s
+		case headerSeq:
+			h.Seq = v
+		case headerError:
+			h.Error = s
+		case headerCompressed:
+			h.Compressed = protowire.DecodeBool(v)
+		case headerStreamIndex:
+			h.StreamIndex = v
+		case headerStreamEnd:
+			h.StreamEnd = protowire.DecodeBool(v)
+		case headerDeadline:
+			h.Deadline = int64(v)
+		case headerTraceID:
+			h.TraceID = s
+		case headerSpanID:
+			h.SpanID = s
+		case headerOneWay:
+			h.OneWay = protowire.DecodeBool(v)
+		case headerErrorCode:
+			h.ErrorCode = int(v)
+		case headerNonce:
+			h.Nonce = s
+		case headerTimestamp:
+			h.Timestamp = int64(v)
+		case headerMeta:
+			if err := unmarshalProtoMetaEntry([]byte(s), h); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unmarshalProtoMetaEntry 解码 Meta 中的一个键值对，忽略未知字段
+func unmarshalProtoMetaEntry(b []byte, h *Header) error {
+	var k, v string
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errInvalidProtoHeader
+		}
+		b = b[n:]
+		var s string
+		if typ == protowire.BytesType {
+			s, n = protowire.ConsumeString(b)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return errInvalidProtoHeader
+		}
+		b = b[n:]
+		switch num {
+		case metaEntryKey:
+			k = s
+		case metaEntryValue:
+			v = s
+		}
+	}
+	if h.Meta == nil {
+		h.Meta = make(map[string]string)
+	}
+	h.Meta[k] = v
+	return nil
+}
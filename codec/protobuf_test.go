@@ -0,0 +1,83 @@
+package codec
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProtobufCodecRoundTrip(t *testing.T) {
+	conn := &bufferConn{}
+	cc := NewProtobufCodec(conn)
+	h := Header{
+		ServiceMethod: "Foo.Sum", Seq: 7, Error: "e", ErrorCode: 3, Compressed: true,
+		StreamIndex: 2, StreamEnd: true, Deadline: 123, TraceID: "t", SpanID: "s",
+		OneWay: true, Nonce: "n", Timestamp: 456, Meta: map[string]string{"k": "v"},
+	}
+	body, err := structpb.NewStruct(map[string]interface{}{"name": "a", "nums": []interface{}{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.Write(&h, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.Write(&Header{Seq: 8}, nil); err != nil { // 错误响应没有消息体
+		t.Fatal(err)
+	}
+
+	var got Header
+	if err := cc.ReadHeader(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, h) {
+		t.Fatalf("expect header %+v, got %+v", h, got)
+	}
+	var gotBody structpb.Struct
+	if err := cc.ReadBody(&gotBody); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(&gotBody, body) {
+		t.Fatalf("expect body %v, got %v", body, &gotBody)
+	}
+	if err := cc.ReadHeader(&got); err != nil || got.Seq != 8 {
+		t.Fatalf("expect the second header, got %+v, %v", got, err)
+	}
+	if err := cc.ReadBody(nil); err != nil {
+		t.Fatalf("expect an empty body to be skipped, got %v", err)
+	}
+}
+
+func TestProtobufCodecNonProtoBody(t *testing.T) {
+	conn := &bufferConn{}
+	cc := NewProtobufCodec(conn)
+	if err := cc.Write(&Header{Seq: 1}, struct{ Num int }{1}); err == nil || !strings.Contains(err.Error(), "does not implement proto.Message") {
+		t.Fatalf("expect a non-proto body to be rejected, got %v", err)
+	}
+	conn.Reset()
+
+	for seq := uint64(1); seq <= 2; seq++ {
+		if err := cc.Write(&Header{Seq: seq}, wrapperspb.String("hello")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var h Header
+	if err := cc.ReadHeader(&h); err != nil {
+		t.Fatal(err)
+	}
+	var num int
+	if err := cc.ReadBody(&num); err == nil || !strings.Contains(err.Error(), "does not implement proto.Message") {
+		t.Fatalf("expect decoding into a non-proto type to fail, got %v", err)
+	}
+	// 解码失败的消息体已被消费，下一条消息不受影响
+	var s wrapperspb.StringValue
+	if err := cc.ReadHeader(&h); err != nil || h.Seq != 2 {
+		t.Fatalf("expect the second header, got %+v, %v", h, err)
+	}
+	if err := cc.ReadBody(&s); err != nil || s.GetValue() != "hello" {
+		t.Fatalf("expect hello, got %q, %v", s.GetValue(), err)
+	}
+}
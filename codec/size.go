@@ -0,0 +1,45 @@
+package codec
+
+import (
+	"bufio"
+	"io"
+)
+
+// Sizer 是编解码器可选实现的接口，报告消息在连接上占用的字节数，用于统计请求和响应的大小。
+// 两个方法都只在对应的读写完成后、下一次读写开始前调用才有意义
+type Sizer interface {
+	BodySize() int  // 最近一次 ReadBody 读取的消息体字节数
+	WriteSize() int // 最近一次 Write 写入的字节数，包括 header 和分帧的长度前缀
+}
+
+// countingWriter 统计写入 w 的字节数
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += n
+	return n, err
+}
+
+// countingReader 统计从 r 读出的字节数，实现 io.ByteReader 使 gob.Decoder 不再额外包装缓冲
+type countingReader struct {
+	r *bufio.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += n
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	b, err := cr.r.ReadByte()
+	if err == nil {
+		cr.n++
+	}
+	return b, err
+}
@@ -0,0 +1,96 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"bytes"
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+// countingConn 统计写入连接的字节数
+type countingConn struct {
+	net.Conn
+	written *atomic.Int64
+}
+
+func (c countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// dialCounting 连接 addr 并统计客户端写出的字节数
+func dialCounting(t *testing.T, addr string, opt *Option) (*Client, *atomic.Int64) {
+	written := new(atomic.Int64)
+	o := *opt
+	o.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return countingConn{Conn: conn, written: written}, nil
+	}
+	return dialServer(t, addr, &o), written
+}
+
+func TestGzipCompressionRoundTrip(t *testing.T) {
+	server, addr := startServer(t)
+	_ = server.RegisterFunc("Blob.Echo", func(data []byte, reply *[]byte) error {
+		*reply = data
+		return nil
+	})
+	body := bytes.Repeat([]byte("go-rpc compression "), (1<<20)/19)
+
+	for _, compressor := range []codec.CompressType{"", codec.Gzip} {
+		client, written := dialCounting(t, addr, &Option{Compressor: compressor})
+		var reply []byte
+		if err := client.Call(context.Background(), "Blob.Echo", body, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(reply, body) {
+			t.Fatalf("compressor %q: expect the echoed body, got %d bytes", compressor, len(reply))
+		}
+		if compressor == "" && written.Load() < int64(len(body)) {
+			t.Fatalf("expect uncompressed bytes on the wire, got %d", written.Load())
+		}
+		if compressor != "" && written.Load() > int64(len(body))/10 {
+			t.Fatalf("expect the compressed request to be much smaller than %d, got %d", len(body), written.Load())
+		}
+	}
+}
+
+func TestStatsCompressionRatio(t *testing.T) {
+	server, addr := startServer(t)
+	_ = server.RegisterFunc("Blob.Echo", func(data []byte, reply *[]byte) error {
+		*reply = data
+		return nil
+	})
+	body := bytes.Repeat([]byte("go-rpc compression "), 1000)
+	for _, compressor := range []codec.CompressType{"", codec.Gzip} {
+		client := dialServer(t, addr, &Option{Compressor: compressor})
+		if err := client.Call(context.Background(), "Blob.Echo", body, new([]byte)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conns := server.Stats().Connections
+	if len(conns) != 2 {
+		t.Fatalf("expect 2 connections in stats, got %v", conns)
+	}
+	for _, cs := range conns {
+		switch cs.Compressor {
+		case codec.Gzip:
+			if cs.OriginalBytes < uint64(len(body)) || cs.CompressionRatio() >= 0.1 {
+				t.Fatalf("expect a ratio well below 1 for a compressible body, got %v (%d/%d)", cs.CompressionRatio(), cs.CompressedBytes, cs.OriginalBytes)
+			}
+		case "":
+			if cs.CompressionRatio() != 1 {
+				t.Fatalf("expect a ratio of 1 without compression, got %v", cs.CompressionRatio())
+			}
+		default:
+			t.Fatalf("unexpected compressor %q", cs.Compressor)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package Go_rpc
+
+import (
+	"context"
+	"net"
+)
+
+type remoteAddrKey struct{}
+
+// RemoteAddrFromContext 返回处理请求时客户端连接的远端地址，连接不是 net.Conn 时返回 false
+func RemoteAddrFromContext(ctx context.Context) (net.Addr, bool) {
+	addr, ok := ctx.Value(remoteAddrKey{}).(net.Addr)
+	return addr, ok
+}
+
+// SetConnContext 设置在每个连接完成握手后调用的 f，用于在连接级别的 context 中添加自定义的值，
+// 这些值对该连接上的所有请求可见。f 收到的 ctx 已携带令牌、客户端身份和远端地址，
+// nc 在连接不是 net.Conn 时为 nil；f 为 nil 表示不使用
+func (server *Server) SetConnContext(f func(ctx context.Context, nc net.Conn) context.Context) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.connCtxFunc = f
+}
+
+// connContext 返回一个连接上所有请求共用的 context，携带握手时得到的令牌、客户端身份和远端地址
+func (server *Server) connContext(nc net.Conn, opt *Option) context.Context {
+	ctx := contextWithToken(context.Background(), opt.Token)
+	if nc != nil {
+		ctx = context.WithValue(ctx, remoteAddrKey{}, nc.RemoteAddr())
+	}
+	if id, ok := peerIdentity(nc); ok {
+		ctx = context.WithValue(ctx, identityKey{}, id)
+	}
+	server.mu.Lock()
+	f := server.connCtxFunc
+	server.mu.Unlock()
+	if f != nil {
+		ctx = f(ctx, nc)
+	}
+	return ctx
+}
@@ -0,0 +1,51 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// Peer 返回连接级别 context 中的值
+type Peer struct{}
+
+type userKey struct{}
+
+func (Peer) Addr(ctx context.Context, args Args, reply *string) error {
+	addr, ok := RemoteAddrFromContext(ctx)
+	if !ok {
+		return errors.New("no remote address")
+	}
+	*reply = addr.String()
+	return nil
+}
+
+func (Peer) User(ctx context.Context, args Args, reply *string) error {
+	*reply, _ = ctx.Value(userKey{}).(string)
+	return nil
+}
+
+func TestConnContext(t *testing.T) {
+	server, addr := startServer(t, Peer{})
+	server.SetConnContext(func(ctx context.Context, nc net.Conn) context.Context {
+		return context.WithValue(ctx, userKey{}, "user-"+TokenFromContext(ctx))
+	})
+	var local string
+	opt := &Option{Token: "alice", Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err == nil {
+			local = conn.LocalAddr().String()
+		}
+		return conn, err
+	}}
+	client := dialServer(t, addr, opt)
+
+	var remote, user string
+	if err := client.Call(context.Background(), "Peer.Addr", Args{}, &remote); err != nil || remote != local {
+		t.Fatalf("expect the method to see the client address %s, got %s, %v", local, remote, err)
+	}
+	if err := client.Call(context.Background(), "Peer.User", Args{}, &user); err != nil || user != "user-alice" {
+		t.Fatalf("expect the value from SetConnContext, got %q, %v", user, err)
+	}
+}
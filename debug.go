@@ -0,0 +1,75 @@
+package Go_rpc
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const debugText = `<html>
+	<body>
+	<title>GoRPC Services</title>
+	{{range .}}
+	<hr>
+	Service {{.Name}}
+	<hr>
+		<table>
+		<th align=center>Method</th><th align=center>Calls</th>
+		{{range .Methods}}
+			<tr>
+			<td align=left font=fixed>{{.Name}}({{.ArgType}}, {{.ReplyType}}) error</td>
+			<td align=center>{{.Calls}}</td>
+			</tr>
+		{{end}}
+		</table>
+	{{end}}
+	</body>
+	</html>`
+
+var debugTemplate = template.Must(template.New("RPC debug").Parse(debugText))
+
+// debugHTTP 以 HTML 页面展示服务端已注册的服务、方法的签名和调用次数
+type debugHTTP struct {
+	*Server
+}
+
+type debugService struct {
+	Name    string
+	Methods []debugMethod
+}
+
+type debugMethod struct {
+	Name      string
+	ArgType   string
+	ReplyType string
+	Calls     uint64
+}
+
+// ServeHTTP 处理 debugPath 上的请求
+func (server debugHTTP) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var services []debugService
+	server.serviceMap.Range(func(namei, svci interface{}) bool {
+		if strings.HasPrefix(namei.(string), builtinPrefix) {
+			return true
+		}
+		svc := svci.(*service)
+		ds := debugService{Name: namei.(string)}
+		for name, m := range svc.method {
+			ds.Methods = append(ds.Methods, debugMethod{
+				Name:      name,
+				ArgType:   m.ArgType.String(),
+				ReplyType: m.ReplyType.String(),
+				Calls:     m.NumCalls(),
+			})
+		}
+		sort.Slice(ds.Methods, func(i, j int) bool { return ds.Methods[i].Name < ds.Methods[j].Name })
+		services = append(services, ds)
+		return true
+	})
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	if err := debugTemplate.Execute(w, services); err != nil {
+		_, _ = fmt.Fprintln(w, "rpc: error executing template:", err.Error())
+	}
+}
@@ -0,0 +1,31 @@
+package Go_rpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugHTTP(t *testing.T) {
+	server, addr := startServer(t, new(Arith))
+	client := dialServer(t, addr)
+	for i := 0; i < 3; i++ {
+		var reply int
+		if err := client.Call(context.Background(), "Arith.Sum", Args{Num1: i, Num2: 1}, &reply); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	debugHTTP{server}.ServeHTTP(w, httptest.NewRequest("GET", defaultDebugPath, nil))
+	page := w.Body.String()
+	for _, want := range []string{"Service Arith", "Sum(Go_rpc.Args, *int) error", "<td align=center>3</td>", "Service Foo"} {
+		if !strings.Contains(page, want) {
+			t.Fatalf("expect the debug page to contain %q, got\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, introspectService) {
+		t.Fatal("expect builtin services to be hidden from the debug page")
+	}
+}
@@ -0,0 +1,32 @@
+package Go_rpc
+
+import "Go-rpc/codec"
+
+// ServeCodecDirect 直接在已创建的编解码器上处理请求，不读取 Option 握手，阻塞直到连接关闭。
+// 用于已经完成认证和协商的传输（如进程内连接、多路复用的子流），客户端需使用 NewClientCodec 创建。
+// opts 最多一个，其中的 HandleTimeout、OrderedResponses 生效，编解码相关的字段被忽略。
+// 没有 net.Conn，因此空闲超时不生效，Shutdown 在它没有正在处理的请求时关闭编解码器
+func (server *Server) ServeCodecDirect(cc codec.Codec, opts ...*Option) {
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		server.logger().Errorf("rpc server: options error: %v", err)
+		_ = cc.Close()
+		return
+	}
+	if !server.trackConn(cc, true) { // 服务器正在关闭
+		_ = cc.Close()
+		return
+	}
+	defer server.trackConn(cc, false)
+	server.serveCodec(cc, opt, nil, 0, nil) // 结束时关闭编解码器
+}
+
+// NewClientCodec 直接在已创建的编解码器上创建客户端，不发送 Option 握手，服务端需使用 ServeCodecDirect 处理。
+// opts 最多一个，其中的 CallTimeout 等调用相关的字段生效，编解码相关的字段被忽略
+func NewClientCodec(cc codec.Codec, opts ...*Option) (*Client, error) {
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newClientCodec(cc, nil, opt), nil
+}
@@ -0,0 +1,85 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordConn 记录写入连接的所有字节
+type recordConn struct {
+	net.Conn
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	c.buf.Write(p)
+	c.mu.Unlock()
+	return c.Conn.Write(p)
+}
+
+func TestServeCodecDirect(t *testing.T) {
+	server := newTestServer(t)
+	serverConn, clientConn := net.Pipe()
+	go server.ServeCodecDirect(codec.NewGobCodec(serverConn))
+
+	rec := &recordConn{Conn: clientConn}
+	client, err := NewClientCodec(codec.NewGobCodec(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3 without the handshake, got %d, %v", reply, err)
+	}
+
+	// 连接上的第一条数据就是 gob 编码的请求头，之前没有 Option
+	rec.mu.Lock()
+	wire := bytes.NewReader(rec.buf.Bytes())
+	rec.mu.Unlock()
+	var h codec.Header
+	if err := gob.NewDecoder(wire).Decode(&h); err != nil || h.ServiceMethod != "Foo.Sum" {
+		t.Fatalf("expect the request header first on the wire, got %+v, %v", h, err)
+	}
+}
+
+func TestShutdownServeCodecDirect(t *testing.T) {
+	server := newTestServer(t)
+	serverConn, clientConn := net.Pipe()
+	served := make(chan struct{})
+	go func() {
+		server.ServeCodecDirect(codec.NewGobCodec(serverConn))
+		close(served)
+	}()
+	client, err := NewClientCodec(codec.NewGobCodec(clientConn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	call := client.Go("Foo.Sleep", Args{Num1: 50}, new(int), nil)
+	time.Sleep(10 * time.Millisecond)
+	// 编解码器没有读取超时，Shutdown 等正在处理的请求完成后关闭它，不需要 ctx 超时
+	done := make(chan error, 1)
+	go func() { done <- server.Shutdown(context.Background()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect Shutdown to return with a direct codec open")
+	}
+	if err := (<-call.Done).Error; err != nil {
+		t.Fatalf("expect the in-flight call to finish, got %v", err)
+	}
+	<-served
+}
@@ -0,0 +1,163 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"bufio"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFlushWindow = time.Millisecond // SetFlushPolicy 的 window 不大于 0 时使用的等待时间
+	flushBufferSize    = 64 << 10         // 批量刷新时缓冲的最大字节数，超出时直接写入连接
+)
+
+// flushPolicy 是响应的批量刷新策略，maxPending 为 0 表示每个响应都立即刷新
+type flushPolicy struct {
+	maxPending int
+	window     time.Duration
+}
+
+// SetFlushPolicy 设置响应的批量刷新策略：连接上缓冲的响应达到 maxPending 个，
+// 或第一个缓冲的响应已等待 window 时，一次性写入连接，减少大量小响应的系统调用。
+// 连接空闲时缓冲的响应最多等待 window 就会发送。window <= 0 时使用 1ms，
+// maxPending <= 1 表示每个响应都立即写入（默认）。对之后建立的连接生效
+func (server *Server) SetFlushPolicy(maxPending int, window time.Duration) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if maxPending <= 1 {
+		server.flush = flushPolicy{}
+		return
+	}
+	if window <= 0 {
+		window = defaultFlushWindow
+	}
+	server.flush = flushPolicy{maxPending: maxPending, window: window}
+}
+
+func (server *Server) getFlushPolicy() flushPolicy {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.flush
+}
+
+// flushWriter 缓冲编解码器写入连接的数据，由 flushCodec 在响应计数达到上限或等待超时时刷新
+type flushWriter struct {
+	rwc    io.ReadWriteCloser
+	policy flushPolicy
+
+	mu      sync.Mutex // 保护以下字段
+	w       *bufio.Writer
+	pending int         // 缓冲中完整响应的个数
+	timer   *time.Timer // 在 window 后刷新缓冲
+	armed   bool        // timer 是否在等待
+	err     error       // 第一次写入连接的错误，之后的写入都返回它
+}
+
+func newFlushWriter(rwc io.ReadWriteCloser, policy flushPolicy) *flushWriter {
+	return &flushWriter{rwc: rwc, policy: policy, w: bufio.NewWriterSize(rwc, flushBufferSize)}
+}
+
+func (f *flushWriter) Read(p []byte) (int, error) {
+	return f.rwc.Read(p)
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return 0, f.err
+	}
+	n, err := f.w.Write(p)
+	if err != nil {
+		f.err = err
+	}
+	return n, err
+}
+
+// done 在一个完整的响应写入后调用，达到 maxPending 时立即刷新，否则保证 window 内刷新
+func (f *flushWriter) done() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	if f.pending++; f.pending >= f.policy.maxPending {
+		return f.flushLocked()
+	}
+	if !f.armed {
+		f.armed = true
+		if f.timer == nil {
+			f.timer = time.AfterFunc(f.policy.window, f.timedFlush)
+		} else {
+			f.timer.Reset(f.policy.window)
+		}
+	}
+	return nil
+}
+
+// timedFlush 在 window 到期时刷新缓冲，失败时关闭连接，使读取请求的循环尽快结束
+func (f *flushWriter) timedFlush() {
+	f.mu.Lock()
+	err := f.err
+	if err == nil && f.armed {
+		err = f.flushLocked()
+	}
+	f.mu.Unlock()
+	if err != nil {
+		_ = f.rwc.Close()
+	}
+}
+
+func (f *flushWriter) flushLocked() error {
+	f.pending = 0
+	if f.armed {
+		f.armed = false
+		f.timer.Stop()
+	}
+	if err := f.w.Flush(); err != nil && f.err == nil {
+		f.err = err
+	}
+	return f.err
+}
+
+// Close 发送缓冲的响应后关闭连接
+func (f *flushWriter) Close() error {
+	f.mu.Lock()
+	if f.err == nil {
+		_ = f.flushLocked()
+	}
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+	f.mu.Unlock()
+	return f.rwc.Close()
+}
+
+// flushCodec 在每个响应写入编解码器后通知 flushWriter
+type flushCodec struct {
+	codec.Codec
+	fw *flushWriter
+}
+
+func (c *flushCodec) Write(h *codec.Header, body interface{}) error {
+	if err := c.Codec.Write(h, body); err != nil {
+		return err
+	}
+	if err := c.fw.done(); err != nil {
+		_ = c.Codec.Close()
+		return err
+	}
+	return nil
+}
+
+// newServerCodec 使用 f 在 conn 上创建编解码器，设置了批量刷新策略时缓冲写入连接的数据
+func (server *Server) newServerCodec(f codec.NewCodecFunc, conn io.ReadWriteCloser) codec.Codec {
+	policy := server.getFlushPolicy()
+	if policy.maxPending == 0 {
+		return f(conn)
+	}
+	fw := newFlushWriter(conn, policy)
+	return &flushCodec{Codec: f(fw), fw: fw}
+}
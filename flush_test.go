@@ -0,0 +1,94 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// writeCounter 统计写入的次数
+type writeCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func (*writeCounter) Close() error { return nil }
+
+func TestFlushWriterBatches(t *testing.T) {
+	conn := &writeCounter{}
+	fw := newFlushWriter(conn, flushPolicy{maxPending: 4, window: time.Hour})
+	cc := &flushCodec{Codec: codec.NewGobCodec(fw), fw: fw}
+	for seq := uint64(1); seq <= 8; seq++ {
+		if err := cc.Write(&codec.Header{ServiceMethod: "Foo.Sum", Seq: seq}, int(seq)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if conn.writes != 2 {
+		t.Fatalf("expect 8 responses to be written in 2 batches, got %d writes", conn.writes)
+	}
+	_ = cc.Write(&codec.Header{ServiceMethod: "Foo.Sum", Seq: 9}, 9)
+	if conn.writes != 2 {
+		t.Fatalf("expect the response below maxPending to stay buffered, got %d writes", conn.writes)
+	}
+	_ = cc.Close() // 关闭前发送缓冲的响应
+	if conn.writes != 3 {
+		t.Fatalf("expect Close to flush the buffered response, got %d writes", conn.writes)
+	}
+}
+
+func TestFlushPolicyIdleWindow(t *testing.T) {
+	server, addr := startServer(t)
+	server.SetFlushPolicy(100, 30*time.Millisecond)
+	client := dialServer(t, addr)
+	start := time.Now()
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, got %d, %v", reply, err)
+	}
+	// 唯一的响应达不到 maxPending，在 window 到期后发送
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > 300*time.Millisecond {
+		t.Fatalf("expect the lone response to be flushed after the 30ms window, took %v", elapsed)
+	}
+}
+
+// benchmarkFlush 在 TCP 连接上写入 b.N 个小响应，对端持续读取并丢弃；maxPending 为 0 表示每个响应立即写入
+func benchmarkFlush(b *testing.B, maxPending int) {
+	l := listenTCP(b)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	server := NewServer()
+	server.SetFlushPolicy(maxPending, time.Millisecond)
+	cc := server.newServerCodec(codec.NewGobCodec, conn)
+	defer func() { _ = cc.Close() }()
+	h := &codec.Header{ServiceMethod: "Foo.Sum"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Seq = uint64(i + 1)
+		if err := cc.Write(h, i); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFlushEachResponse(b *testing.B) { benchmarkFlush(b, 0) }
+
+func BenchmarkFlushBatched(b *testing.B) { benchmarkFlush(b, 64) }
@@ -1,3 +1,10 @@
 module Go-rpc
 
 go 1.22.4
+
+require (
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	google.golang.org/protobuf v1.36.5
+)
+
+require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
@@ -0,0 +1,209 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// 编码协商：客户端设置了 Option.FallbackCodecs 时，发送的 Option 中 CodecType 为 gob，
+// 并在 Codecs 中按优先顺序列出可接受的编码。支持协商的服务端选择第一个支持的编码，
+// 在 Option 之后回复一行 JSON 格式的 serverOption，没有可用的编码时回复握手错误帧；
+// 不支持协商的旧服务端忽略 Codecs，不回复并使用 gob
+
+// handshakeOption 是客户端发送的 Option，Codecs 只在协商时使用
+type handshakeOption struct {
+	Option
+	Codecs []codec.Type `json:",omitempty"`
+}
+
+// serverOption 是服务端对编码协商的回复
+type serverOption struct {
+	CodecType codec.Type   // 服务端选择的编码
+	Codecs    []codec.Type // 服务端支持的所有编码
+}
+
+// negotiateTimeout 是客户端等待服务端回复协商结果的时间，超过后认为是旧服务端
+const negotiateTimeout = 500 * time.Millisecond
+
+// negotiate 从 codecs 中选择第一个支持的编码写回客户端，并据此设置 opt.CodecType
+func (server *Server) negotiate(conn io.Writer, opt *Option, codecs []codec.Type) (codec.NewCodecFunc, error) {
+	var lastErr error
+	for _, t := range codecs {
+		o := *opt
+		o.CodecType = t
+		f, err := newCodecFunc(&o)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		*opt = o
+		if err := json.NewEncoder(conn).Encode(&serverOption{CodecType: t, Codecs: codec.Codecs()}); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("no supported codec in %v (server supports %v): %v", codecs, codec.Codecs(), lastErr)
+}
+
+// newNegotiatedClient 发送可接受的编码列表，根据服务端的回复选择编码并创建客户端
+func newNegotiatedClient(conn net.Conn, opt *Option) (*Client, error) {
+	var codecs []codec.Type
+	for _, t := range append([]codec.Type{opt.CodecType}, opt.FallbackCodecs...) {
+		o := *opt
+		o.CodecType = t
+		if _, err := newCodecFunc(&o); err == nil { // 只列出客户端自己支持的编码
+			codecs = append(codecs, t)
+		}
+	}
+	if len(codecs) == 0 {
+		err := fmt.Errorf("no supported codec in %v", append([]codec.Type{opt.CodecType}, opt.FallbackCodecs...))
+		getLogger().Errorf("rpc client: codec error: %v", err)
+		return nil, err
+	}
+	wire := handshakeOption{Option: *opt, Codecs: codecs}
+	wire.CodecType = codec.GobType // 旧服务端会使用 gob
+	if err := json.NewEncoder(conn).Encode(&wire); err != nil {
+		getLogger().Errorf("rpc client: options error: %v", err)
+		_ = conn.Close()
+		return nil, err
+	}
+	cconn := newClientConn(conn)
+	so, err := cconn.readServerOption(conn, negotiateTimeout)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	chosen := *opt
+	chosen.CodecType = codec.GobType
+	if so != nil {
+		chosen.CodecType = so.CodecType
+	}
+	f, err := newCodecFunc(&chosen)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return newClientCodec(f(cconn), cconn, &chosen), nil
+}
+
+// readServerOption 读取服务端的协商结果，在 timeout 内没有回复时返回 nil
+func (c *clientConn) readServerOption(nc net.Conn, timeout time.Duration) (*serverOption, error) {
+	c.once.Do(func() {}) // 握手错误帧在这里检查，之后的读取不再检查
+	_ = nc.SetReadDeadline(time.Now().Add(timeout))
+	defer func() { _ = nc.SetReadDeadline(time.Time{}) }()
+	b, err := c.r.Peek(1)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return nil, nil // 旧服务端不回复
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b[0] == handshakeErrPrefix[0] {
+		c.checkHandshake()
+		if c.err != nil {
+			return nil, c.err
+		}
+	}
+	line, err := c.r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	var so serverOption
+	if err := json.Unmarshal(line, &so); err != nil {
+		return nil, errors.New("rpc client: invalid server option: " + err.Error())
+	}
+	return &so, nil
+}
+
+// 握手失败时服务端无法使用客户端选择的编解码器，改为回复一个固定格式的错误帧后关闭连接：
+//
+//	| 0xff 0x00 | gob 编码的 codec.Header |
+//
+// Header 的 Seq 为 0，Error 为失败原因。gob 总是用单字节编码小于 128 的长度，
+// encoding/json 不会输出 0xff，分帧编码的长度前缀也不会以 0xff 开头，
+// 因此该前缀不会与任何正常响应的开头冲突
+var handshakeErrPrefix = [2]byte{0xff, 0x00}
+
+// writeHandshakeError 向连接写入握手错误帧
+func writeHandshakeError(w io.Writer, msg string) error {
+	if _, err := w.Write(handshakeErrPrefix[:]); err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(&codec.Header{Error: msg})
+}
+
+// handshakeLinger 是写入握手错误帧后等待客户端读取的最长时间
+const handshakeLinger = time.Second
+
+// rejectConn 写入握手错误帧，并在关闭连接前尽量让客户端读到它：
+// 连接中还有未读取的数据时直接关闭会发送 RST，客户端可能来不及读取错误帧，
+// 因此先关闭写方向，再丢弃客户端已发送的请求直到对方关闭或超时
+func rejectConn(conn io.ReadWriteCloser, msg string) error {
+	if err := writeHandshakeError(conn, msg); err != nil {
+		return err
+	}
+	cw, ok := conn.(interface{ CloseWrite() error })
+	if !ok {
+		return nil
+	}
+	if err := cw.CloseWrite(); err != nil {
+		return nil
+	}
+	if nc, ok := conn.(net.Conn); ok {
+		_ = nc.SetReadDeadline(time.Now().Add(handshakeLinger))
+		_, _ = io.Copy(io.Discard, io.LimitReader(nc, 1<<20))
+	}
+	return nil
+}
+
+// HandshakeError 表示服务端拒绝了客户端的 Option
+type HandshakeError struct {
+	Msg string // 服务端返回的失败原因
+}
+
+func (e *HandshakeError) Error() string {
+	return "rpc client: handshake rejected by server: " + e.Msg
+}
+
+// clientConn 在客户端读取第一个响应前检查握手错误帧
+type clientConn struct {
+	io.ReadWriteCloser
+	r    *bufio.Reader
+	once sync.Once
+	err  error // 握手错误，只在第一次读取后设置
+}
+
+func newClientConn(conn io.ReadWriteCloser) *clientConn {
+	return &clientConn{ReadWriteCloser: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *clientConn) Read(p []byte) (int, error) {
+	c.once.Do(c.checkHandshake)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(p)
+}
+
+func (c *clientConn) checkHandshake() {
+	b, err := c.r.Peek(len(handshakeErrPrefix))
+	if err != nil || b[0] != handshakeErrPrefix[0] || b[1] != handshakeErrPrefix[1] {
+		return // 读取错误留给编解码器处理
+	}
+	_, _ = c.r.Discard(len(handshakeErrPrefix))
+	var h codec.Header
+	if err := gob.NewDecoder(c.r).Decode(&h); err != nil {
+		c.err = errors.New("rpc client: invalid handshake error frame: " + err.Error())
+		return
+	}
+	c.err = &HandshakeError{Msg: h.Error}
+}
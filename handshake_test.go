@@ -0,0 +1,224 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rewriteConn 把第一次写入（客户端的 Option）中的 old 替换为 new，模拟服务端不支持的 Option
+type rewriteConn struct {
+	net.Conn
+	old, new []byte
+	written  bool
+}
+
+func (c *rewriteConn) Write(p []byte) (int, error) {
+	if c.written {
+		return c.Conn.Write(p)
+	}
+	c.written = true
+	if _, err := c.Conn.Write(bytes.Replace(p, c.old, c.new, 1)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// rewriteDialer 返回替换 Option 中 old 为 new 的 Option.Dialer
+func rewriteDialer(old, new string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &rewriteConn{Conn: conn, old: []byte(old), new: []byte(new)}, nil
+	}
+}
+
+func TestHandshakeUnknownCodec(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr, &Option{Dialer: rewriteDialer("application/gob", "application/bogus")})
+	start := time.Now()
+	err := client.Call(context.Background(), "Foo.Sum", Args{}, new(int))
+	var he *HandshakeError
+	if !errors.As(err, &he) || !strings.Contains(he.Msg, "application/bogus") {
+		t.Fatalf("expect a handshake error naming the codec, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expect the client to fail fast, took %v", elapsed)
+	}
+}
+
+func TestHandshakeOversizedOption(t *testing.T) {
+	server, addr := startServer(t)
+	logger := &captureLogger{}
+	server.SetLogger(logger)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	// 不断开的超大 JSON 字符串，服务端读到上限后放弃
+	go func() {
+		_, _ = conn.Write([]byte(`{"MagicNumber": 4082, "CodecType": "`))
+		_, _ = conn.Write(bytes.Repeat([]byte("x"), 2*maxOptionSize))
+	}()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expect the server to close the connection")
+	}
+	waitFor(t, "the oversized option to be logged", func() bool { return logger.contains("option exceeds") })
+}
+
+func TestHandshakeRequestInSameSegment(t *testing.T) {
+	_, addr := startServer(t)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Option 和第一个请求在同一次写入中发送，服务端不能丢失 JSON 解码器多读的字节
+	var buf bytes.Buffer
+	_ = json.NewEncoder(&buf).Encode(DefaultOption)
+	cc := codec.NewGobCodec(&bufferRWC{Buffer: &buf})
+	if err := cc.Write(&codec.Header{ServiceMethod: "Foo.Sum", Seq: 1}, Args{Num1: 1, Num2: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	rc := codec.NewGobCodec(conn)
+	defer func() { _ = rc.Close() }()
+	var h codec.Header
+	var reply int
+	if err := rc.ReadHeader(&h); err != nil || h.Error != "" {
+		t.Fatalf("expect a response, got %+v, %v", h, err)
+	}
+	if err := rc.ReadBody(&reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, got %d, %v", reply, err)
+	}
+}
+
+// bufferRWC 把写入的数据追加到 Buffer
+type bufferRWC struct{ *bytes.Buffer }
+
+func (bufferRWC) Close() error { return nil }
+
+func TestHandshakeNegotiation(t *testing.T) {
+	_, addr := startServer(t)
+	// dial 用 opt 连接服务端并调用 Foo.Sum，返回客户端协商后使用的编码
+	dial := func(opt *Option) codec.Type {
+		t.Helper()
+		client := dialServer(t, addr, opt)
+		var reply int
+		if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+			t.Fatalf("expect 3, got %d, %v", reply, err)
+		}
+		return client.opt.CodecType
+	}
+
+	// 服务端支持首选的编码
+	if typ := dial(&Option{CodecType: codec.MsgpackType, FallbackCodecs: []codec.Type{codec.JsonType}}); typ != codec.MsgpackType {
+		t.Fatalf("expect the preferred codec, got %s", typ)
+	}
+	// 服务端不支持首选的编码时回退到下一个
+	opt := &Option{
+		CodecType:      codec.MsgpackType,
+		FallbackCodecs: []codec.Type{codec.JsonType},
+		Dialer:         rewriteDialer(`"Codecs":["application/msgpack"`, `"Codecs":["application/bogus"`),
+	}
+	if typ := dial(opt); typ != codec.JsonType {
+		t.Fatalf("expect the fallback codec, got %s", typ)
+	}
+	// 不支持协商的旧服务端不回复，客户端超时后使用 gob
+	opt = &Option{
+		CodecType:      codec.MsgpackType,
+		FallbackCodecs: []codec.Type{codec.JsonType},
+		Dialer:         rewriteDialer(`"Codecs"`, `"Ignored"`),
+	}
+	if typ := dial(opt); typ != codec.GobType {
+		t.Fatalf("expect gob with an old server, got %s", typ)
+	}
+}
+
+func TestHandshakeNegotiationNoCommonCodec(t *testing.T) {
+	_, addr := startServer(t)
+	opt := &Option{
+		CodecType:      codec.MsgpackType,
+		FallbackCodecs: []codec.Type{codec.JsonType},
+		Dialer:         rewriteDialer(`"Codecs":["application/msgpack","application/json"]`, `"Codecs":["application/bogus"]`),
+		ConnectTimeout: time.Second,
+	}
+	_, err := Dial("tcp", addr, opt)
+	var he *HandshakeError
+	if !errors.As(err, &he) || !strings.Contains(he.Msg, "no supported codec") {
+		t.Fatalf("expect a handshake error listing the codecs, got %v", err)
+	}
+}
+
+// closedWithin 读取并丢弃服务端发送的数据，返回服务端是否在 d 内关闭了 conn
+func closedWithin(conn net.Conn, d time.Duration) bool {
+	_ = conn.SetReadDeadline(time.Now().Add(d))
+	_, err := io.Copy(io.Discard, conn)
+	return !errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	server, addr := startServer(t)
+	server.SetHandshakeTimeout(50 * time.Millisecond)
+
+	// 只发送一部分 Option 后停止
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_, _ = conn.Write([]byte(`{"MagicNumber": 4082`))
+	start := time.Now()
+	if !closedWithin(conn, time.Second) {
+		t.Fatal("expect a stalled handshake to be disconnected")
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Fatalf("expect the connection to be closed after about 50ms, took %v", elapsed)
+	}
+
+	// 握手的截止时间在握手完成后清除，空闲的连接不会被关闭
+	client := dialServer(t, addr)
+	time.Sleep(100 * time.Millisecond)
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3 after the handshake timeout, got %d, %v", reply, err)
+	}
+}
+
+func TestServerReadTimeout(t *testing.T) {
+	server, addr := startServer(t)
+	server.SetReadTimeout(50 * time.Millisecond)
+
+	// 只发送请求头，不发送请求体
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = json.NewEncoder(conn).Encode(DefaultOption)
+	_ = gob.NewEncoder(conn).Encode(&codec.Header{ServiceMethod: "Foo.Sum", Seq: 1})
+	if !closedWithin(conn, time.Second) {
+		t.Fatal("expect a stalled request body to be disconnected")
+	}
+
+	// 没有设置空闲超时时，等待请求的时间也受读取超时限制
+	idle := dialRaw(t, addr, DefaultOption)
+	var h codec.Header
+	if err := idle.ReadHeader(&h); err == nil {
+		t.Fatal("expect an idle connection to be closed")
+	}
+}
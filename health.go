@@ -0,0 +1,102 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// HealthStatus 是健康检查返回的服务状态
+type HealthStatus int
+
+const (
+	StatusUnknown    HealthStatus = iota // 查询失败时的零值
+	StatusServing                        // 正常提供服务
+	StatusNotServing                     // 不再提供服务，负载均衡器应停止向其发送请求
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case StatusServing:
+		return "SERVING"
+	case StatusNotServing:
+		return "NOT_SERVING"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+const (
+	healthService = "__health"
+
+	// HealthCheck 是健康检查的 ServiceMethod，参数为服务名（为空表示整个服务器），
+	// 返回值为 *HealthStatus，服务不存在时返回错误
+	HealthCheck = healthService + ".Check"
+)
+
+// health 是 NewServer 自动注册的内置服务，供负载均衡器和编排系统检查服务器的状态
+type health struct {
+	server *Server
+}
+
+// Check 返回服务 name 的健康状态
+func (h *health) Check(name string, reply *HealthStatus) error {
+	status, err := h.server.servingStatusOf(name)
+	*reply = status
+	return err
+}
+
+// SetServingStatus 设置服务 name 的健康状态，name 为空时设置整个服务器的状态。
+// 没有设置过状态的已注册服务为 SERVING；服务器为 NOT_SERVING 时所有服务都为 NOT_SERVING。
+// Shutdown 开始后所有服务都为 NOT_SERVING，不能再改变
+func (server *Server) SetServingStatus(name string, serving bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.servingStatus == nil {
+		server.servingStatus = make(map[string]bool)
+	}
+	server.servingStatus[name] = serving
+}
+
+// servingStatusOf 返回服务 name 的健康状态，name 为空时返回整个服务器的状态
+func (server *Server) servingStatusOf(name string) (HealthStatus, error) {
+	_, registered := server.serviceMap.Load(name)
+	if name != "" && strings.HasPrefix(name, builtinPrefix) {
+		registered = false // 内置服务不对外报告状态
+	}
+	server.mu.Lock()
+	serving, set := server.servingStatus[name]
+	all, allSet := server.servingStatus[""]
+	server.mu.Unlock()
+	if name != "" && !registered && !set {
+		return StatusUnknown, errors.New("rpc server: unknown service " + name)
+	}
+	if server.notServing.Load() || (allSet && !all) || (set && !serving) {
+		return StatusNotServing, nil
+	}
+	return StatusServing, nil
+}
+
+// SetHealthGracePeriod 设置 Shutdown 在健康状态变为 NOT_SERVING 后继续正常服务的时间，
+// 使负载均衡器有时间通过健康检查发现服务器即将关闭，之后才停止接受新连接。d <= 0 表示不等待（默认）
+func (server *Server) SetHealthGracePeriod(d time.Duration) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.healthGrace = max(d, 0)
+}
+
+func (server *Server) getHealthGracePeriod() time.Duration {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.healthGrace
+}
+
+// CheckHealth 通过 client 查询服务 name 的健康状态，name 为空时查询整个服务器
+func CheckHealth(client *Client, name string) (HealthStatus, error) {
+	var status HealthStatus
+	if err := client.Call(context.Background(), HealthCheck, name, &status); err != nil {
+		return StatusUnknown, err
+	}
+	return status, nil
+}
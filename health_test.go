@@ -0,0 +1,48 @@
+package Go_rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHealthCheck(t *testing.T) {
+	server, addr := startServer(t)
+	server.SetHealthGracePeriod(300 * time.Millisecond)
+	client := dialServer(t, addr)
+	expect := func(name string, want HealthStatus) {
+		t.Helper()
+		if status, err := CheckHealth(client, name); err != nil || status != want {
+			t.Fatalf("expect %q to be %v, got %v, %v", name, want, status, err)
+		}
+	}
+	expect("", StatusServing)
+	expect("Foo", StatusServing)
+	if _, err := CheckHealth(client, "Missing"); err == nil {
+		t.Fatal("expect an unknown service to be reported")
+	}
+	server.SetServingStatus("Foo", false)
+	expect("Foo", StatusNotServing)
+	expect("", StatusServing)
+	server.SetServingStatus("Foo", true)
+	expect("Foo", StatusServing)
+
+	// Shutdown 先将状态改为 NOT_SERVING，在宽限期内仍然应答健康检查
+	done := make(chan error, 1)
+	go func() { done <- server.Shutdown(context.Background()) }()
+	waitFor(t, "the server to report NOT_SERVING", func() bool {
+		status, err := CheckHealth(client, "")
+		return err == nil && status == StatusNotServing
+	})
+	expect("Foo", StatusNotServing)
+	server.SetServingStatus("", true)
+	expect("", StatusNotServing)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expect Shutdown to finish after the grace period")
+	}
+}
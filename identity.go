@@ -0,0 +1,42 @@
+package Go_rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+)
+
+// Identity 是通过 TLS 客户端证书确认的调用方身份
+type Identity struct {
+	CommonName  string            // 证书主题的 CN
+	DNSNames    []string          // 证书的 DNS SAN
+	URIs        []string          // 证书的 URI SAN
+	Certificate *x509.Certificate // 客户端的叶子证书
+}
+
+type identityKey struct{}
+
+// IdentityFromContext 返回客户端证书中的身份，连接没有使用 TLS 或客户端没有提供证书时 ok 为 false
+func IdentityFromContext(ctx context.Context) (id Identity, ok bool) {
+	id, ok = ctx.Value(identityKey{}).(Identity)
+	return
+}
+
+// peerIdentity 从已完成握手的 TLS 连接中取得客户端证书的身份
+func peerIdentity(nc net.Conn) (Identity, bool) {
+	tc, ok := nc.(*tls.Conn)
+	if !ok {
+		return Identity{}, false
+	}
+	certs := tc.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return Identity{}, false
+	}
+	cert := certs[0]
+	id := Identity{CommonName: cert.Subject.CommonName, DNSNames: cert.DNSNames, Certificate: cert}
+	for _, u := range cert.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+	return id, true
+}
@@ -0,0 +1,35 @@
+package Go_rpc
+
+import (
+	"context"
+	"net"
+)
+
+// inprocNetwork 是进程内连接在 Dial 中使用的网络名，只用于日志和错误信息
+const inprocNetwork = "inproc"
+
+// NewInprocTransport 返回一对同步的内存连接，写入一端的数据从另一端读出。
+// 连接支持读写截止时间，可以代替 TCP 连接用于测试或在同一进程内使用 RPC
+func NewInprocTransport() (client, server net.Conn) {
+	return net.Pipe()
+}
+
+// ServeInproc 创建一个进程内连接并在后台通过 ServeConn 服务它的一端，返回另一端供客户端使用
+func (server *Server) ServeInproc() net.Conn {
+	client, conn := NewInprocTransport()
+	go server.ServeConn(conn)
+	return client
+}
+
+// DialInproc 不经过网络直接连接到进程内的 server，请求仍经过完整的握手、编解码和分发流程
+func DialInproc(server *Server, opts ...*Option) (*Client, error) {
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	o := *opt
+	o.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return server.ServeInproc(), nil
+	}
+	return Dial(inprocNetwork, inprocNetwork, &o)
+}
@@ -0,0 +1,50 @@
+package Go_rpc
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDialInproc(t *testing.T) {
+	server := newTestServer(t)
+	client, err := DialInproc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3 in-process, got %d, %v", reply, err)
+	}
+	if err := client.Call(context.Background(), "Foo.Div", Args{Num1: 1}, &reply); err == nil {
+		t.Fatal("expect the method error in-process")
+	}
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// benchmarkSum 在 client 上依次调用 Foo.Sum
+func benchmarkSum(b *testing.B, client *Client) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: i, Num2: 1}, new(int)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInproc(b *testing.B) {
+	client, err := DialInproc(newTestServer(b))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	benchmarkSum(b, client)
+}
+
+func BenchmarkLoopbackTCP(b *testing.B) {
+	_, addr := startServer(b)
+	benchmarkSum(b, dialServer(b, addr))
+}
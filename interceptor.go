@@ -0,0 +1,74 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"context"
+)
+
+// Handler 处理一次请求，返回待发送的响应体
+type Handler func(ctx context.Context, h *codec.Header, argv interface{}) (reply interface{}, err error)
+
+// Interceptor 是服务端拦截器，在方法调用前后执行通用逻辑（鉴权、日志、监控等）。
+// 调用 next 继续处理请求；不调用 next 而直接返回错误时，方法不会被调用，错误作为响应返回给客户端
+type Interceptor func(ctx context.Context, h *codec.Header, argv interface{}, next Handler) (reply interface{}, err error)
+
+// Use 添加服务端拦截器。
+// 拦截器按添加的顺序由外向内执行，返回时顺序相反
+func (server *Server) Use(interceptors ...Interceptor) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.interceptors = append(server.interceptors, interceptors...)
+}
+
+// invoke 经过拦截器链调用 req 对应的方法
+func (server *Server) invoke(ctx context.Context, req *request) (interface{}, error) {
+	handler := func(ctx context.Context, h *codec.Header, argv interface{}) (interface{}, error) {
+		if err := req.svc.call(ctx, req.mtype, req.argv, req.replyv); err != nil {
+			return nil, err
+		}
+		if req.mtype.stream { // 流式方法的消息已通过 ServerStream 发送
+			return nil, nil
+		}
+		return req.replyv.Interface(), nil
+	}
+	server.mu.Lock()
+	interceptors := server.interceptors
+	server.mu.Unlock()
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, h *codec.Header, argv interface{}) (interface{}, error) {
+			return interceptor(ctx, h, argv, next)
+		}
+	}
+	return handler(ctx, req.h, req.argv.Interface())
+}
+
+// Invoker 执行一次客户端调用
+type Invoker func(ctx context.Context, serviceMethod string, args, reply interface{}) error
+
+// ClientInterceptor 是客户端拦截器，包裹 Client.Call，可用于重试、注入请求 ID、统计等。
+// 调用 next 继续发起调用，最内层的 next 会真正发送请求并等待响应
+type ClientInterceptor func(ctx context.Context, serviceMethod string, args, reply interface{}, next Invoker) error
+
+// Use 添加客户端拦截器。
+// 与服务端相同，拦截器按添加的顺序由外向内执行：先添加的拦截器最先看到调用，
+// 其对 ctx 的修改（如设置超时）对之后的拦截器和真正的调用都生效
+func (client *Client) Use(interceptors ...ClientInterceptor) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.interceptors = append(client.interceptors, interceptors...)
+}
+
+// invoke 经过拦截器链执行 invoker
+func (client *Client) invoke(ctx context.Context, serviceMethod string, args, reply interface{}, invoker Invoker) error {
+	client.mu.Lock()
+	interceptors := client.interceptors
+	client.mu.Unlock()
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+			return interceptor(ctx, serviceMethod, args, reply, next)
+		}
+	}
+	return invoker(ctx, serviceMethod, args, reply)
+}
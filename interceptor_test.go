@@ -0,0 +1,114 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServerInterceptors(t *testing.T) {
+	server, addr := startServer(t)
+	var calls atomic.Int32
+	var mu sync.Mutex
+	var order []string
+	trace := func(name string) Interceptor {
+		return func(ctx context.Context, h *codec.Header, argv interface{}, next Handler) (interface{}, error) {
+			mu.Lock()
+			order = append(order, name+" in")
+			mu.Unlock()
+			reply, err := next(ctx, h, argv)
+			mu.Lock()
+			order = append(order, name+" out")
+			mu.Unlock()
+			return reply, err
+		}
+	}
+	server.Use(func(ctx context.Context, h *codec.Header, argv interface{}, next Handler) (interface{}, error) {
+		calls.Add(1)
+		if h.ServiceMethod == "Foo.Div" {
+			return nil, errors.New("Foo.Div is forbidden")
+		}
+		return next(ctx, h, argv)
+	}, trace("a"), trace("b"))
+	client := dialServer(t, addr)
+
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, got %d, %v", reply, err)
+	}
+	if err := client.Call(context.Background(), "Foo.Div", Args{Num1: 4, Num2: 2}, &reply); err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Fatalf("expect the interceptor error, got %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expect the interceptor to count 2 calls, got %d", n)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(order, ", "); got != "a in, b in, b out, a out" {
+		t.Fatalf("expect interceptors to run in order and unwind in reverse, got %s", got)
+	}
+}
+
+func TestClientInterceptors(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr)
+	var latency time.Duration
+	var sawDeadline bool
+	client.Use(func(ctx context.Context, serviceMethod string, args, reply interface{}, next Invoker) error {
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		return next(ctx, serviceMethod, args, reply)
+	}, func(ctx context.Context, serviceMethod string, args, reply interface{}, next Invoker) error {
+		_, sawDeadline = ctx.Deadline() // 外层拦截器设置的超时对内层可见
+		start := time.Now()
+		err := next(ctx, serviceMethod, args, reply)
+		latency = time.Since(start)
+		return err
+	})
+
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect the real call to return 3, got %d, %v", reply, err)
+	}
+	if !sawDeadline || latency <= 0 {
+		t.Fatalf("expect both interceptors to run, deadline %v, latency %v", sawDeadline, latency)
+	}
+	if err := client.Call(context.Background(), "Foo.Sleep", Args{Num1: 300}, &reply); !errors.Is(err, context.DeadlineExceeded) && !isTimeout(err) {
+		t.Fatalf("expect the interceptor deadline to end the call, got %v", err)
+	}
+}
+
+// Idempotency 返回请求元数据中的 idempotency-key
+type Idempotency struct{}
+
+func (Idempotency) Key(ctx context.Context, args Args, reply *string) error {
+	meta := MetaFromContext(ctx)
+	if meta == nil {
+		*reply = "<nil>"
+		return nil
+	}
+	*reply = meta["idempotency-key"]
+	return nil
+}
+
+func TestClientInterceptorMeta(t *testing.T) {
+	_, addr := startServer(t, Idempotency{})
+	for _, typ := range []codec.Type{codec.GobType, codec.JsonType} {
+		client := dialServer(t, addr, &Option{MagicNumber: MagicNumber, CodecType: typ})
+		var key string
+		if err := client.Call(context.Background(), "Idempotency.Key", Args{}, &key); err != nil || key != "<nil>" {
+			t.Fatalf("%s: expect no metadata by default, got %q, %v", typ, key, err)
+		}
+		client.Use(func(ctx context.Context, serviceMethod string, args, reply interface{}, next Invoker) error {
+			return next(ContextWithMeta(ctx, "idempotency-key", "req-1"), serviceMethod, args, reply)
+		})
+		if err := client.Call(context.Background(), "Idempotency.Key", Args{}, &key); err != nil || key != "req-1" {
+			t.Fatalf("%s: expect the method to read the key set by the interceptor, got %q, %v", typ, key, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package Go_rpc
+
+import (
+	"sort"
+	"strings"
+)
+
+// 内置服务的名称以双下划线开头，不会出现在服务列表和调试页面中
+const (
+	builtinPrefix     = "__"
+	introspectService = "__registry"
+
+	// ListMethods 是列出已注册方法的 ServiceMethod，参数为服务名（为空表示所有服务），
+	// 返回值为 *[]MethodInfo
+	ListMethods = introspectService + ".List"
+)
+
+// MethodInfo 描述一个已注册的方法
+type MethodInfo struct {
+	Name      string // "Service.Method"
+	ArgType   string // 参数类型名
+	ReplyType string // 返回值类型名，流式方法为 ServerStream
+	Stream    bool   // 是否为流式方法
+	Calls     uint64 // 方法被调用的次数
+}
+
+// introspection 是 NewServer 自动注册的内置服务，客户端可以通过普通调用查询服务端暴露的方法
+type introspection struct {
+	server *Server
+}
+
+// List 返回所有已注册的方法，按名称排序。name 不为空时只返回该服务的方法
+func (i *introspection) List(name string, reply *[]MethodInfo) error {
+	*reply = i.server.methods(name)
+	return nil
+}
+
+// methods 返回已注册的方法，serviceName 为空时返回所有服务的方法，不包含内置服务
+func (server *Server) methods(serviceName string) []MethodInfo {
+	infos := []MethodInfo{}
+	server.serviceMap.Range(func(namei, svci interface{}) bool {
+		name := namei.(string)
+		if strings.HasPrefix(name, builtinPrefix) || (serviceName != "" && name != serviceName) {
+			return true
+		}
+		for methodName, m := range svci.(*service).method {
+			infos = append(infos, MethodInfo{
+				Name:      name + "." + methodName,
+				ArgType:   m.ArgType.String(),
+				ReplyType: m.ReplyType.String(),
+				Stream:    m.stream,
+				Calls:     m.NumCalls(),
+			})
+		}
+		return true
+	})
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
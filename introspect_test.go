@@ -0,0 +1,44 @@
+package Go_rpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type Arith int
+
+func (a Arith) Sum(args Args, reply *int) error {
+	*reply = args.Num1 + args.Num2
+	return nil
+}
+
+func TestListMethods(t *testing.T) {
+	_, addr := startServer(t, new(Arith))
+	client := dialServer(t, addr)
+	var reply int
+	if err := client.Call(context.Background(), "Arith.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+
+	var methods []MethodInfo
+	if err := client.Call(context.Background(), ListMethods, "", &methods); err != nil {
+		t.Fatal(err)
+	}
+	var sum *MethodInfo
+	for i, m := range methods {
+		if strings.HasPrefix(m.Name, builtinPrefix) {
+			t.Fatalf("expect builtin services to be hidden, got %s", m.Name)
+		}
+		if m.Name == "Arith.Sum" {
+			sum = &methods[i]
+		}
+	}
+	if sum == nil || sum.ArgType != "Go_rpc.Args" || sum.ReplyType != "*int" || sum.Calls != 1 {
+		t.Fatalf("expect Arith.Sum(Go_rpc.Args, *int) called once, got %+v", sum)
+	}
+
+	if err := client.Call(context.Background(), ListMethods, "Arith", &methods); err != nil || len(methods) != 1 {
+		t.Fatalf("expect only the methods of Arith, got %v, %v", methods, err)
+	}
+}
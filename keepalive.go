@@ -0,0 +1,49 @@
+package Go_rpc
+
+import (
+	"context"
+	"time"
+)
+
+// 心跳使用保留的 ServiceMethod，由服务端直接回复，不会分发给注册的服务
+const (
+	pingMethod = "__ping"
+	pongMethod = "__pong"
+)
+
+// Ping 发送一次心跳并等待服务端回复，ctx 结束时返回 ctx.Err()。
+// 心跳不经过客户端拦截器
+func (client *Client) Ping(ctx context.Context) error {
+	return client.call(ctx, pingMethod, invalidRequest, nil)
+}
+
+// StartKeepAlive 启动后台心跳：每隔 interval 发送一次心跳，
+// 在下一次心跳之前没有收到回复时关闭客户端。
+// 客户端关闭后心跳自动停止，重复调用不会启动多个心跳
+func (client *Client) StartKeepAlive(interval time.Duration) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.keepAlive || interval <= 0 {
+		return
+	}
+	client.keepAlive = true
+	go client.keepAliveLoop(interval)
+}
+
+func (client *Client) keepAliveLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !client.IsAvailable() {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := client.Ping(ctx)
+		cancel()
+		if err != nil {
+			getLogger().Errorf("rpc client: keepalive failed, closing: %v", err)
+			_ = client.Close()
+			return
+		}
+	}
+}
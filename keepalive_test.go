@@ -0,0 +1,30 @@
+package Go_rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestKeepAliveKeepsIdleConnection(t *testing.T) {
+	server, addr := startServer(t)
+	server.SetIdleTimeout(150 * time.Millisecond)
+	client := dialServer(t, addr)
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	client.StartKeepAlive(50 * time.Millisecond)
+	time.Sleep(400 * time.Millisecond)
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect pings to keep the connection alive, got %d, %v", reply, err)
+	}
+	// 心跳不计入请求数
+	waitFor(t, "only the call to be counted", func() bool { return server.Stats().TotalRequests == 1 })
+}
+
+func TestKeepAliveClosesUnresponsiveServer(t *testing.T) {
+	client := dialServer(t, silentListener(t))
+	client.StartKeepAlive(50 * time.Millisecond)
+	waitFor(t, "the client to be closed", func() bool { return !client.IsAvailable() })
+}
@@ -0,0 +1,70 @@
+package Go_rpc
+
+import (
+	"Go-rpc/registry"
+	"log"
+	"sync/atomic"
+)
+
+// Logger 是框架内部使用的日志接口，可以通过 SetLogger 接入应用自己的日志系统
+type Logger interface {
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+// stdLogger 是基于标准库 log 的默认实现，不输出 Debug 级别的日志
+type stdLogger struct{}
+
+func (stdLogger) Debugf(format string, v ...interface{}) {}
+
+func (stdLogger) Infof(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+func (stdLogger) Errorf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+// loggerHolder 使不同的 Logger 实现可以存入同一个 atomic.Value
+type loggerHolder struct {
+	Logger
+}
+
+var defaultLogger atomic.Value
+
+func init() {
+	defaultLogger.Store(loggerHolder{stdLogger{}})
+}
+
+// SetLogger 设置包级别的默认日志，客户端、xclient、注册中心和未单独设置日志的服务端都使用它
+func SetLogger(l Logger) {
+	if l == nil {
+		l = stdLogger{}
+	}
+	defaultLogger.Store(loggerHolder{l})
+	registry.SetLogger(l)
+}
+
+// getLogger 返回包级别的默认日志
+func getLogger() Logger {
+	return defaultLogger.Load().(loggerHolder).Logger
+}
+
+// DefaultLogger 返回包级别的默认日志，供 xclient 等子包使用
+func DefaultLogger() Logger {
+	return getLogger()
+}
+
+// SetLogger 设置该服务端使用的日志，l 为 nil 时恢复使用包级别的默认日志
+func (server *Server) SetLogger(l Logger) {
+	server.log.Store(loggerHolder{l})
+}
+
+// logger 返回该服务端使用的日志
+func (server *Server) logger() Logger {
+	if h, ok := server.log.Load().(loggerHolder); ok && h.Logger != nil {
+		return h.Logger
+	}
+	return getLogger()
+}
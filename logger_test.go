@@ -0,0 +1,131 @@
+package Go_rpc
+
+import (
+	"Go-rpc/registry"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureLogger 记录所有日志，用于断言
+type captureLogger struct {
+	mu     sync.Mutex
+	errors []string
+	infos  []string
+}
+
+func (l *captureLogger) Debugf(format string, v ...interface{}) {}
+
+func (l *captureLogger) Infof(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.infos = append(l.infos, fmt.Sprintf(format, v...))
+}
+
+func (l *captureLogger) Errorf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, fmt.Sprintf(format, v...))
+}
+
+// contains 返回是否有包含 s 的错误日志
+func (l *captureLogger) contains(s string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.errors {
+		if strings.Contains(e, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestServerLoggerInvalidMagicNumber(t *testing.T) {
+	server, addr := startServer(t)
+	logger := &captureLogger{}
+	server.SetLogger(logger)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = json.NewEncoder(conn).Encode(&Option{MagicNumber: 0x1234})
+	_, _ = conn.Read(make([]byte, 1)) // 服务端拒绝后关闭连接
+	waitFor(t, "the invalid magic number to be logged", func() bool { return logger.contains("invalid magic number 1234") })
+}
+
+func TestSetLogger(t *testing.T) {
+	logger := &captureLogger{}
+	SetLogger(logger)
+	defer SetLogger(nil)
+	if getLogger() != logger {
+		t.Fatal("expect the package logger to be replaced")
+	}
+	// 服务端没有单独设置日志时使用包级别的日志
+	server := NewServer()
+	if server.logger() != logger {
+		t.Fatal("expect the server to fall back to the package logger")
+	}
+	own := &captureLogger{}
+	server.SetLogger(own)
+	if server.logger() != own {
+		t.Fatal("expect the server logger to take precedence")
+	}
+	server.SetLogger(nil)
+	if server.logger() != logger {
+		t.Fatal("expect a nil server logger to restore the package logger")
+	}
+}
+
+func TestSetLoggerRegistry(t *testing.T) {
+	logger := &captureLogger{}
+	SetLogger(logger)
+	defer SetLogger(nil)
+	// 注册中心的辅助函数同样使用包级别的日志
+	_ = registry.Deregister("http://127.0.0.1:0/_gorpc_/registry", "tcp@127.0.0.1:1")
+	if !logger.contains("rpc server: deregister err") {
+		t.Fatalf("expect the registry error to reach the package logger, got %q", logger.errors)
+	}
+}
+
+func TestServerDisconnectNotLogged(t *testing.T) {
+	server, addr := startServer(t)
+	logger := &captureLogger{}
+	server.SetLogger(logger)
+
+	// 调用进行中客户端重置连接，服务端发送响应时写入失败
+	client := dialServer(t, addr, &Option{Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err == nil {
+			_ = conn.(*net.TCPConn).SetLinger(0) // 关闭时发送 RST
+		}
+		return conn, err
+	}})
+	call := client.Go("Foo.Sleep", Args{Num1: 50}, new(int), nil)
+	time.Sleep(10 * time.Millisecond)
+	_ = client.Close()
+	<-call.Done
+
+	// 请求头只写了一半时客户端断开
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = json.NewEncoder(conn).Encode(DefaultOption)
+	_, _ = conn.Write([]byte{0x40, 0xff})
+	_ = conn.Close()
+
+	waitFor(t, "both requests to finish", func() bool { return server.Stats().TotalRequests == 1 })
+	waitFor(t, "both connections to close", func() bool { return len(server.Stats().Connections) == 0 })
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	if len(logger.errors) != 0 {
+		t.Fatalf("expect no error logs for client disconnects, got %q", logger.errors)
+	}
+}
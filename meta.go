@@ -0,0 +1,24 @@
+package Go_rpc
+
+import "context"
+
+type metaKey struct{}
+
+// ContextWithMeta 返回在 ctx 已有的元数据上添加 key=value 的 ctx，原 ctx 的元数据不受影响。
+// 客户端（包括客户端拦截器）使用该 ctx 发起调用时，元数据写入请求头的 Meta，
+// 服务端处理请求时可以通过 MetaFromContext 取得，服务端拦截器也可以直接读写 codec.Header.Meta
+func ContextWithMeta(ctx context.Context, key, value string) context.Context {
+	old := MetaFromContext(ctx)
+	meta := make(map[string]string, len(old)+1)
+	for k, v := range old {
+		meta[k] = v
+	}
+	meta[key] = value
+	return context.WithValue(ctx, metaKey{}, meta)
+}
+
+// MetaFromContext 返回 ctx 携带的元数据，未设置时返回 nil。返回的 map 不能修改
+func MetaFromContext(ctx context.Context) map[string]string {
+	meta, _ := ctx.Value(metaKey{}).(map[string]string)
+	return meta
+}
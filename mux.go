@@ -0,0 +1,469 @@
+package Go_rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Session 的帧格式：1 字节类型 + 4 字节流 ID + 4 字节长度，之后是长度为 length 的数据。
+// 窗口更新帧没有数据，length 表示对端可以继续发送的字节数
+const (
+	muxFrameOpen   byte = iota // 打开一个新的流
+	muxFrameData               // 流上的数据
+	muxFrameWindow             // 增加对端的发送窗口
+	muxFrameClose              // 关闭流，之后双方都不能再读写
+)
+
+const (
+	muxHeaderSize   = 9
+	muxMaxFrameSize = 16 << 10  // 一个数据帧的最大长度
+	muxWindowSize   = 256 << 10 // 每个流的接收窗口，对端最多发送这么多未被读取的数据
+	muxAcceptQueue  = 64        // 等待 Accept 的流的数量，超出时新打开的流被直接关闭
+)
+
+// errSessionClosed 包装 net.ErrClosed，使流上的读写错误被归为 ErrTransport
+var errSessionClosed = fmt.Errorf("rpc mux: session is closed: %w", net.ErrClosed)
+
+// Session 在一个连接上复用多个相互独立的逻辑流，每个流都是一个 net.Conn，
+// 可以各自完成 Option 握手并承载一个独立的客户端（各自的 Seq 空间）。
+// 每个流有单独的接收窗口，一个流上的数据没有被读取不会阻塞其他流，
+// 一个流出错或关闭也不影响其他流。连接的两端各创建一个 Session，isClient 必须一端为 true、另一端为 false
+type Session struct {
+	conn io.ReadWriteCloser
+
+	writeMu sync.Mutex // 保证帧完整写入
+
+	mu      sync.Mutex // 保护以下字段
+	streams map[uint32]*muxStream
+	nextID  uint32 // 客户端使用奇数 ID，服务端使用偶数 ID，双方打开的流不会冲突
+	err     error  // 会话结束的原因，不为 nil 时会话已关闭
+
+	accept chan *muxStream
+	done   chan struct{} // 会话结束时关闭
+}
+
+// NewSession 在 conn 上创建会话并在后台读取帧
+func NewSession(conn io.ReadWriteCloser, isClient bool) *Session {
+	s := &Session{
+		conn:    conn,
+		streams: make(map[uint32]*muxStream),
+		nextID:  2,
+		accept:  make(chan *muxStream, muxAcceptQueue),
+		done:    make(chan struct{}),
+	}
+	if isClient {
+		s.nextID = 1
+	}
+	go s.readLoop()
+	return s
+}
+
+// Open 打开一个新的流
+func (s *Session) Open() (net.Conn, error) {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return nil, s.err
+	}
+	id := s.nextID
+	s.nextID += 2
+	st := newMuxStream(s, id)
+	s.streams[id] = st
+	s.mu.Unlock()
+	if err := s.writeFrame(muxFrameOpen, id, 0, nil); err != nil {
+		s.remove(id)
+		return nil, err
+	}
+	return st, nil
+}
+
+// Accept 等待并返回对端打开的下一个流，会话关闭时返回错误
+func (s *Session) Accept() (net.Conn, error) {
+	select {
+	case st := <-s.accept:
+		return st, nil
+	case <-s.done:
+		return nil, s.closeErr()
+	}
+}
+
+// Dial 打开一个新的流并在其上创建客户端，与 Dial 相同地完成 Option 握手
+func (s *Session) Dial(opts ...*Option) (*Client, error) {
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	o := *opt
+	o.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return s.Open()
+	}
+	return Dial(muxNetwork, muxNetwork, &o)
+}
+
+// Close 关闭会话和底层连接，所有流随之关闭
+func (s *Session) Close() error {
+	s.shutdown(errSessionClosed)
+	return s.conn.Close()
+}
+
+func (s *Session) closeErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// shutdown 记录会话结束的原因并唤醒所有流，只有第一次调用生效
+func (s *Session) shutdown(err error) {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.err = err
+	streams := s.streams
+	s.streams = make(map[uint32]*muxStream)
+	close(s.done)
+	s.mu.Unlock()
+	for _, st := range streams {
+		st.wake()
+	}
+}
+
+func (s *Session) remove(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.streams, id)
+}
+
+func (s *Session) stream(id uint32) *muxStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams[id]
+}
+
+func (s *Session) writeFrame(typ byte, id, length uint32, data []byte) error {
+	frame := make([]byte, muxHeaderSize+len(data))
+	frame[0] = typ
+	binary.BigEndian.PutUint32(frame[1:5], id)
+	binary.BigEndian.PutUint32(frame[5:9], length)
+	copy(frame[muxHeaderSize:], data)
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	select {
+	case <-s.done:
+		return s.closeErr()
+	default:
+	}
+	if _, err := s.conn.Write(frame); err != nil {
+		s.shutdown(err)
+		_ = s.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// readLoop 读取帧并交给对应的流，连接出错或对端违反协议时结束会话
+func (s *Session) readLoop() {
+	err := s.readFrames()
+	if err == io.EOF {
+		err = errSessionClosed
+	}
+	s.shutdown(err)
+	_ = s.conn.Close()
+}
+
+func (s *Session) readFrames() error {
+	var header [muxHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(s.conn, header[:]); err != nil {
+			return err
+		}
+		typ, id, length := header[0], binary.BigEndian.Uint32(header[1:5]), binary.BigEndian.Uint32(header[5:9])
+		switch typ {
+		case muxFrameOpen:
+			st := newMuxStream(s, id)
+			s.mu.Lock()
+			_, dup := s.streams[id]
+			if !dup {
+				s.streams[id] = st
+			}
+			s.mu.Unlock()
+			if dup {
+				return fmt.Errorf("rpc mux: duplicate stream %d", id)
+			}
+			select {
+			case s.accept <- st:
+			default: // 没有及时 Accept，拒绝该流而不阻塞其他流
+				_ = st.Close()
+			}
+		case muxFrameData:
+			if length > muxMaxFrameSize {
+				return fmt.Errorf("rpc mux: frame of %d bytes exceeds %d", length, muxMaxFrameSize)
+			}
+			data := make([]byte, length)
+			if _, err := io.ReadFull(s.conn, data); err != nil {
+				return err
+			}
+			if st := s.stream(id); st != nil { // 已在本端关闭的流直接丢弃数据
+				if err := st.push(data); err != nil {
+					return err
+				}
+			}
+		case muxFrameWindow:
+			if st := s.stream(id); st != nil {
+				st.addWindow(length)
+			}
+		case muxFrameClose:
+			if st := s.stream(id); st != nil {
+				s.remove(id)
+				st.remoteClose()
+			}
+		default:
+			return fmt.Errorf("rpc mux: unknown frame type %d", typ)
+		}
+	}
+}
+
+// muxNetwork 是会话中的流在 Dial 和地址中使用的网络名
+const muxNetwork = "mux"
+
+type muxAddr struct{}
+
+func (muxAddr) Network() string { return muxNetwork }
+func (muxAddr) String() string  { return muxNetwork }
+
+// muxStream 是 Session 中的一个流
+type muxStream struct {
+	sess *Session
+	id   uint32
+
+	mu            sync.Mutex // 保护以下字段
+	buf           bytes.Buffer
+	unacked       uint32 // 已读取但还没有通知对端的字节数
+	sendWindow    uint32 // 还可以发送的字节数
+	closed        bool   // 本端已关闭
+	remoteClosed  bool   // 对端已关闭
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	readable chan struct{} // 有新数据、关闭或读取截止时间变化
+	writable chan struct{} // 发送窗口增加、关闭或写入截止时间变化
+}
+
+func newMuxStream(sess *Session, id uint32) *muxStream {
+	return &muxStream{
+		sess:       sess,
+		id:         id,
+		sendWindow: muxWindowSize,
+		readable:   make(chan struct{}, 1),
+		writable:   make(chan struct{}, 1),
+	}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func (st *muxStream) wake() {
+	signal(st.readable)
+	signal(st.writable)
+}
+
+// wait 等待 ch 的通知，截止时间到达时返回 os.ErrDeadlineExceeded
+func (st *muxStream) wait(ch chan struct{}, deadline time.Time) error {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ch:
+	case <-st.sess.done:
+	case <-timeout:
+		return os.ErrDeadlineExceeded
+	}
+	return nil
+}
+
+func (st *muxStream) push(data []byte) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.buf.Len()+len(data) > muxWindowSize {
+		return fmt.Errorf("rpc mux: stream %d exceeds its receive window", st.id)
+	}
+	st.buf.Write(data)
+	signal(st.readable)
+	return nil
+}
+
+func (st *muxStream) addWindow(n uint32) {
+	st.mu.Lock()
+	st.sendWindow += n
+	st.mu.Unlock()
+	signal(st.writable)
+}
+
+func (st *muxStream) remoteClose() {
+	st.mu.Lock()
+	st.remoteClosed = true
+	st.mu.Unlock()
+	st.wake()
+}
+
+func (st *muxStream) Read(p []byte) (int, error) {
+	for {
+		st.mu.Lock()
+		if st.closed {
+			st.mu.Unlock()
+			return 0, net.ErrClosed
+		}
+		if st.buf.Len() > 0 {
+			n, _ := st.buf.Read(p)
+			// 读取了一半窗口后才通知对端，避免每次读取都发送窗口更新帧
+			st.unacked += uint32(n)
+			var update uint32
+			if st.unacked >= muxWindowSize/2 {
+				update, st.unacked = st.unacked, 0
+			}
+			st.mu.Unlock()
+			if update > 0 {
+				_ = st.sess.writeFrame(muxFrameWindow, st.id, update, nil)
+			}
+			return n, nil
+		}
+		if st.remoteClosed || st.sess.closeErr() != nil {
+			st.mu.Unlock()
+			return 0, io.EOF
+		}
+		deadline := st.readDeadline
+		st.mu.Unlock()
+		if err := st.wait(st.readable, deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (st *muxStream) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		st.mu.Lock()
+		switch {
+		case st.closed:
+			st.mu.Unlock()
+			return written, net.ErrClosed
+		case st.remoteClosed:
+			st.mu.Unlock()
+			return written, io.ErrClosedPipe
+		}
+		if err := st.sess.closeErr(); err != nil {
+			st.mu.Unlock()
+			return written, err
+		}
+		if st.sendWindow == 0 { // 对端还没有读取，等待窗口更新
+			deadline := st.writeDeadline
+			st.mu.Unlock()
+			if err := st.wait(st.writable, deadline); err != nil {
+				return written, err
+			}
+			continue
+		}
+		n := min(uint32(len(p)), st.sendWindow, muxMaxFrameSize)
+		st.sendWindow -= n
+		st.mu.Unlock()
+		if err := st.sess.writeFrame(muxFrameData, st.id, n, p[:n]); err != nil {
+			return written, err
+		}
+		written += int(n)
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Close 关闭流并通知对端，不影响会话中的其他流
+func (st *muxStream) Close() error {
+	st.mu.Lock()
+	if st.closed {
+		st.mu.Unlock()
+		return net.ErrClosed
+	}
+	st.closed = true
+	remoteClosed := st.remoteClosed
+	st.mu.Unlock()
+	st.wake()
+	st.sess.remove(st.id)
+	if remoteClosed {
+		return nil
+	}
+	return st.sess.writeFrame(muxFrameClose, st.id, 0, nil)
+}
+
+func (st *muxStream) LocalAddr() net.Addr {
+	if nc, ok := st.sess.conn.(net.Conn); ok {
+		return nc.LocalAddr()
+	}
+	return muxAddr{}
+}
+
+func (st *muxStream) RemoteAddr() net.Addr {
+	if nc, ok := st.sess.conn.(net.Conn); ok {
+		return nc.RemoteAddr()
+	}
+	return muxAddr{}
+}
+
+func (st *muxStream) SetDeadline(t time.Time) error {
+	_ = st.SetReadDeadline(t)
+	return st.SetWriteDeadline(t)
+}
+
+func (st *muxStream) SetReadDeadline(t time.Time) error {
+	st.mu.Lock()
+	st.readDeadline = t
+	st.mu.Unlock()
+	signal(st.readable)
+	return nil
+}
+
+func (st *muxStream) SetWriteDeadline(t time.Time) error {
+	st.mu.Lock()
+	st.writeDeadline = t
+	st.mu.Unlock()
+	signal(st.writable)
+	return nil
+}
+
+// ServeSession 处理对端在 sess 中打开的每个流，与 Accept 处理连接相同，阻塞直到会话关闭。
+// Shutdown 期间新打开的流会被直接关闭，已有的流与普通连接一样处理完请求后结束
+func (server *Server) ServeSession(sess *Session) {
+	for {
+		conn, err := sess.Accept()
+		if err != nil {
+			return // 会话已关闭
+		}
+		sem, ok := server.acquireConn()
+		if !ok {
+			server.logger().Errorf("rpc server: too many connections, reject stream of %s", conn.RemoteAddr())
+			_ = conn.Close()
+			continue
+		}
+		go func() {
+			defer server.releaseConn(sem)
+			server.ServeConn(conn)
+		}()
+	}
+}
@@ -0,0 +1,72 @@
+package Go_rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// startSessionServer 启动在每个连接上运行 Session 的服务端，返回客户端一侧的会话
+func startSessionServer(t testing.TB) *Session {
+	t.Helper()
+	server := newTestServer(t)
+	l := listenTCP(t)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		server.ServeSession(NewSession(conn, false))
+	}()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := NewSession(conn, true)
+	t.Cleanup(func() { _ = sess.Close() })
+	return sess
+}
+
+func TestSessionStreamsIsolated(t *testing.T) {
+	sess := startSessionServer(t)
+	clients := make([]*Client, 3)
+	for i := range clients {
+		client, err := sess.Dial()
+		if err != nil {
+			t.Fatal(err)
+		}
+		clients[i] = client
+	}
+
+	// 一个流上的慢调用不阻塞其他流
+	slow := clients[0].Go("Foo.Sleep", Args{Num1: 300}, new(int), nil)
+	start := time.Now()
+	var reply int
+	if err := clients[1].Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3 on the second stream, got %d, %v", reply, err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("expect no head-of-line blocking across streams, took %v", elapsed)
+	}
+
+	// 握手错误的流被服务端关闭，其他流不受影响
+	bad, err := sess.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = bad.Write([]byte("not an option\n"))
+	_ = bad.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := bad.Read(make([]byte, 1)); err == nil || isTimeout(err) {
+		t.Fatalf("expect the bad stream to be closed by the server, got %v", err)
+	}
+
+	// 关闭一个流上的客户端，其他流照常工作
+	_ = clients[1].Close()
+	if err := clients[2].Call(context.Background(), "Foo.Sum", Args{Num1: 2, Num2: 3}, &reply); err != nil || reply != 5 {
+		t.Fatalf("expect 5 after closing another stream, got %d, %v", reply, err)
+	}
+	if err := (<-slow.Done).Error; err != nil || *slow.Reply.(*int) != 300 {
+		t.Fatalf("expect the slow call to complete, got %v", err)
+	}
+}
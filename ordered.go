@@ -0,0 +1,64 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"sync"
+)
+
+// responseQueue 按请求的读取顺序发送一个连接上的响应，用于 Option.OrderedResponses。
+// 请求仍然并发处理，先完成的响应在队列中等待之前的请求完成
+type responseQueue struct {
+	server  *Server
+	cc      codec.Codec
+	sending *sync.Mutex
+
+	mu      sync.Mutex      // 保护 pending，并保证响应按队列顺序写入
+	pending []*responseSlot // 按读取顺序排列的尚未发送的响应
+}
+
+// responseSlot 是一个请求在 responseQueue 中的位置
+type responseSlot struct {
+	q     *responseQueue
+	ready bool
+	h     *codec.Header
+	body  interface{}
+}
+
+func newResponseQueue(server *Server, cc codec.Codec, sending *sync.Mutex) *responseQueue {
+	return &responseQueue{server: server, cc: cc, sending: sending}
+}
+
+// reserve 在队尾为下一个读取的请求分配位置，q 为 nil 时返回 nil，表示不需要排序
+func (q *responseQueue) reserve() *responseSlot {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := &responseSlot{q: q}
+	q.pending = append(q.pending, s)
+	return s
+}
+
+// send 记录响应，并发送队首所有已完成的响应
+func (s *responseSlot) send(h *codec.Header, body interface{}) {
+	q := s.q
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s.h, s.body, s.ready = h, body, true
+	for len(q.pending) > 0 && q.pending[0].ready {
+		head := q.pending[0]
+		q.pending[0] = nil
+		q.pending = q.pending[1:]
+		q.server.sendResponse(q.cc, head.h, head.body, q.sending)
+	}
+}
+
+// writeResponse 发送响应，slot 不为 nil 时等到之前读取的请求都已响应后再发送
+func (server *Server) writeResponse(cc codec.Codec, h *codec.Header, body interface{}, sending *sync.Mutex, slot *responseSlot) {
+	if slot == nil {
+		server.sendResponse(cc, h, body, sending)
+		return
+	}
+	slot.send(h, body)
+}
@@ -0,0 +1,43 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"reflect"
+	"testing"
+)
+
+// responseOrder 在一个连接上发送三个处理时间依次变短的请求，返回响应的 Seq 顺序
+func responseOrder(t *testing.T, ordered bool) []uint64 {
+	t.Helper()
+	_, addr := startServer(t)
+	opt := *DefaultOption
+	opt.OrderedResponses = ordered
+	cc := dialRaw(t, addr, &opt)
+	for i, ms := range []int{80, 40, 0} {
+		if err := cc.Write(&codec.Header{ServiceMethod: "Foo.Sleep", Seq: uint64(i + 1)}, Args{Num1: ms}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var seqs []uint64
+	for i := 0; i < 3; i++ {
+		var h codec.Header
+		var reply int
+		if err := cc.ReadHeader(&h); err != nil {
+			t.Fatal(err)
+		}
+		if err := cc.ReadBody(&reply); err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, h.Seq)
+	}
+	return seqs
+}
+
+func TestOrderedResponses(t *testing.T) {
+	if seqs := responseOrder(t, false); !reflect.DeepEqual(seqs, []uint64{3, 2, 1}) {
+		t.Fatalf("expect responses in completion order by default, got %v", seqs)
+	}
+	if seqs := responseOrder(t, true); !reflect.DeepEqual(seqs, []uint64{1, 2, 3}) {
+		t.Fatalf("expect responses in request order, got %v", seqs)
+	}
+}
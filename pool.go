@@ -0,0 +1,103 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// ClientPool 维护到同一地址的多个独立连接，并在它们之间轮询分发调用。
+// 同一个 Client 上的响应按序列号在一个编解码器上依次读取，
+// 使用多个连接可以避免较大的请求或响应阻塞其他调用
+type ClientPool struct {
+	network, address string
+	opt              *Option
+	next             atomic.Uint64 // 下一次使用的连接位置
+	slots            []poolSlot
+	closed           atomic.Bool
+}
+
+// poolSlot 是连接池中的一个位置，连接断开后在下一次使用时重新建立
+type poolSlot struct {
+	mu     sync.Mutex // 保护 client，避免并发地重复建立连接
+	client *Client
+}
+
+var _ io.Closer = (*ClientPool)(nil)
+
+// ErrPoolClosed 表示连接池已关闭
+var ErrPoolClosed = errors.New("rpc client: pool is closed")
+
+// NewClientPool 创建最多包含 size 个连接的连接池，连接在第一次使用时建立
+func NewClientPool(network, address string, size int, opts ...*Option) (*ClientPool, error) {
+	if size <= 0 {
+		return nil, errors.New("rpc client: pool size must be positive")
+	}
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientPool{
+		network: network,
+		address: address,
+		opt:     opt,
+		slots:   make([]poolSlot, size),
+	}, nil
+}
+
+// Get 轮询返回池中的一个可用连接，连接已断开时丢弃并重新建立。
+// 返回的 Client 由连接池管理，调用方不应关闭它
+func (p *ClientPool) Get() (*Client, error) {
+	if p.closed.Load() {
+		return nil, ErrPoolClosed
+	}
+	slot := &p.slots[(p.next.Add(1)-1)%uint64(len(p.slots))]
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	if slot.client != nil && slot.client.IsAvailable() {
+		return slot.client, nil
+	}
+	if slot.client != nil {
+		_ = slot.client.Close()
+		slot.client = nil
+	}
+	client, err := Dial(p.network, p.address, p.opt)
+	if err != nil {
+		return nil, err
+	}
+	if p.closed.Load() { // 建立连接期间连接池被关闭
+		_ = client.Close()
+		return nil, ErrPoolClosed
+	}
+	slot.client = client
+	return client, nil
+}
+
+// Call 在池中的一个连接上调用指定的方法并等待其完成。
+// 连接在调用过程中断开时返回错误，该连接会在之后被重新建立
+func (p *ClientPool) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	client, err := p.Get()
+	if err != nil {
+		return err
+	}
+	return client.Call(ctx, serviceMethod, args, reply)
+}
+
+// Close 关闭连接池及其中所有的连接
+func (p *ClientPool) Close() error {
+	if p.closed.Swap(true) {
+		return ErrPoolClosed
+	}
+	for i := range p.slots {
+		slot := &p.slots[i]
+		slot.mu.Lock()
+		if slot.client != nil {
+			_ = slot.client.Close()
+			slot.client = nil
+		}
+		slot.mu.Unlock()
+	}
+	return nil
+}
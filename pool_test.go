@@ -0,0 +1,130 @@
+package Go_rpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Echo 原样返回请求中的数据，用于测量较大负载下的吞吐量
+type Echo struct{}
+
+func (Echo) Bytes(args []byte, reply *[]byte) error {
+	*reply = args
+	return nil
+}
+
+func TestClientPool(t *testing.T) {
+	_, addr := startServer(t)
+	pool, err := NewClientPool("tcp", addr, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pool.Close() }()
+	seen := make(map[*Client]bool)
+	for i := 0; i < 6; i++ {
+		client, err := pool.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[client] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("expect 3 independent connections, got %d", len(seen))
+	}
+	if _, err := NewClientPool("tcp", addr, 0); err == nil {
+		t.Fatal("expect a non-positive size to be rejected")
+	}
+	_ = pool.Close()
+	if err := pool.Call(context.Background(), "Foo.Sum", Args{}, new(int)); err != ErrPoolClosed {
+		t.Fatalf("expect ErrPoolClosed, got %v", err)
+	}
+}
+
+func TestClientPoolConnectionDies(t *testing.T) {
+	_, addr := startServer(t)
+	pool, err := NewClientPool("tcp", addr, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pool.Close() }()
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			errs <- pool.Call(ctx, "Foo.Sleep", Args{Num1: 100}, new(int))
+		}()
+	}
+	time.Sleep(30 * time.Millisecond)
+	pool.slots[0].mu.Lock()
+	dead := pool.slots[0].client
+	pool.slots[0].mu.Unlock()
+	_ = dead.Close() // 第一个连接在调用过程中断开
+
+	// 每个调用都要么成功，要么立即得到错误，不会一直等待
+	wg.Wait()
+	close(errs)
+	var failed int
+	for err := range errs {
+		if isTimeout(err) {
+			t.Fatalf("expect no call to be lost, got %v", err)
+		}
+		if err != nil {
+			failed++
+		}
+	}
+	if failed == 0 || failed == n {
+		t.Fatalf("expect only calls on the dead connection to fail, got %d of %d", failed, n)
+	}
+	for i := 0; i < 4; i++ {
+		var reply int
+		if err := pool.Call(context.Background(), "Foo.Sum", Args{Num1: i, Num2: 1}, &reply); err != nil || reply != i+1 {
+			t.Fatalf("expect the dead connection to be redialed, got %d, %v", reply, err)
+		}
+	}
+	if pool.slots[0].client == dead {
+		t.Fatal("expect the dead connection to be replaced")
+	}
+}
+
+// benchmarkPayload 是吞吐量测试中每次调用发送和返回的数据
+var benchmarkPayload = make([]byte, 64<<10)
+
+func BenchmarkSingleClient(b *testing.B) {
+	_, addr := startServer(b, Echo{})
+	client := dialServer(b, addr)
+	b.SetBytes(int64(len(benchmarkPayload)))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := client.Call(context.Background(), "Echo.Bytes", benchmarkPayload, new([]byte)); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkClientPool(b *testing.B) {
+	_, addr := startServer(b, Echo{})
+	pool, err := NewClientPool("tcp", addr, 8)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = pool.Close() }()
+	b.SetBytes(int64(len(benchmarkPayload)))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := pool.Call(context.Background(), "Echo.Bytes", benchmarkPayload, new([]byte)); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// errRateLimited 是限流函数拒绝请求时回复的错误
+var errRateLimited = errors.New("rpc server: rate limited")
+
+// SetRateLimiter 设置请求的限流函数，f 为 nil 表示不限流。
+// 每个请求在调用方法之前以调用方的标识调用 f，返回 false 时不调用方法，
+// 直接回复 "rpc server: rate limited" 错误。调用方的标识依次取客户端证书的 CN、
+// 经过 SetAuthFunc 校验的 Option.Token、远端 IP，都没有时为空字符串。心跳不经过限流。
+// 可以使用 NewRateLimiter(...).Allow 作为 f。对之后建立的连接生效
+func (server *Server) SetRateLimiter(f func(identity string) bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.rateLimiter = f
+}
+
+func (server *Server) getRateLimiter() func(identity string) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.rateLimiter
+}
+
+// callerIdentity 返回限流使用的调用方标识，ctx 为连接级别的 context。
+// 令牌由客户端任意选择，只有经过 SetAuthFunc 校验时才作为标识，否则每个连接换一个令牌就能绕过限流
+func (server *Server) callerIdentity(ctx context.Context) string {
+	if id, ok := IdentityFromContext(ctx); ok && id.CommonName != "" {
+		return id.CommonName
+	}
+	if token := TokenFromContext(ctx); token != "" && server.getAuthFunc() != nil {
+		return token
+	}
+	if addr, ok := RemoteAddrFromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+			return host
+		}
+		return addr.String()
+	}
+	return ""
+}
+
+// rateLimiterSweepInterval 是 RateLimiter 清理已补满的令牌桶的间隔
+const rateLimiterSweepInterval = time.Minute
+
+// RateLimiter 是按标识区分的令牌桶限流器：每个标识的令牌以每秒 rate 个的速度补充，最多积累 burst 个，
+// 每个请求消耗一个令牌，没有令牌时拒绝。可以并发使用
+type RateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time // 上一次补充令牌的时间
+}
+
+// NewRateLimiter 创建限流器，每个标识每秒允许 rate 个请求，突发时最多 burst 个，burst < 1 时为 1
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*tokenBucket), lastSweep: time.Now()}
+}
+
+// Allow 消耗 identity 的一个令牌，没有可用的令牌时返回 false
+func (l *RateLimiter) Allow(identity string) bool {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= rateLimiterSweepInterval {
+		l.sweep(now)
+	}
+	b := l.buckets[identity]
+	if b == nil {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[identity] = b
+	}
+	l.refill(b, now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (l *RateLimiter) refill(b *tokenBucket, now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+}
+
+// sweep 删除已补满的令牌桶，它们与新建的桶相同，避免不再出现的标识一直占用内存
+func (l *RateLimiter) sweep(now time.Time) {
+	for identity, b := range l.buckets {
+		if l.refill(b, now); b.tokens >= l.burst {
+			delete(l.buckets, identity)
+		}
+	}
+	l.lastSweep = now
+}
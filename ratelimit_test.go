@@ -0,0 +1,86 @@
+package Go_rpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(10, 2)
+	if !l.Allow("a") || !l.Allow("a") {
+		t.Fatal("expect the burst to be allowed")
+	}
+	if l.Allow("a") {
+		t.Fatal("expect the third request to be rejected")
+	}
+	if !l.Allow("b") {
+		t.Fatal("expect another identity to have its own bucket")
+	}
+	time.Sleep(150 * time.Millisecond)
+	if !l.Allow("a") {
+		t.Fatal("expect tokens to refill over time")
+	}
+}
+
+// callTokens 用不同的 Option.Token 各建立一个连接并调用一次，返回被限流的调用数
+func callTokens(t *testing.T, addr string, tokens ...string) int {
+	limited := 0
+	for _, token := range tokens {
+		client := dialServer(t, addr, &Option{Token: token})
+		var reply int
+		err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1}, &reply)
+		if err != nil && strings.Contains(err.Error(), "rate limited") {
+			limited++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return limited
+}
+
+func TestRateLimitUnverifiedToken(t *testing.T) {
+	server, addr := startServer(t)
+	server.SetRateLimiter(NewRateLimiter(0, 2).Allow)
+	// 没有校验令牌时按远端 IP 限流，每个连接换一个令牌不能得到新的令牌桶
+	if limited := callTokens(t, addr, "a", "b", "c", "d"); limited != 2 {
+		t.Fatalf("expect 2 calls to be rate limited, got %d", limited)
+	}
+}
+
+func TestRateLimitVerifiedToken(t *testing.T) {
+	server, addr := startServer(t)
+	server.SetRateLimiter(NewRateLimiter(0, 1).Allow)
+	server.SetAuthFunc(func(token string) bool { return token != "" })
+	if limited := callTokens(t, addr, "a", "b", "a"); limited != 1 {
+		t.Fatalf("expect only the repeated token to be rate limited, got %d", limited)
+	}
+}
+
+func TestServerRateLimitRefill(t *testing.T) {
+	events := new(Events)
+	server, addr := startServer(t, events)
+	server.SetRateLimiter(NewRateLimiter(20, 3).Allow) // 每 50ms 补充一个令牌
+	client := dialServer(t, addr)
+	call := func() error {
+		return client.Call(context.Background(), "Events.Record", Args{Num1: 1}, new(int))
+	}
+	for i := 0; i < 3; i++ {
+		if err := call(); err != nil {
+			t.Fatalf("expect the burst to be allowed, got %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if err := call(); err == nil || err.Error() != "rpc server: rate limited" {
+			t.Fatalf("expect the call to be rate limited, got %v", err)
+		}
+	}
+	if n := events.n.Load(); n != 3 {
+		t.Fatalf("expect rejected calls not to reach the method, got %d calls", n)
+	}
+	time.Sleep(80 * time.Millisecond)
+	if err := call(); err != nil {
+		t.Fatalf("expect a call to be allowed after the bucket refills, got %v", err)
+	}
+}
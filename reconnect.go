@@ -0,0 +1,222 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ConnState 是 ReconnectingClient 的连接状态
+type ConnState int
+
+const (
+	StateConnected    ConnState = iota // 已建立连接
+	StateDisconnected                  // 连接已断开，下一次调用时重新连接
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	reconnectAttempts    = 5                      // 每次重新连接最多尝试的次数
+	reconnectBaseBackoff = 100 * time.Millisecond // 第一次重试前的等待时间，之后每次翻倍
+	reconnectMaxBackoff  = 5 * time.Second
+)
+
+// ReconnectingClient 在连接断开后自动重新连接的客户端。
+// 调用因连接错误失败时，重新连接并最多重试一次：
+// 请求还没有完整写入连接时（服务端一定没有收到）总是重试；
+// 请求已经发出时，只有通过 SetIdempotent 声明为幂等的方法才会重试，避免非幂等的操作被执行两次
+type ReconnectingClient struct {
+	network, address string
+	opt              *Option
+
+	mu         sync.Mutex // 保护以下字段
+	client     *Client
+	closed     bool
+	idempotent map[string]bool
+	onState    []func(ConnState)
+	dialing    *dialAttempt // 正在进行的重新连接，nil 表示没有
+}
+
+// dialAttempt 是一次重新连接，所有等待连接的调用共享它
+type dialAttempt struct {
+	done chan struct{} // 重新连接结束时关闭
+	err  error         // 重新连接失败的原因，done 关闭后才能读取
+}
+
+var _ io.Closer = (*ReconnectingClient)(nil)
+
+// NewReconnectingClient 创建 ReconnectingClient，连接在第一次调用时建立。opts 最多使用第一个
+func NewReconnectingClient(network, address string, opts ...*Option) *ReconnectingClient {
+	if len(opts) > 1 {
+		opts = opts[:1]
+	}
+	opt, _ := parseOptions(opts...) // 最多一个 Option 时不会出错
+	return &ReconnectingClient{
+		network:    network,
+		address:    address,
+		opt:        opt,
+		idempotent: make(map[string]bool),
+	}
+}
+
+// SetIdempotent 将 serviceMethods 声明为幂等方法，请求已发出后连接断开时也会重试
+func (rc *ReconnectingClient) SetIdempotent(serviceMethods ...string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for _, sm := range serviceMethods {
+		rc.idempotent[sm] = true
+	}
+}
+
+// OnStateChange 添加连接状态变化时调用的回调，回调在持有内部锁时调用，不能再调用 rc 的方法
+func (rc *ReconnectingClient) OnStateChange(f func(ConnState)) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.onState = append(rc.onState, f)
+}
+
+// Call 调用指定的方法并等待其完成，连接断开时重新连接并按幂等规则最多重试一次
+func (rc *ReconnectingClient) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	for attempt := 0; ; attempt++ {
+		client, err := rc.get(ctx)
+		if err != nil {
+			return err
+		}
+		written, err := client.roundTrip(ctx, serviceMethod, args, reply)
+		if err == nil || attempt > 0 || !isConnError(err) || ctx.Err() != nil {
+			return err
+		}
+		rc.mu.Lock()
+		retry := !written || rc.idempotent[serviceMethod]
+		rc.mu.Unlock()
+		if !retry {
+			return err
+		}
+	}
+}
+
+// get 返回可用的连接，连接已断开时重新连接。
+// 同一时间只有一个 goroutine 在重新连接，其他调用等待它的结果，等待期间 ctx 结束时立即返回
+func (rc *ReconnectingClient) get(ctx context.Context) (*Client, error) {
+	for {
+		rc.mu.Lock()
+		if rc.closed {
+			rc.mu.Unlock()
+			return nil, ErrShutdown
+		}
+		if rc.client != nil {
+			if rc.client.IsAvailable() {
+				client := rc.client
+				rc.mu.Unlock()
+				return client, nil
+			}
+			_ = rc.client.Close()
+			rc.client = nil
+			rc.notify(StateDisconnected)
+		}
+		attempt := rc.dialing
+		if attempt == nil {
+			attempt = &dialAttempt{done: make(chan struct{})}
+			rc.dialing = attempt
+			go rc.redial(attempt)
+		}
+		rc.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-attempt.done:
+		}
+		if attempt.err != nil {
+			return nil, attempt.err
+		}
+	}
+}
+
+// redial 按退避时间最多尝试 reconnectAttempts 次建立连接，结果保存在 attempt 中。
+// 不受单个调用的 ctx 影响，rc 关闭后停止重试
+func (rc *ReconnectingClient) redial(attempt *dialAttempt) {
+	var client *Client
+	var err error
+	backoff := reconnectBaseBackoff
+	for i := 0; i < reconnectAttempts; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > reconnectMaxBackoff {
+				backoff = reconnectMaxBackoff
+			}
+		}
+		if rc.isClosed() {
+			err = ErrShutdown
+			break
+		}
+		if client, err = Dial(rc.network, rc.address, rc.opt); err == nil {
+			break
+		}
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.dialing = nil
+	switch {
+	case err != nil:
+		attempt.err = err
+	case rc.closed: // 连接期间 rc 已关闭
+		_ = client.Close()
+		attempt.err = ErrShutdown
+	default:
+		rc.client = client
+		rc.notify(StateConnected)
+	}
+	close(attempt.done)
+}
+
+func (rc *ReconnectingClient) isClosed() bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.closed
+}
+
+func (rc *ReconnectingClient) notify(state ConnState) {
+	for _, f := range rc.onState {
+		f(state)
+	}
+}
+
+// Close 关闭当前连接，之后的调用返回 ErrShutdown
+func (rc *ReconnectingClient) Close() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.closed {
+		return ErrShutdown
+	}
+	rc.closed = true
+	if rc.client == nil {
+		return nil
+	}
+	err := rc.client.Close()
+	rc.client = nil
+	return err
+}
+
+// isConnError 返回 err 是否为连接错误，服务端返回的错误和超时不是连接错误
+func isConnError(err error) bool {
+	switch {
+	case errors.Is(err, ErrServer):
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return errors.Is(err, ErrShutdown) || errors.Is(err, ErrConnectionLost) || errors.Is(err, ErrTransport)
+}
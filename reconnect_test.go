@@ -0,0 +1,117 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// trackingListener 记录接受的连接，用于在测试中从服务端断开连接
+type trackingListener struct {
+	net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mu.Lock()
+		l.conns = append(l.conns, conn)
+		l.mu.Unlock()
+	}
+	return conn, err
+}
+
+// closeAll 关闭所有已接受的连接，返回关闭的连接数
+func (l *trackingListener) closeAll() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := len(l.conns)
+	for _, conn := range l.conns {
+		_ = conn.Close()
+	}
+	l.conns = nil
+	return n
+}
+
+func TestReconnectingClient(t *testing.T) {
+	server, l := newTestServer(t), listenTCP(t)
+	tl := &trackingListener{Listener: l}
+	go server.Accept(tl)
+
+	rc := NewReconnectingClient("tcp", l.Addr().String())
+	defer func() { _ = rc.Close() }()
+	var mu sync.Mutex
+	var states []ConnState
+	rc.OnStateChange(func(s ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, s)
+	})
+
+	var reply int
+	if err := rc.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, got %d, %v", reply, err)
+	}
+	if n := tl.closeAll(); n != 1 {
+		t.Fatalf("expect one server connection, got %d", n)
+	}
+	time.Sleep(50 * time.Millisecond) // 等待客户端发现连接已断开
+	if err := rc.Call(context.Background(), "Foo.Sum", Args{Num1: 2, Num2: 2}, &reply); err != nil || reply != 4 {
+		t.Fatalf("expect the second call to reconnect and return 4, got %d, %v", reply, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []ConnState{StateConnected, StateDisconnected, StateConnected}
+	if len(states) != len(want) {
+		t.Fatalf("expect states %v, got %v", want, states)
+	}
+	for i := range want {
+		if states[i] != want[i] {
+			t.Fatalf("expect states %v, got %v", want, states)
+		}
+	}
+}
+
+func TestReconnectingClientWaitersHonorContext(t *testing.T) {
+	// 连接总是被拒绝，重新连接会按退避时间持续约 1.5s
+	rc := NewReconnectingClient("tcp", deadAddr(t))
+	defer func() { _ = rc.Close() }()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			err := rc.Call(ctx, "Foo.Sum", Args{}, new(int))
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expect DeadlineExceeded, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+				t.Errorf("expect the call to return with its ctx, took %v", elapsed)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestReconnectingClientClosed(t *testing.T) {
+	_, addr := startServer(t)
+	rc := NewReconnectingClient("tcp", addr)
+	if err := rc.Call(context.Background(), "Foo.Sum", Args{}, new(int)); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Call(context.Background(), "Foo.Sum", Args{}, new(int)); !errors.Is(err, ErrShutdown) {
+		t.Fatalf("expect ErrShutdown after Close, got %v", err)
+	}
+}
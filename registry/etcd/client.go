@@ -0,0 +1,55 @@
+// Package etcd 提供基于 etcd 的服务注册和服务发现。
+// EtcdRegistry 和 EtcdDiscovery 只依赖 Client 接口，NewClient 返回基于 clientv3 的实现。
+// 本包是单独的模块（Go-rpc/registry/etcd），不使用 etcd 的程序不会引入 etcd 的依赖
+package etcd
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultPrefix 是服务器地址在 etcd 中的默认键前缀，每个服务器的键为前缀加地址
+const DefaultPrefix = "/_gorpc_/servers/"
+
+const etcdRequestTimeout = 5 * time.Second // 单次 etcd 请求的超时时间
+
+// LeaseID 是 etcd 租约的 ID
+type LeaseID int64
+
+// EventType 是 Watch 事件的类型
+type EventType int
+
+const (
+	EventPut    EventType = iota // 键被创建或更新
+	EventDelete                  // 键被删除或随租约过期
+)
+
+// Event 是前缀下一个键的变化，EventDelete 事件的 Value 为空
+type Event struct {
+	Type  EventType
+	Key   string
+	Value string
+}
+
+// WatchResponse 是 Watch 返回的一批事件，Err 不为 nil 时 Watch 已停止
+type WatchResponse struct {
+	Events []Event
+	Err    error
+}
+
+// Client 是 EtcdRegistry 和 EtcdDiscovery 用到的 etcd 操作，测试时可以替换为内存实现
+type Client interface {
+	// Grant 创建 ttl 秒后过期的租约
+	Grant(ctx context.Context, ttl int64) (LeaseID, error)
+	// KeepAlive 在后台持续续约，每次续约成功向返回的 channel 发送一个值（channel 满时丢弃）。
+	// 租约过期、续约失败或 ctx 结束时关闭 channel
+	KeepAlive(ctx context.Context, id LeaseID) (<-chan struct{}, error)
+	// Revoke 撤销租约，绑定该租约的键随之删除
+	Revoke(ctx context.Context, id LeaseID) error
+	// Put 写入键值并绑定租约，lease 为 0 表示不绑定
+	Put(ctx context.Context, key, value string, lease LeaseID) error
+	// List 返回前缀下的所有键值以及读取时的版本号
+	List(ctx context.Context, prefix string) (kvs map[string]string, rev int64, err error)
+	// Watch 从版本号 rev 开始监听前缀下的变化，ctx 结束或监听出错时关闭返回的 channel
+	Watch(ctx context.Context, prefix string, rev int64) <-chan WatchResponse
+}
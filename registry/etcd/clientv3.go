@@ -0,0 +1,99 @@
+package etcd
+
+import (
+	"context"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// v3Client 基于 clientv3 实现 Client
+type v3Client struct {
+	cli *clientv3.Client
+}
+
+// NewClient 将 clientv3.Client 包装为 Client，cli 的生命周期由调用方管理
+func NewClient(cli *clientv3.Client) Client {
+	return &v3Client{cli: cli}
+}
+
+func (c *v3Client) Grant(ctx context.Context, ttl int64) (LeaseID, error) {
+	resp, err := c.cli.Grant(ctx, ttl)
+	if err != nil {
+		return 0, err
+	}
+	return LeaseID(resp.ID), nil
+}
+
+func (c *v3Client) KeepAlive(ctx context.Context, id LeaseID) (<-chan struct{}, error) {
+	ch, err := c.cli.KeepAlive(ctx, clientv3.LeaseID(id))
+	if err != nil {
+		return nil, err
+	}
+	alive := make(chan struct{}, 1)
+	go func() {
+		defer close(alive)
+		for range ch { // 租约过期或 ctx 结束时 clientv3 关闭 ch
+			select {
+			case alive <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return alive, nil
+}
+
+func (c *v3Client) Revoke(ctx context.Context, id LeaseID) error {
+	_, err := c.cli.Revoke(ctx, clientv3.LeaseID(id))
+	return err
+}
+
+func (c *v3Client) Put(ctx context.Context, key, value string, lease LeaseID) error {
+	var opts []clientv3.OpOption
+	if lease != 0 {
+		opts = append(opts, clientv3.WithLease(clientv3.LeaseID(lease)))
+	}
+	_, err := c.cli.Put(ctx, key, value, opts...)
+	return err
+}
+
+func (c *v3Client) List(ctx context.Context, prefix string) (map[string]string, int64, error) {
+	resp, err := c.cli.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, err
+	}
+	kvs := make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		kvs[string(kv.Key)] = string(kv.Value)
+	}
+	return kvs, resp.Header.Revision, nil
+}
+
+func (c *v3Client) Watch(ctx context.Context, prefix string, rev int64) <-chan WatchResponse {
+	wch := c.cli.Watch(clientv3.WithRequireLeader(ctx), prefix, clientv3.WithPrefix(), clientv3.WithRev(rev))
+	out := make(chan WatchResponse)
+	go func() {
+		defer close(out)
+		for wresp := range wch {
+			resp := WatchResponse{Err: wresp.Err()}
+			if resp.Err == nil {
+				resp.Events = make([]Event, 0, len(wresp.Events))
+				for _, e := range wresp.Events {
+					ev := Event{Type: EventPut, Key: string(e.Kv.Key), Value: string(e.Kv.Value)}
+					if e.Type == clientv3.EventTypeDelete {
+						ev.Type = EventDelete
+					}
+					resp.Events = append(resp.Events, ev)
+				}
+			}
+			select {
+			case out <- resp:
+			case <-ctx.Done():
+				return
+			}
+			if resp.Err != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,183 @@
+package etcd
+
+import (
+	Go_rpc "Go-rpc"
+	"Go-rpc/xclient"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EtcdDiscovery 是基于 etcd 的服务发现，先读取前缀下的所有地址，再监听前缀的变化实时更新服务列表。
+// 监听中断时（例如与 etcd 断开或版本被压缩）按退避时间重新读取并监听，期间继续使用旧的服务列表
+type EtcdDiscovery struct {
+	*xclient.MultiServersDiscovery
+	client Client
+	prefix string
+	cancel context.CancelFunc
+	done   chan struct{} // 监听的 goroutine 退出后关闭
+
+	readyOnce sync.Once
+	ready     chan struct{} // 第一次成功读取服务列表后关闭
+
+	mu      sync.Mutex // 保护 lastErr
+	lastErr error      // 最近一次读取或监听的错误
+}
+
+var _ xclient.WeightedDiscovery = (*EtcdDiscovery)(nil)
+
+// NewEtcdDiscovery 创建 EtcdDiscovery 并在后台开始监听 prefix，prefix 为空时使用 DefaultPrefix。
+// 不再使用时调用 Close 停止监听
+func NewEtcdDiscovery(client Client, prefix string) *EtcdDiscovery {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &EtcdDiscovery{
+		MultiServersDiscovery: xclient.NewMultiServerDiscovery(make([]string, 0)),
+		client:                client,
+		prefix:                prefix,
+		cancel:                cancel,
+		done:                  make(chan struct{}),
+		ready:                 make(chan struct{}),
+	}
+	go d.run(ctx)
+	return d
+}
+
+// run 读取服务列表并监听变化，监听中断后重新开始，直到 ctx 结束
+func (d *EtcdDiscovery) run(ctx context.Context) {
+	defer close(d.done)
+	backoff := reregisterBackoff
+	for {
+		err := d.sync(ctx, func() { backoff = reregisterBackoff })
+		if ctx.Err() != nil {
+			return
+		}
+		Go_rpc.DefaultLogger().Errorf("rpc discovery: watch etcd prefix %s err: %v", d.prefix, err)
+		d.mu.Lock()
+		d.lastErr = err
+		d.mu.Unlock()
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > reregisterMaxDelay {
+			backoff = reregisterMaxDelay
+		}
+	}
+}
+
+// sync 读取前缀下的所有地址，再按事件更新服务列表，返回中断的原因。收到事件时调用 progress
+func (d *EtcdDiscovery) sync(ctx context.Context, progress func()) error {
+	listCtx, cancel := context.WithTimeout(ctx, etcdRequestTimeout)
+	kvs, rev, err := d.client.List(listCtx, d.prefix)
+	cancel()
+	if err != nil {
+		return err
+	}
+	servers := make(map[string]struct{}, len(kvs))
+	for key := range kvs {
+		servers[strings.TrimPrefix(key, d.prefix)] = struct{}{}
+	}
+	d.update(servers)
+	d.readyOnce.Do(func() { close(d.ready) })
+	watch := d.client.Watch(ctx, d.prefix, rev+1)
+	for {
+		var resp WatchResponse
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case resp, ok = <-watch:
+		}
+		if !ok {
+			return errors.New("watch closed")
+		}
+		if resp.Err != nil {
+			return resp.Err
+		}
+		for _, e := range resp.Events {
+			addr := strings.TrimPrefix(e.Key, d.prefix)
+			if e.Type == EventDelete {
+				delete(servers, addr)
+			} else {
+				servers[addr] = struct{}{}
+			}
+		}
+		d.update(servers)
+		progress()
+	}
+}
+
+func (d *EtcdDiscovery) update(servers map[string]struct{}) {
+	list := make([]string, 0, len(servers))
+	for addr := range servers {
+		if addr != "" {
+			list = append(list, addr)
+		}
+	}
+	sort.Strings(list)
+	_ = d.MultiServersDiscovery.Update(list)
+}
+
+// Refresh 等待第一次读取服务列表完成，之后服务列表由监听实时更新，Refresh 直接返回。
+// 超时仍未读取成功时返回最近一次的错误
+func (d *EtcdDiscovery) Refresh() error {
+	select {
+	case <-d.ready:
+		return nil
+	default:
+	}
+	timer := time.NewTimer(etcdRequestTimeout)
+	defer timer.Stop()
+	select {
+	case <-d.ready:
+		return nil
+	case <-d.done:
+	case <-timer.C:
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lastErr != nil {
+		return d.lastErr
+	}
+	return errors.New("rpc discovery: etcd not ready")
+}
+
+// Get 等待服务列表就绪后根据 mode 选择一个服务实例
+func (d *EtcdDiscovery) Get(mode xclient.SelectMode) (string, error) {
+	if err := d.Refresh(); err != nil {
+		return "", err
+	}
+	return d.MultiServersDiscovery.Get(mode)
+}
+
+// GetWeighted 等待服务列表就绪后返回所有服务实例，权重都为 1
+func (d *EtcdDiscovery) GetWeighted() ([]xclient.WeightedServer, error) {
+	if err := d.Refresh(); err != nil {
+		return nil, err
+	}
+	return d.MultiServersDiscovery.GetWeighted()
+}
+
+// GetAll 等待服务列表就绪后返回所有服务实例
+func (d *EtcdDiscovery) GetAll() ([]string, error) {
+	if err := d.Refresh(); err != nil {
+		return nil, err
+	}
+	return d.MultiServersDiscovery.GetAll()
+}
+
+// Close 停止监听，之后服务列表不再更新
+func (d *EtcdDiscovery) Close() error {
+	d.cancel()
+	<-d.done
+	return nil
+}
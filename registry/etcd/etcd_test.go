@@ -0,0 +1,239 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memClient 是 Client 的内存实现，租约只在 Revoke 或 expire 时失效
+type memClient struct {
+	mu       sync.Mutex
+	rev      int64
+	nextID   LeaseID
+	kvs      map[string]memKV
+	leases   map[LeaseID]chan struct{} // 租约失效时关闭
+	watchers map[chan WatchResponse]string
+}
+
+type memKV struct {
+	value string
+	lease LeaseID
+}
+
+func newMemClient() *memClient {
+	return &memClient{
+		kvs:      make(map[string]memKV),
+		leases:   make(map[LeaseID]chan struct{}),
+		watchers: make(map[chan WatchResponse]string),
+	}
+}
+
+func (c *memClient) Grant(ctx context.Context, ttl int64) (LeaseID, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	c.leases[c.nextID] = make(chan struct{})
+	return c.nextID, nil
+}
+
+func (c *memClient) KeepAlive(ctx context.Context, id LeaseID) (<-chan struct{}, error) {
+	c.mu.Lock()
+	lost, ok := c.leases[id]
+	c.mu.Unlock()
+	if !ok {
+		return nil, errors.New("lease not found")
+	}
+	alive := make(chan struct{}, 1)
+	alive <- struct{}{}
+	go func() {
+		defer close(alive)
+		select {
+		case <-ctx.Done():
+		case <-lost:
+		}
+	}()
+	return alive, nil
+}
+
+func (c *memClient) Revoke(ctx context.Context, id LeaseID) error {
+	c.expire(id)
+	return nil
+}
+
+// expire 使租约失效并删除绑定该租约的键，模拟租约过期
+func (c *memClient) expire(id LeaseID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if lost, ok := c.leases[id]; ok {
+		close(lost)
+		delete(c.leases, id)
+	}
+	for key, kv := range c.kvs {
+		if kv.lease == id {
+			delete(c.kvs, key)
+			c.notify(Event{Type: EventDelete, Key: key})
+		}
+	}
+}
+
+func (c *memClient) Put(ctx context.Context, key, value string, lease LeaseID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.leases[lease]; lease != 0 && !ok {
+		return errors.New("lease not found")
+	}
+	c.kvs[key] = memKV{value: value, lease: lease}
+	c.notify(Event{Type: EventPut, Key: key, Value: value})
+	return nil
+}
+
+func (c *memClient) List(ctx context.Context, prefix string) (map[string]string, int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kvs := make(map[string]string)
+	for key, kv := range c.kvs {
+		if strings.HasPrefix(key, prefix) {
+			kvs[key] = kv.value
+		}
+	}
+	return kvs, c.rev, nil
+}
+
+func (c *memClient) Watch(ctx context.Context, prefix string, rev int64) <-chan WatchResponse {
+	ch := make(chan WatchResponse, 16)
+	c.mu.Lock()
+	c.watchers[ch] = prefix
+	c.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.watchers, ch)
+		close(ch)
+	}()
+	return ch
+}
+
+// notify 向监听前缀的 watcher 发送事件，调用方需持有 mu
+func (c *memClient) notify(e Event) {
+	c.rev++
+	for ch, prefix := range c.watchers {
+		if strings.HasPrefix(e.Key, prefix) {
+			ch <- WatchResponse{Events: []Event{e}}
+		}
+	}
+}
+
+func (c *memClient) leaseOf(key string) LeaseID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.kvs[key].lease
+}
+
+// waitFor 等待 cond 成立，超过 1s 时测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestEtcdRegistryRegister(t *testing.T) {
+	client := newMemClient()
+	r := NewEtcdRegistry(client, "", time.Second)
+	defer func() { _ = r.Close() }()
+
+	const addr = "tcp@127.0.0.1:1"
+	if err := r.Register(addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(addr); err == nil {
+		t.Fatal("expect an error when registering the same address twice")
+	}
+	kvs, _, _ := client.List(context.Background(), DefaultPrefix)
+	if !reflect.DeepEqual(kvs, map[string]string{DefaultPrefix + addr: addr}) {
+		t.Fatalf("expect %s under the default prefix, got %v", addr, kvs)
+	}
+	if client.leaseOf(DefaultPrefix+addr) == 0 {
+		t.Fatal("expect the key to be bound to a lease")
+	}
+	if err := r.Deregister(addr); err != nil {
+		t.Fatal(err)
+	}
+	if kvs, _, _ := client.List(context.Background(), DefaultPrefix); len(kvs) != 0 {
+		t.Fatalf("expect the key to be removed with its lease, got %v", kvs)
+	}
+}
+
+func TestEtcdRegistryLeaseLoss(t *testing.T) {
+	client := newMemClient()
+	r := NewEtcdRegistry(client, "", time.Second)
+	defer func() { _ = r.Close() }()
+	d := NewEtcdDiscovery(client, "")
+	defer func() { _ = d.Close() }()
+
+	const addr = "tcp@127.0.0.1:1"
+	if err := r.Register(addr); err != nil {
+		t.Fatal(err)
+	}
+	lease := client.leaseOf(DefaultPrefix + addr)
+	waitFor(t, "discovery to list the server", func() bool {
+		servers, _ := d.GetAll()
+		return len(servers) == 1
+	})
+	client.expire(lease)
+	waitFor(t, "the expired server to disappear", func() bool {
+		servers, _ := d.GetAll()
+		return len(servers) == 0
+	})
+	// 续约停止而没有注销，按退避时间用新的租约重新注册
+	waitFor(t, "the server to be re-registered", func() bool {
+		servers, _ := d.GetAll()
+		return len(servers) == 1
+	})
+	if l := client.leaseOf(DefaultPrefix + addr); l == 0 || l == lease {
+		t.Fatalf("expect a new lease after re-registering, got %d", l)
+	}
+}
+
+func TestEtcdDiscoveryWatch(t *testing.T) {
+	client := newMemClient()
+	_ = client.Put(context.Background(), DefaultPrefix+"tcp@a:1", "tcp@a:1", 0)
+	d := NewEtcdDiscovery(client, "")
+	defer func() { _ = d.Close() }()
+	if servers, err := d.GetAll(); err != nil || !reflect.DeepEqual(servers, []string{"tcp@a:1"}) {
+		t.Fatalf("expect the initial list, got %v, %v", servers, err)
+	}
+
+	r := NewEtcdRegistry(client, "", time.Second)
+	defer func() { _ = r.Close() }()
+	if err := r.Register("tcp@b:1"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the registered server to be watched", func() bool {
+		servers, _ := d.GetAll()
+		return reflect.DeepEqual(servers, []string{"tcp@a:1", "tcp@b:1"})
+	})
+	if err := r.Deregister("tcp@b:1"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the deregistered server to be removed", func() bool {
+		servers, _ := d.GetAll()
+		return reflect.DeepEqual(servers, []string{"tcp@a:1"})
+	})
+	// 其他前缀下的键不影响服务列表
+	_ = client.Put(context.Background(), "/other/tcp@c:1", "tcp@c:1", 0)
+	time.Sleep(20 * time.Millisecond)
+	if servers, _ := d.GetAll(); len(servers) != 1 {
+		t.Fatalf("expect keys outside the prefix to be ignored, got %v", servers)
+	}
+}
@@ -0,0 +1,167 @@
+package etcd
+
+import (
+	Go_rpc "Go-rpc"
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLeaseTTL    = 10 * time.Second
+	reregisterBackoff  = 100 * time.Millisecond // 租约丢失后第一次重新注册前的等待时间，之后每次翻倍
+	reregisterMaxDelay = 5 * time.Second
+)
+
+// EtcdRegistry 将服务器地址注册到 etcd，每个地址的键绑定一个租约并在后台持续续约。
+// 服务器崩溃或与 etcd 断开时租约在 ttl 后过期，键被 etcd 自动删除；
+// 续约停止而没有调用 Deregister 时（例如网络分区导致租约过期），按退避时间重新注册
+type EtcdRegistry struct {
+	client Client
+	prefix string
+	ttl    int64 // 租约的有效期，单位为秒
+
+	mu      sync.Mutex // 保护 servers
+	servers map[string]*registration
+}
+
+// registration 是一个已注册的地址
+type registration struct {
+	cancel context.CancelFunc
+	done   chan struct{} // 续约的 goroutine 退出后关闭
+
+	mu    sync.Mutex // 保护 lease
+	lease LeaseID
+}
+
+// NewEtcdRegistry 创建 EtcdRegistry，prefix 为空时使用 DefaultPrefix，ttl 为 0 时使用默认的 10s。
+// ttl 向上取整到秒
+func NewEtcdRegistry(client Client, prefix string, ttl time.Duration) *EtcdRegistry {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+	return &EtcdRegistry{
+		client:  client,
+		prefix:  prefix,
+		ttl:     int64(math.Ceil(ttl.Seconds())),
+		servers: make(map[string]*registration),
+	}
+}
+
+// Register 以 prefix+addr 为键注册 addr（如 tcp@127.0.0.1:9999），返回第一次注册的错误。
+// 注册成功后在后台续约，直到调用 Deregister 或 Close
+func (r *EtcdRegistry) Register(addr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.servers[addr]; dup {
+		return errors.New("rpc registry: " + addr + " already registered")
+	}
+	lease, err := r.put(addr)
+	if err != nil {
+		Go_rpc.DefaultLogger().Errorf("rpc registry: register %s to etcd err: %v", addr, err)
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	reg := &registration{cancel: cancel, done: make(chan struct{}), lease: lease}
+	r.servers[addr] = reg
+	go r.keepAlive(ctx, addr, reg)
+	return nil
+}
+
+// put 创建新的租约并写入 addr 的键
+func (r *EtcdRegistry) put(addr string) (LeaseID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+	defer cancel()
+	lease, err := r.client.Grant(ctx, r.ttl)
+	if err != nil {
+		return 0, err
+	}
+	if err = r.client.Put(ctx, r.prefix+addr, addr, lease); err != nil {
+		_ = r.client.Revoke(ctx, lease)
+		return 0, err
+	}
+	return lease, nil
+}
+
+// keepAlive 为 reg 的租约续约，续约停止时重新注册，直到 ctx 结束
+func (r *EtcdRegistry) keepAlive(ctx context.Context, addr string, reg *registration) {
+	defer close(reg.done)
+	backoff := reregisterBackoff
+	for {
+		reg.mu.Lock()
+		lease := reg.lease
+		reg.mu.Unlock()
+		if lease != 0 {
+			alive, err := r.client.KeepAlive(ctx, lease)
+			if err == nil {
+				for range alive {
+					backoff = reregisterBackoff
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			Go_rpc.DefaultLogger().Infof("rpc registry: lease of %s lost, re-registering", addr)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > reregisterMaxDelay {
+			backoff = reregisterMaxDelay
+		}
+		lease, err := r.put(addr)
+		if err != nil {
+			Go_rpc.DefaultLogger().Errorf("rpc registry: re-register %s err: %v", addr, err)
+		}
+		reg.mu.Lock()
+		reg.lease = lease
+		reg.mu.Unlock()
+	}
+}
+
+// Deregister 停止续约并撤销 addr 的租约，使 addr 立即从服务列表中消失
+func (r *EtcdRegistry) Deregister(addr string) error {
+	r.mu.Lock()
+	reg := r.servers[addr]
+	delete(r.servers, addr)
+	r.mu.Unlock()
+	if reg == nil {
+		return errors.New("rpc registry: " + addr + " not registered")
+	}
+	return r.revoke(reg)
+}
+
+func (r *EtcdRegistry) revoke(reg *registration) error {
+	reg.cancel()
+	<-reg.done
+	if reg.lease == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+	defer cancel()
+	return r.client.Revoke(ctx, reg.lease)
+}
+
+// Close 注销所有已注册的地址，返回第一个错误。Close 不会关闭 client
+func (r *EtcdRegistry) Close() error {
+	r.mu.Lock()
+	servers := r.servers
+	r.servers = make(map[string]*registration)
+	r.mu.Unlock()
+	var first error
+	for _, reg := range servers {
+		if err := r.revoke(reg); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
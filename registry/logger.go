@@ -0,0 +1,50 @@
+package registry
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// Logger 是注册中心使用的日志接口，与 Go_rpc.Logger 相同，Go_rpc.SetLogger 会同时设置它
+type Logger interface {
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+// stdLogger 是基于标准库 log 的默认实现，不输出 Debug 级别的日志
+type stdLogger struct{}
+
+func (stdLogger) Debugf(format string, v ...interface{}) {}
+
+func (stdLogger) Infof(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+func (stdLogger) Errorf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+// loggerHolder 使不同的 Logger 实现可以存入同一个 atomic.Value
+type loggerHolder struct {
+	Logger
+}
+
+var defaultLogger atomic.Value
+
+func init() {
+	defaultLogger.Store(loggerHolder{stdLogger{}})
+}
+
+// SetLogger 设置注册中心和心跳使用的日志，l 为 nil 时恢复使用标准库 log
+func SetLogger(l Logger) {
+	if l == nil {
+		l = stdLogger{}
+	}
+	defaultLogger.Store(loggerHolder{l})
+}
+
+// getLogger 返回注册中心使用的日志
+func getLogger() Logger {
+	return defaultLogger.Load().(loggerHolder).Logger
+}
@@ -0,0 +1,215 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry 是一个简单的注册中心，提供以下功能：
+// 添加一个服务器并接收心跳以保持其存活；
+// 返回所有存活的服务器，并同时删除已超时的服务器
+type Registry struct {
+	timeout time.Duration
+	mu      sync.Mutex // 保护 servers
+	servers map[string]*ServerItem
+	now     func() time.Time // 当前时间，测试时可替换
+}
+
+// ServerItem 记录一个服务器的地址和最近一次心跳的时间
+type ServerItem struct {
+	Addr  string
+	start time.Time
+}
+
+const (
+	defaultPath    = "/_gorpc_/registry"
+	defaultTimeout = time.Minute * 5
+)
+
+// 注册中心通过以下 HTTP 头传递服务器地址
+const (
+	serverHeader  = "X-Gorpc-Server"  // POST 时携带要注册的地址，DELETE 时携带要注销的地址
+	serversHeader = "X-Gorpc-Servers" // GET 时返回存活的地址，逗号分隔
+)
+
+// New 创建注册中心，timeout 为 0 时服务器永不过期
+func New(timeout time.Duration) *Registry {
+	return &Registry{
+		servers: make(map[string]*ServerItem),
+		timeout: timeout,
+		now:     time.Now,
+	}
+}
+
+// DefaultRegistry 是默认的注册中心实例
+var DefaultRegistry = New(defaultTimeout)
+
+// putServer 添加服务器，已存在时更新心跳时间
+func (r *Registry) putServer(addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.servers[addr]
+	if s == nil {
+		r.servers[addr] = &ServerItem{Addr: addr, start: r.now()}
+	} else {
+		s.start = r.now()
+	}
+}
+
+// removeServer 立即删除服务器，服务器不存在时什么也不做
+func (r *Registry) removeServer(addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.servers, addr)
+}
+
+// aliveServers 返回存活的服务器地址，并删除已超时的服务器
+func (r *Registry) aliveServers() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var alive []string
+	for addr, s := range r.servers {
+		if r.timeout == 0 || s.start.Add(r.timeout).After(r.now()) {
+			alive = append(alive, addr)
+		} else {
+			delete(r.servers, addr)
+		}
+	}
+	sort.Strings(alive)
+	return alive
+}
+
+// ServeHTTP 运行在 defaultPath 上：GET 返回存活的服务器，POST 注册或刷新服务器，DELETE 注销服务器
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		w.Header().Set(serversHeader, strings.Join(r.aliveServers(), ","))
+	case "POST":
+		addr := req.Header.Get(serverHeader)
+		if addr == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		r.putServer(addr)
+	case "DELETE":
+		addr := req.Header.Get(serverHeader)
+		if addr == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		r.removeServer(addr)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleHTTP 在 http.DefaultServeMux 的 registryPath 上注册处理器
+func (r *Registry) HandleHTTP(registryPath string) {
+	http.Handle(registryPath, r)
+	getLogger().Infof("rpc registry path: %s", registryPath)
+}
+
+// HandleHTTP 在默认路径上注册 DefaultRegistry
+func HandleHTTP() {
+	DefaultRegistry.HandleHTTP(defaultPath)
+}
+
+// requestTimeout 是向注册中心发送一次请求的最长时间，避免注册中心没有响应时心跳或注销一直阻塞
+const requestTimeout = 5 * time.Second
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
+// Heartbeat 每隔 duration 向注册中心发送一次心跳，是服务器注册到注册中心的辅助函数。
+// duration 为 0 时使用比默认超时时间稍短的间隔，保证在被删除前有足够的时间发送心跳。
+// 心跳失败时记录日志，下一个间隔继续发送
+func Heartbeat(registry, addr string, duration time.Duration) {
+	_, _ = heartbeat(registry, addr, duration, nil)
+}
+
+// StartHeartbeat 与 Heartbeat 相同，返回第一次心跳的错误以及停止心跳的函数。
+// 调用 stop 后不再发送心跳，等待正在发送的心跳结束后立即从注册中心注销 addr，
+// 使其不必等到超时才从服务列表中消失，也不会被之后到达的心跳重新注册；ctx 结束时放弃等待和注销
+func StartHeartbeat(registry, addr string, duration time.Duration) (stop func(ctx context.Context) error, err error) {
+	done := make(chan struct{})
+	stopped, err := heartbeat(registry, addr, duration, done)
+	var once sync.Once
+	stop = func(ctx context.Context) error {
+		once.Do(func() { close(done) })
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return DeregisterContext(ctx, registry, addr)
+	}
+	return stop, err
+}
+
+// heartbeat 立即发送一次心跳，之后在后台定期发送，直到 done 被关闭。
+// 返回后台 goroutine 退出时关闭的 channel，以及第一次心跳的错误
+func heartbeat(registry, addr string, duration time.Duration, done <-chan struct{}) (<-chan struct{}, error) {
+	if duration == 0 {
+		duration = defaultTimeout - time.Duration(1)*time.Minute
+	}
+	err := sendHeartbeat(registry, addr)
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		t := time.NewTicker(duration)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+			case <-done:
+				return
+			}
+			_ = sendHeartbeat(registry, addr) // 失败时已记录日志，注册中心恢复后下一次心跳会重新注册
+		}
+	}()
+	return exited, err
+}
+
+func sendHeartbeat(registry, addr string) error {
+	getLogger().Debugf("rpc server: %s send heart beat to registry %s", addr, registry)
+	if err := sendRequest(context.Background(), "POST", registry, addr); err != nil {
+		getLogger().Errorf("rpc server: heart beat err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Deregister 立即从注册中心注销 addr
+func Deregister(registry, addr string) error {
+	return DeregisterContext(context.Background(), registry, addr)
+}
+
+// DeregisterContext 与 Deregister 相同，ctx 结束时放弃注销
+func DeregisterContext(ctx context.Context, registry, addr string) error {
+	if err := sendRequest(ctx, "DELETE", registry, addr); err != nil {
+		getLogger().Errorf("rpc server: deregister err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func sendRequest(ctx context.Context, method, registry, addr string) error {
+	req, err := http.NewRequestWithContext(ctx, method, registry, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(serverHeader, addr)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("registry returned " + resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRegistryExpiresServers(t *testing.T) {
+	now := time.Now()
+	r := New(time.Minute)
+	r.now = func() time.Time { return now }
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	for _, addr := range []string{"tcp@a:1", "tcp@b:1"} {
+		if err := sendRequest(context.Background(), "POST", ts.URL, addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now = now.Add(40 * time.Second)
+	if err := sendRequest(context.Background(), "POST", ts.URL, "tcp@b:1"); err != nil { // 只有 b 发送心跳
+		t.Fatal(err)
+	}
+	now = now.Add(30 * time.Second)
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if servers := resp.Header.Get(serversHeader); servers != "tcp@b:1" {
+		t.Fatalf("expect only the live server to be listed, got %q", servers)
+	}
+}
+
+func TestRegistryRejectsBadRequests(t *testing.T) {
+	ts := httptest.NewServer(New(time.Minute))
+	defer ts.Close()
+	for _, method := range []string{"POST", "DELETE", "PUT"} {
+		req, _ := http.NewRequest(method, ts.URL, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode < 400 {
+			t.Fatalf("expect %s without a server address to fail, got %d", method, resp.StatusCode)
+		}
+	}
+}
+
+func TestStartHeartbeatDeregister(t *testing.T) {
+	r := New(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	stop, err := StartHeartbeat(ts.URL, "tcp@127.0.0.1:1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alive := r.aliveServers(); len(alive) != 1 {
+		t.Fatalf("expect the server to be registered, got %v", alive)
+	}
+	if err := stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if alive := r.aliveServers(); len(alive) != 0 {
+		t.Fatalf("expect the server to be removed immediately, got %v", alive)
+	}
+}
+
+func TestStopWaitsForInFlightHeartbeat(t *testing.T) {
+	r := New(time.Minute)
+	var posts atomic.Int32
+	inflight := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "POST" && posts.Add(1) == 2 { // 第二次心跳很慢
+			inflight <- struct{}{}
+			time.Sleep(50 * time.Millisecond)
+		}
+		r.ServeHTTP(w, req)
+	}))
+	defer ts.Close()
+
+	stop, err := StartHeartbeat(ts.URL, "tcp@127.0.0.1:1", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-inflight
+	if err := stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 注销在慢心跳之后发送，服务器不会被重新注册
+	time.Sleep(100 * time.Millisecond)
+	if alive := r.aliveServers(); len(alive) != 0 {
+		t.Fatalf("expect the server to stay deregistered, got %v", alive)
+	}
+}
+
+func TestHeartbeatRetriesAfterFailure(t *testing.T) {
+	r := New(time.Minute)
+	var posts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "POST" && posts.Add(1) <= 2 { // 前两次心跳失败
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		r.ServeHTTP(w, req)
+	}))
+	defer ts.Close()
+
+	stop, err := StartHeartbeat(ts.URL, "tcp@127.0.0.1:1", 20*time.Millisecond)
+	if err == nil {
+		t.Fatal("expect the first heartbeat to fail")
+	}
+	defer func() { _ = stop(context.Background()) }()
+	deadline := time.Now().Add(time.Second)
+	for len(r.aliveServers()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect the heartbeat to keep retrying, got %d posts", posts.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDeregisterContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done() // 注册中心一直不响应
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := DeregisterContext(ctx, ts.URL, "tcp@127.0.0.1:1"); err == nil {
+		t.Fatal("expect an error from a registry that never responds")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expect deregistration to give up with ctx, took %v", elapsed)
+	}
+}
+
+func TestSendRequestInvalidURL(t *testing.T) {
+	if err := sendRequest(context.Background(), "POST", "://bad", "tcp@127.0.0.1:1"); err == nil {
+		t.Fatal("expect an error for an invalid registry URL")
+	}
+}
@@ -0,0 +1,88 @@
+package Go_rpc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// 重放保护拒绝单向请求时的错误，单向请求没有响应，只记录在日志中
+var (
+	errMissingNonce = errors.New("rpc server: one-way request without nonce")
+	errStaleRequest = errors.New("rpc server: one-way request timestamp outside replay window")
+	errReplayed     = errors.New("rpc server: replayed one-way request")
+)
+
+// EnableReplayProtection 为单向请求启用重放保护，window <= 0 表示关闭（默认）。
+// 客户端为每个单向请求附带随机的 Nonce 和发送时间，服务端拒绝没有 Nonce 的请求、
+// 发送时间与本地时间相差超过 window 的请求，以及 window 内重复出现的 Nonce。
+// 被拒绝的请求不调用方法。双方的时钟偏差应远小于 window，普通调用不受影响。对之后建立的连接生效
+func (server *Server) EnableReplayProtection(window time.Duration) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if window <= 0 {
+		server.replayGuard = nil
+		return
+	}
+	server.replayGuard = newReplayGuard(window)
+}
+
+func (server *Server) getReplayGuard() *replayGuard {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.replayGuard
+}
+
+// replayGuard 记录 window 内出现过的 Nonce，所有连接共用，使重放到其他连接的请求同样被拒绝
+type replayGuard struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // Nonce -> 请求的发送时间
+	lastSweep time.Time
+}
+
+func newReplayGuard(window time.Duration) *replayGuard {
+	return &replayGuard{window: window, seen: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+// check 检查请求是否为重放，通过时记录 nonce
+func (g *replayGuard) check(nonce string, timestamp int64) error {
+	if nonce == "" {
+		return errMissingNonce
+	}
+	now := time.Now()
+	sent := time.Unix(0, timestamp)
+	if sent.Before(now.Add(-g.window)) || sent.After(now.Add(g.window)) {
+		return errStaleRequest
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now.Sub(g.lastSweep) >= g.window {
+		g.sweep(now)
+	}
+	if _, dup := g.seen[nonce]; dup {
+		return errReplayed
+	}
+	g.seen[nonce] = sent
+	return nil
+}
+
+// sweep 删除发送时间已超出 window 的 Nonce，重放这些请求会因时间戳过期而被拒绝
+func (g *replayGuard) sweep(now time.Time) {
+	for nonce, sent := range g.seen {
+		if sent.Before(now.Add(-g.window)) {
+			delete(g.seen, nonce)
+		}
+	}
+	g.lastSweep = now
+}
+
+// newNonce 返回单向请求使用的随机 Nonce
+func newNonce() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,48 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReplayProtection(t *testing.T) {
+	events := new(Events)
+	server, addr := startServer(t, events)
+	server.EnableReplayProtection(time.Minute)
+	logger := &captureLogger{}
+	server.SetLogger(logger)
+	cc := dialRaw(t, addr, DefaultOption)
+	send := func(seq uint64, nonce string, sent time.Time) {
+		t.Helper()
+		h := &codec.Header{ServiceMethod: "Events.Record", Seq: seq, OneWay: true, Nonce: nonce, Timestamp: sent.UnixNano()}
+		if err := cc.Write(h, Args{Num1: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(1, "n1", time.Now())
+	waitFor(t, "the first message to be handled", func() bool { return events.n.Load() == 1 })
+	send(2, "n1", time.Now()) // 原样重放
+	waitFor(t, "the replay to be rejected", func() bool { return logger.contains("replayed one-way request") })
+	send(3, "n2", time.Now().Add(-2*time.Minute))
+	waitFor(t, "the stale message to be rejected", func() bool { return logger.contains("outside replay window") })
+	send(4, "", time.Now())
+	waitFor(t, "the message without a nonce to be rejected", func() bool { return logger.contains("without nonce") })
+	send(5, "n3", time.Now())
+	waitFor(t, "the fresh message to be handled", func() bool { return events.n.Load() == 2 })
+
+	// 客户端的单向调用自动附带 Nonce，普通调用不受影响
+	client := dialServer(t, addr)
+	if err := client.Notify(context.Background(), "Events.Record", Args{Num1: 1}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the client notification to be handled", func() bool { return events.n.Load() == 3 })
+	if err := client.Call(context.Background(), "Events.Record", Args{Num1: 1}, new(int)); err != nil {
+		t.Fatalf("expect a normal call to be unaffected, got %v", err)
+	}
+	if n := events.n.Load(); n != 4 {
+		t.Fatalf("expect only the rejected messages to be dropped, got %d calls", n)
+	}
+}
@@ -0,0 +1,84 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"errors"
+	"io"
+	"net"
+)
+
+// DefaultErrorCode 是服务端错误不是 *RPCError（或 Code 为 0）时随响应发送的错误码
+const DefaultErrorCode = 500
+
+// RPCError 是带错误码的错误。方法返回 *RPCError（或包装了它的错误）时，
+// 错误码随响应发送，客户端的调用返回具有相同 Code 和 Message 的 *RPCError，可以通过 errors.As 取得
+type RPCError struct {
+	Code    int
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// As 使 errors.As(err, new(ServerError)) 对 *RPCError 同样成立，与其他服务端错误的判断方式一致
+func (e *RPCError) As(target interface{}) bool {
+	if se, ok := target.(*ServerError); ok {
+		*se = ServerError(e.Message)
+		return true
+	}
+	return false
+}
+
+func (e *RPCError) Is(target error) bool {
+	return target == ErrServer
+}
+
+// setHeaderError 将 err 写入响应头
+func setHeaderError(h *codec.Header, err error) {
+	h.Error, h.ErrorCode = err.Error(), DefaultErrorCode
+	var e *RPCError
+	if errors.As(err, &e) && e.Code != 0 {
+		h.ErrorCode = e.Code
+	}
+}
+
+// errorFromHeader 返回响应头中的服务端错误，没有错误码的响应（旧版本的服务端）返回 ServerError
+func errorFromHeader(h *codec.Header) error {
+	if h.ErrorCode == 0 {
+		return ServerError(h.Error)
+	}
+	return &RPCError{Code: h.ErrorCode, Message: h.Error}
+}
+
+// layerError 使 errors.Is(err, layer) 成立，错误信息与 err 相同
+type layerError struct {
+	err, layer error
+}
+
+func (e *layerError) Error() string {
+	return e.err.Error()
+}
+
+func (e *layerError) Unwrap() []error {
+	return []error{e.err, e.layer}
+}
+
+// withLayer 将 err 标记为属于 layer 层，err 为 nil 或已经标记过时原样返回
+func withLayer(err, layer error) error {
+	if err == nil || errors.Is(err, ErrCodec) || errors.Is(err, ErrTransport) {
+		return err
+	}
+	return &layerError{err: err, layer: layer}
+}
+
+// layerOf 返回编解码器读写错误所属的层：连接关闭或网络错误属于 ErrTransport，其他属于 ErrCodec
+func layerOf(err error) error {
+	var ne net.Error
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.ErrClosedPipe),
+		errors.Is(err, net.ErrClosed), errors.As(err, &ne):
+		return ErrTransport
+	}
+	return ErrCodec
+}
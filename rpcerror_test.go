@@ -0,0 +1,72 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Lookup 的方法返回带错误码的错误
+type Lookup struct{}
+
+func (Lookup) Find(key string, reply *string) error {
+	return &RPCError{Code: 404, Message: "not found"}
+}
+
+func (Lookup) Wrapped(key string, reply *string) error {
+	return fmt.Errorf("lookup %s: %w", key, &RPCError{Code: 403, Message: "forbidden"})
+}
+
+func TestRPCErrorCode(t *testing.T) {
+	_, addr := startServer(t, Lookup{})
+	client := dialServer(t, addr)
+	var reply string
+	var e *RPCError
+	err := client.Call(context.Background(), "Lookup.Find", "k", &reply)
+	if !errors.As(err, &e) || e.Code != 404 || e.Message != "not found" {
+		t.Fatalf("expect RPCError 404 not found, got %v", err)
+	}
+	var se ServerError
+	if !errors.Is(err, ErrServer) || !errors.As(err, &se) {
+		t.Fatalf("expect an RPCError to be a server error, got %v", err)
+	}
+
+	err = client.Call(context.Background(), "Lookup.Wrapped", "k", &reply)
+	if !errors.As(err, &e) || e.Code != 403 || e.Message != "lookup k: forbidden" {
+		t.Fatalf("expect the code of the wrapped RPCError, got %v", err)
+	}
+	// 其他错误使用默认的错误码
+	err = client.Call(context.Background(), "Foo.Div", Args{Num1: 1}, new(int))
+	if !errors.As(err, &e) || e.Code != DefaultErrorCode {
+		t.Fatalf("expect the default code %d, got %v", DefaultErrorCode, err)
+	}
+}
+
+func TestClientErrorLayers(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr)
+	layers := []error{ErrCodec, ErrTransport, ErrServer, ErrShutdown}
+	// expectLayer 检查 err 只属于 want 这一层
+	expectLayer := func(what string, err, want error) {
+		t.Helper()
+		for _, layer := range layers {
+			if errors.Is(err, layer) != (layer == want) {
+				t.Fatalf("%s: expect only %v, got %v", what, want, err)
+			}
+		}
+	}
+
+	expectLayer("method error", client.Call(context.Background(), "Foo.Div", Args{Num1: 1}, new(int)), ErrServer)
+	// 响应体无法解码为 reply 的类型
+	expectLayer("decode error", client.Call(context.Background(), "Foo.Sum", Args{Num1: 1}, new(string)), ErrCodec)
+	// 请求体无法编码
+	expectLayer("encode error", client.Call(context.Background(), "Foo.Sum", make(chan int), new(int)), ErrCodec)
+
+	_, err := Dial("tcp", deadAddr(t)) // 地址上没有服务端监听
+	expectLayer("dial error", err, ErrTransport)
+
+	closed := dialServer(t, addr)
+	_ = closed.Close()
+	expectLayer("closed client", closed.Call(context.Background(), "Foo.Sum", Args{}, new(int)), ErrShutdown)
+}
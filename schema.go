@@ -0,0 +1,115 @@
+package Go_rpc
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	reflectService = "__reflect"
+
+	// Describe 是描述方法参数和返回值类型的 ServiceMethod，参数为服务名（为空表示所有服务），
+	// 返回值为 *[]MethodSchema，可用于生成客户端代码和文档
+	Describe = reflectService + ".Describe"
+)
+
+// MethodSchema 描述一个方法的参数和返回值类型
+type MethodSchema struct {
+	Name   string      // "Service.Method"
+	Arg    *TypeSchema // 参数类型
+	Reply  *TypeSchema // 返回值指向的类型，流式方法的消息类型无法从签名得到，为 nil
+	Stream bool        // 是否为流式方法
+}
+
+// TypeSchema 是由反射得到的类型描述，格式类似 JSON Schema。
+// 指针被展开为其指向的类型；结构体只列出导出的字段，嵌入的结构体作为普通字段列出。
+// 递归的结构体在第二次出现时只填写 Kind 和 Type，Ref 为 true，字段见外层的同名类型
+type TypeSchema struct {
+	Kind   string        // reflect.Kind 的名称，如 "struct"、"slice"、"int"
+	Type   string        // 类型名，如 "main.Args"、"[]string"
+	Fields []FieldSchema `json:",omitempty"` // 结构体的字段，按声明顺序
+	Elem   *TypeSchema   `json:",omitempty"` // 数组、切片、map 的元素类型
+	Key    *TypeSchema   `json:",omitempty"` // map 的键类型
+	Ref    bool          `json:",omitempty"` // 是否为外层已展开的递归类型
+}
+
+// FieldSchema 描述结构体的一个字段
+type FieldSchema struct {
+	Name   string      // 字段名
+	Tag    string      `json:",omitempty"` // 字段的 tag
+	Schema *TypeSchema // 字段类型
+}
+
+// reflection 是 NewServer 自动注册的内置服务，返回已注册方法的类型描述
+type reflection struct {
+	server *Server
+}
+
+// Describe 返回已注册方法的类型描述，按名称排序。name 不为空时只返回该服务的方法
+func (r *reflection) Describe(name string, reply *[]MethodSchema) error {
+	*reply = r.server.schemas(name)
+	return nil
+}
+
+// schemas 返回已注册方法的类型描述，serviceName 为空时返回所有服务的方法，不包含内置服务
+func (server *Server) schemas(serviceName string) []MethodSchema {
+	schemas := []MethodSchema{}
+	server.serviceMap.Range(func(namei, svci interface{}) bool {
+		name := namei.(string)
+		if strings.HasPrefix(name, builtinPrefix) || (serviceName != "" && name != serviceName) {
+			return true
+		}
+		for methodName, m := range svci.(*service).method {
+			schema := MethodSchema{
+				Name:   name + "." + methodName,
+				Arg:    schemaOf(m.ArgType, nil),
+				Stream: m.stream,
+			}
+			if !m.stream {
+				schema.Reply = schemaOf(m.ReplyType, nil)
+			}
+			schemas = append(schemas, schema)
+		}
+		return true
+	})
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+	return schemas
+}
+
+// schemaOf 返回 typ 的描述，visiting 记录正在展开的结构体，用于发现递归类型
+func schemaOf(typ reflect.Type, visiting map[reflect.Type]bool) *TypeSchema {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	schema := &TypeSchema{Kind: typ.Kind().String(), Type: typ.String()}
+	switch typ.Kind() {
+	case reflect.Struct:
+		if visiting[typ] {
+			schema.Ref = true
+			return schema
+		}
+		if visiting == nil {
+			visiting = make(map[reflect.Type]bool)
+		}
+		visiting[typ] = true
+		defer delete(visiting, typ)
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			schema.Fields = append(schema.Fields, FieldSchema{
+				Name:   field.Name,
+				Tag:    string(field.Tag),
+				Schema: schemaOf(field.Type, visiting),
+			})
+		}
+	case reflect.Array, reflect.Slice:
+		schema.Elem = schemaOf(typ.Elem(), visiting)
+	case reflect.Map:
+		schema.Key = schemaOf(typ.Key(), visiting)
+		schema.Elem = schemaOf(typ.Elem(), visiting)
+	}
+	return schema
+}
@@ -0,0 +1,85 @@
+package Go_rpc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Customer struct {
+	Name  string
+	Email string
+}
+
+type Item struct {
+	SKU   string
+	Count int
+}
+
+// Order 包含嵌套结构体、切片、map、未导出字段和递归引用
+type Order struct {
+	ID       int64
+	Customer Customer
+	Items    []Item
+	Notes    map[string]string
+	Parent   *Order `json:"parent"`
+	secret   string
+}
+
+type Shop struct{}
+
+func (Shop) Place(order Order, reply *bool) error {
+	*reply = order.secret == ""
+	return nil
+}
+
+// fieldNames 返回 schema 中字段的名称
+func fieldNames(schema *TypeSchema) []string {
+	var names []string
+	for _, f := range schema.Fields {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestDescribe(t *testing.T) {
+	_, addr := startServer(t, Shop{})
+	client := dialServer(t, addr)
+	var schemas []MethodSchema
+	if err := client.Call(context.Background(), Describe, "Shop", &schemas); err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 1 || schemas[0].Name != "Shop.Place" {
+		t.Fatalf("expect only Shop.Place, got %+v", schemas)
+	}
+	arg, reply := schemas[0].Arg, schemas[0].Reply
+	if arg.Kind != "struct" || arg.Type != "Go_rpc.Order" || reply.Kind != "bool" {
+		t.Fatalf("expect Place(Go_rpc.Order, *bool), got %s, %s", arg.Type, reply.Type)
+	}
+	if got := fieldNames(arg); !reflect.DeepEqual(got, []string{"ID", "Customer", "Items", "Notes", "Parent"}) {
+		t.Fatalf("expect the exported fields of Order in order, got %v", got)
+	}
+	customer, items, notes, parent := arg.Fields[1], arg.Fields[2], arg.Fields[3], arg.Fields[4]
+	if got := fieldNames(customer.Schema); !reflect.DeepEqual(got, []string{"Name", "Email"}) {
+		t.Fatalf("expect the nested Customer fields, got %v", got)
+	}
+	if items.Schema.Kind != "slice" || !reflect.DeepEqual(fieldNames(items.Schema.Elem), []string{"SKU", "Count"}) {
+		t.Fatalf("expect a slice of Item, got %+v", items.Schema)
+	}
+	if notes.Schema.Key.Kind != "string" || notes.Schema.Elem.Kind != "string" {
+		t.Fatalf("expect map[string]string, got %+v", notes.Schema)
+	}
+	if !parent.Schema.Ref || parent.Schema.Type != "Go_rpc.Order" || parent.Schema.Fields != nil || parent.Tag != `json:"parent"` {
+		t.Fatalf("expect the recursive Parent to be a reference with its tag, got %+v, %q", parent.Schema, parent.Tag)
+	}
+
+	if err := client.Call(context.Background(), Describe, "", &schemas); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range schemas {
+		if strings.HasPrefix(s.Name, builtinPrefix) {
+			t.Fatalf("expect builtin services to be hidden, got %s", s.Name)
+		}
+	}
+}
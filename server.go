@@ -6,150 +6,1221 @@ package Go_rpc
 
 import (
 	"Go-rpc/codec"
+	"Go-rpc/registry"
+	"bufio"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
+	"net/http"
+	"os"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 const MagicNumber = 0x3bef5c // 定义魔数
 
 // Option 结构体包含 RPC 选项
 type Option struct {
-	MagicNumber int        // MagicNumber 用于标识这是一个 Gorpc 请求
-	CodecType   codec.Type // 客户端可以选择不同的编码器来编码主体
+	MagicNumber    int                // MagicNumber 用于标识这是一个 Gorpc 请求
+	CodecType      codec.Type         // 客户端可以选择不同的编码器来编码主体
+	ConnectTimeout time.Duration      // 建立连接及握手的超时时间，0 表示不限制
+	HandleTimeout  time.Duration      // 服务端处理单个请求的超时时间，0 表示不限制
+	CallTimeout    time.Duration      // 客户端每次调用的默认超时时间，ctx 的截止时间更早时以 ctx 为准，0 表示不限制
+	TLSConfig      *tls.Config        `json:"-"` // 客户端的 TLS 配置，不为 nil 时通过 TLS 连接
+	Framed         bool               // 是否使用带长度前缀的分帧编码，双方需一致
+	MaxBodySize    int64              // 消息体的最大字节数，0 表示不限制；不为 0 时总是使用分帧编码
+	Compressor     codec.CompressType // 消息体的压缩算法，为空表示不压缩；不为空时总是使用分帧编码
+	// FallbackCodecs 不为空时启用编码协商：服务端按顺序从 CodecType 和 FallbackCodecs 中
+	// 选择第一个支持的编码并告知客户端，在协商超时内没有回复的旧服务端视为只支持 gob
+	FallbackCodecs []codec.Type `json:"-"`
+	Token          string       // 随 Option 发送的认证令牌，服务端通过 SetAuthFunc 校验
+	// OrderedResponses 要求服务端按请求的发送顺序返回响应，请求仍然并发处理。
+	// 心跳和流式方法的中间消息不受影响，流式方法的结束标记按顺序发送
+	OrderedResponses bool
+	// Dialer 不为 nil 时代替默认的 net.Dialer 建立连接，可用于代理、绑定源地址或内存连接，
+	// ctx 在 ConnectTimeout 后超时
+	Dialer func(ctx context.Context, network, address string) (net.Conn, error) `json:"-"`
+	// SeqGenerator 不为 nil 时代替默认的递增计数器为请求分配序列号，可用于测试中得到确定的序列号
+	SeqGenerator SeqGenerator `json:"-"`
 }
 
 // 默认选项
 var DefaultOption = &Option{
-	MagicNumber: MagicNumber,
-	CodecType:   codec.GobType,
+	MagicNumber:    MagicNumber,
+	CodecType:      codec.GobType,
+	ConnectTimeout: time.Second * 10,
+}
+
+// newCodecFunc 根据 Option 返回编解码器的构造函数，服务端和客户端使用相同的规则
+func newCodecFunc(opt *Option) (codec.NewCodecFunc, error) {
+	if opt.Framed || opt.MaxBodySize > 0 || opt.Compressor != "" {
+		// 限制消息体大小和压缩都需要完整的消息体，因此总是使用分帧编码
+		m := codec.GetMarshaler(opt.CodecType)
+		if m == nil {
+			return nil, fmt.Errorf("framing is not supported by codec type %s", opt.CodecType)
+		}
+		cfg := codec.FramedConfig{MaxBodySize: opt.MaxBodySize}
+		if opt.Compressor != "" {
+			if cfg.Compressor = codec.GetCompressor(opt.Compressor); cfg.Compressor == nil {
+				return nil, fmt.Errorf("invalid compressor %s", opt.Compressor)
+			}
+		}
+		return func(conn io.ReadWriteCloser) codec.Codec {
+			return codec.NewFramedCodec(conn, m, cfg)
+		}, nil
+	}
+	f := codec.GetCodecFunc(opt.CodecType)
+	if f == nil {
+		return nil, fmt.Errorf("invalid codec type %s", opt.CodecType)
+	}
+	return f, nil
 }
 
 // Server 表示一个 RPC 服务器
-type Server struct{}
+type Server struct {
+	serviceMap sync.Map // 服务名 -> *service
+	codecReqs  sync.Map // 服务名 -> []codec.Type，由 RequireCodec 设置
+
+	inShutdown atomic.Bool                     // 是否已调用 Shutdown
+	notServing atomic.Bool                     // Shutdown 已开始，健康检查返回 NOT_SERVING
+	mu         sync.Mutex                      // 保护以下字段
+	listeners  map[net.Listener]struct{}       // Accept 中的监听器
+	activeConn map[io.Closer]*inflightRequests // 正在服务的连接，ServeCodecDirect 记录编解码器及其正在处理的请求
+	connSem    chan struct{}                   // 限制同时服务的连接数，nil 表示不限制
+	rejectFull bool                            // 连接数已满时直接关闭新连接而不是等待
+	acceptRate int                             // Accept 每秒最多接受的连接数，0 表示不限制
+
+	handshakeTimeout time.Duration           // 读取 Option 的超时时间，0 表示不限制，由 mu 保护
+	idleTimeout      time.Duration           // 连接上两次请求之间的最长空闲时间，0 表示不限制，由 mu 保护
+	drainTimeout     time.Duration           // 连接关闭前等待请求处理完成的最长时间，0 表示一直等待，由 mu 保护
+	readTimeout      time.Duration           // 读取一个请求的最长时间，0 表示不限制，由 mu 保护
+	writeTimeout     time.Duration           // 每次写入连接的最长时间，0 表示不限制，由 mu 保护
+	authFunc         func(token string) bool // 校验客户端的 Option.Token，nil 表示不校验，由 mu 保护
+	authorizer       Authorizer              // 方法级别的访问控制，nil 表示不检查，由 mu 保护
+	workers          *workerPool             // 处理请求的 worker 池，nil 表示每个请求使用一个 goroutine，由 mu 保护
+	maxInflight      int                     // 每个连接同时处理的最大请求数，0 表示不限制，由 mu 保护
+	onShutdown       []func()                // Shutdown 时执行的回调，由 mu 保护
+	flush            flushPolicy             // 响应的批量刷新策略，零值表示每个响应都立即写入，由 mu 保护
+	servingStatus    map[string]bool         // SetServingStatus 设置的健康状态，由 mu 保护
+	healthGrace      time.Duration           // Shutdown 在健康状态变为 NOT_SERVING 后等待的时间，由 mu 保护
+	replayGuard      *replayGuard            // 单向请求的重放保护，nil 表示不检查，由 mu 保护
 
-// NewServer 返回一个新的 Server 实例
+	connCtxFunc func(ctx context.Context, nc net.Conn) context.Context // 为连接级别的 context 添加值，由 mu 保护
+	rateLimiter func(identity string) bool                             // 按调用方标识限流，nil 表示不限流，由 mu 保护
+	deregisters []func(ctx context.Context) error                      // Shutdown 时停止心跳并从注册中心注销，由 mu 保护
+
+	interceptors []Interceptor // 服务端拦截器，由 mu 保护
+
+	stats *serverStats // 请求统计
+	log   atomic.Value // 服务端单独设置的日志，存放 loggerHolder
+
+	maxInflightTotal atomic.Int64 // 所有连接上同时处理的最大请求数，0 表示不限制
+	inflightTotal    atomic.Int64 // 所有连接上正在处理的请求数
+}
+
+// NewServer 返回一个新的 Server 实例，并注册内置的 ListMethods、Describe 和 HealthCheck 服务
 func NewServer() *Server {
-	return &Server{}
+	server := &Server{handshakeTimeout: defaultHandshakeTimeout}
+	server.stats = newServerStats(server.isRegistered)
+	_ = server.RegisterName(introspectService, &introspection{server: server})
+	_ = server.RegisterName(reflectService, &reflection{server: server})
+	_ = server.RegisterName(healthService, &health{server: server})
+	return server
 }
 
 // DefaultServer 是默认的 *Server 实例
 var DefaultServer = NewServer()
 
+// Register 将 rcvr 的所有符合条件的方法注册为服务，服务名为 rcvr 的类型名
+func (server *Server) Register(rcvr interface{}) error {
+	return server.RegisterName("", rcvr)
+}
+
+// RegisterName 与 Register 相同，但使用 name 作为服务名，完全取代类型名。
+// 同一类型的多个实例可以用不同的名称分别注册
+func (server *Server) RegisterName(name string, rcvr interface{}) error {
+	s, err := newService(name, rcvr)
+	if err != nil {
+		return err
+	}
+	if _, dup := server.serviceMap.LoadOrStore(s.name, s); dup {
+		if name == "" {
+			return errors.New("rpc: service already defined: " + s.name + " (use RegisterName to register another instance)")
+		}
+		return errors.New("rpc: service already defined: " + s.name)
+	}
+	return nil
+}
+
+// RegisterFunc 将函数 fn 注册为 name（形如 "Service.Method"）对应的方法，
+// fn 必须形如 func(arg T1, reply *T2) error 或 func(arg T1, stream ServerStream) error，
+// 可以在最前面增加一个 context.Context 参数以取得请求的上下文。
+// 同一服务名下可以注册多个函数，但不能与 Register 注册的服务同名
+func (server *Server) RegisterFunc(name string, fn interface{}) error {
+	dot := strings.LastIndex(name, ".")
+	if dot <= 0 || dot == len(name)-1 {
+		return errors.New("rpc: RegisterFunc: name must be Service.Method: " + strconv.Quote(name))
+	}
+	serviceName, methodName := name[:dot], name[dot+1:]
+	s, err := newFuncService(serviceName, methodName, fn)
+	if err != nil {
+		return err
+	}
+	for {
+		old, loaded := server.serviceMap.LoadOrStore(serviceName, s)
+		if !loaded {
+			return nil
+		}
+		oldSvc := old.(*service)
+		if oldSvc.rcvr.IsValid() {
+			return errors.New("rpc: service already defined: " + serviceName)
+		}
+		if oldSvc.method[methodName] != nil {
+			return errors.New("rpc: method already defined: " + name)
+		}
+		// 服务可能正在被并发查找，复制一份方法表后替换整个服务
+		merged := &service{name: serviceName, typ: oldSvc.typ, method: make(map[string]*methodType, len(oldSvc.method)+1)}
+		for n, m := range oldSvc.method {
+			merged.method[n] = m
+		}
+		merged.method[methodName] = s.method[methodName]
+		if server.serviceMap.CompareAndSwap(serviceName, old, merged) {
+			return nil
+		}
+	}
+}
+
+// RequireCodec 要求服务 serviceName 只能通过 types 中的编解码器调用，
+// 通过其他编解码器的连接发来的调用不读取参数，直接回复说明所需编解码器的错误。
+// 适用于参数只能用特定编码（如 protobuf）表示的服务。types 为空时取消限制，
+// 可以在注册服务之前或之后调用
+func (server *Server) RequireCodec(serviceName string, types ...codec.Type) {
+	if len(types) == 0 {
+		server.codecReqs.Delete(serviceName)
+		return
+	}
+	server.codecReqs.Store(serviceName, append([]codec.Type(nil), types...))
+}
+
+// checkServiceCodec 检查服务 serviceName 能否通过编解码器 t 调用
+func (server *Server) checkServiceCodec(serviceName string, t codec.Type) error {
+	v, ok := server.codecReqs.Load(serviceName)
+	if !ok {
+		return nil
+	}
+	types := v.([]codec.Type)
+	for _, required := range types {
+		if required == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("rpc server: service %s requires codec %v, but the connection uses %s", serviceName, types, t)
+}
+
+// Alias 将 newName（形如 "Service.Method"）注册为已有方法 existingServiceMethod 的别名，
+// 两个名称调用同一个方法，共享调用计数。newName 的服务不存在时新建一个只包含别名的服务，
+// 已存在时必须是目标方法所在的服务，或与其使用同一个接收者
+func (server *Server) Alias(newName, existingServiceMethod string) error {
+	target, mtype, err := server.findService(existingServiceMethod)
+	if err != nil {
+		return err
+	}
+	dot := strings.LastIndex(newName, ".")
+	if dot <= 0 || dot == len(newName)-1 {
+		return errors.New("rpc: Alias: name must be Service.Method: " + strconv.Quote(newName))
+	}
+	serviceName, methodName := newName[:dot], newName[dot+1:]
+	for {
+		old, loaded := server.serviceMap.Load(serviceName)
+		if !loaded {
+			s := &service{name: serviceName, typ: target.typ, rcvr: target.rcvr, method: map[string]*methodType{methodName: mtype}}
+			if _, loaded = server.serviceMap.LoadOrStore(serviceName, s); !loaded {
+				return nil
+			}
+			continue
+		}
+		oldSvc := old.(*service)
+		if oldSvc.name != target.name && !sameReceiver(oldSvc.rcvr, target.rcvr) {
+			return errors.New("rpc: Alias: service " + serviceName + " has a different receiver than " + target.name)
+		}
+		if oldSvc.method[methodName] != nil {
+			return errors.New("rpc: method already defined: " + newName)
+		}
+		// 与 RegisterFunc 相同，复制一份方法表后替换整个服务
+		merged := &service{name: serviceName, typ: oldSvc.typ, rcvr: oldSvc.rcvr, method: make(map[string]*methodType, len(oldSvc.method)+1)}
+		for n, m := range oldSvc.method {
+			merged.method[n] = m
+		}
+		merged.method[methodName] = mtype
+		if server.serviceMap.CompareAndSwap(serviceName, old, merged) {
+			return nil
+		}
+	}
+}
+
+// sameReceiver 返回两个服务是否使用同一个接收者，只包含函数的服务没有接收者，视为相同
+func sameReceiver(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	return a.Type() == b.Type() && a.Kind() == reflect.Ptr && a.Pointer() == b.Pointer()
+}
+
+// Register 在 DefaultServer 上注册服务
+func Register(rcvr interface{}) error { return DefaultServer.Register(rcvr) }
+
+// RegisterName 在 DefaultServer 上以指定名称注册服务
+func RegisterName(name string, rcvr interface{}) error { return DefaultServer.RegisterName(name, rcvr) }
+
+// RegisterFunc 在 DefaultServer 上注册函数
+func RegisterFunc(name string, fn interface{}) error { return DefaultServer.RegisterFunc(name, fn) }
+
+// Alias 在 DefaultServer 上注册方法的别名
+func Alias(newName, existingServiceMethod string) error {
+	return DefaultServer.Alias(newName, existingServiceMethod)
+}
+
+// findService 根据 "Service.Method" 查找服务和方法。
+// 以最后一个点分隔服务名和方法名，缺少点或任一部分为空时视为格式错误
+func (server *Server) findService(serviceMethod string) (svc *service, mtype *methodType, err error) {
+	dot := strings.LastIndex(serviceMethod, ".")
+	if dot <= 0 || dot == len(serviceMethod)-1 {
+		err = errors.New("rpc: service/method request ill-formed: " + serviceMethod)
+		return
+	}
+	serviceName, methodName := serviceMethod[:dot], serviceMethod[dot+1:]
+	svci, ok := server.serviceMap.Load(serviceName)
+	if !ok {
+		err = errors.New("rpc: can't find service " + serviceName)
+		return
+	}
+	svc = svci.(*service)
+	mtype = svc.method[methodName]
+	if mtype == nil {
+		err = errors.New("rpc: can't find method " + methodName)
+	}
+	return
+}
+
+// isRegistered 报告 serviceMethod 是否对应一个已注册的方法
+func (server *Server) isRegistered(serviceMethod string) bool {
+	_, _, err := server.findService(serviceMethod)
+	return err == nil
+}
+
 // ServeConn 在单个连接上运行服务器。
 // ServeConn 会阻塞，直到客户端断开连接
 func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 	defer func() { _ = conn.Close() }() // 确保在结束时关闭连接
-	var opt Option
-	if err := json.NewDecoder(conn).Decode(&opt); err != nil { // 解码选项
-		log.Println("rpc server: options error: ", err)
+	if !server.trackConn(conn, true) {  // 服务器正在关闭
+		return
+	}
+	defer server.trackConn(conn, false)
+	var hopt handshakeOption
+	// 限制 Option 的大小和读取时间，避免客户端发送超大或不完整的 Option 占用连接
+	nc, _ := conn.(net.Conn)
+	timeout := server.getHandshakeTimeout()
+	if nc != nil && timeout > 0 {
+		server.setReadDeadline(nc, timeout)
+	}
+	lr := &io.LimitedReader{R: conn, N: maxOptionSize}
+	dec := json.NewDecoder(lr)
+	if err := dec.Decode(&hopt); err != nil { // 解码选项
+		if lr.N <= 0 {
+			err = fmt.Errorf("option exceeds %d bytes", maxOptionSize)
+		}
+		server.logger().Errorf("rpc server: options error: %v", err)
 		return
 	}
+	opt := hopt.Option
+	idle := server.getIdleTimeout()
+	if idle == 0 { // 没有空闲超时时，等待请求的时间也受读取超时限制
+		idle = server.getReadTimeout()
+	}
+	if nc != nil && (timeout > 0 || idle > 0) {
+		// 清除握手的截止时间，或改为等待第一个请求的空闲超时
+		server.setReadDeadline(nc, idle)
+	}
 	if opt.MagicNumber != MagicNumber { // 验证魔数
-		log.Printf("rpc server: invalid magic number %x", opt.MagicNumber)
+		server.logger().Errorf("rpc server: invalid magic number %x", opt.MagicNumber)
 		return
 	}
-	f := codec.NewCodecFuncMap[opt.CodecType] // 根据 CodecType 获取编码器
-	if f == nil {
-		log.Printf("rpc server: invalid codec type %s", opt.CodecType)
+	if auth := server.getAuthFunc(); auth != nil && !auth(opt.Token) {
+		server.logger().Errorf("rpc server: authentication failed")
+		if werr := rejectConn(conn, "rpc server: authentication failed"); werr != nil {
+			server.logger().Errorf("rpc server: write handshake error: %v", werr)
+		}
 		return
 	}
-	server.serveCodec(f(conn)) // 使用选定的编码器处理连接
+	var f codec.NewCodecFunc
+	var err error
+	if len(hopt.Codecs) > 0 {
+		f, err = server.negotiate(conn, &opt, hopt.Codecs) // 与客户端协商编码器
+	} else {
+		f, err = newCodecFunc(&opt) // 根据 Option 获取编码器
+	}
+	if err != nil {
+		server.logger().Errorf("rpc server: codec error: %v", err)
+		// 无法使用客户端选择的编解码器，回复固定格式的错误帧，使客户端尽快失败
+		if werr := rejectConn(conn, "rpc server: "+err.Error()); werr != nil {
+			server.logger().Errorf("rpc server: write handshake error: %v", werr)
+		}
+		return
+	}
+	hc := newHandshakeConn(conn, dec)
+	if nc != nil {
+		hc.nc, hc.writeTimeout = nc, server.getWriteTimeout()
+	}
+	server.serveCodec(server.newServerCodec(f, hc), &opt, nc, idle, hc.peek) // 使用选定的编码器处理连接
+}
+
+const (
+	maxOptionSize           = 64 << 10         // Option 的最大字节数
+	defaultHandshakeTimeout = 10 * time.Second // 默认的 Option 读取超时时间
+)
+
+// SetHandshakeTimeout 设置读取客户端 Option 的超时时间，d <= 0 表示不限制，默认为 10 秒。
+// 只对 net.Conn 类型的连接生效
+func (server *Server) SetHandshakeTimeout(d time.Duration) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if d < 0 {
+		d = 0
+	}
+	server.handshakeTimeout = d
+}
+
+func (server *Server) getHandshakeTimeout() time.Duration {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.handshakeTimeout
+}
+
+// SetMaxInflightPerConn 限制每个连接上同时处理的请求数，n <= 0 表示不限制，默认不限制。
+// 达到上限时连接暂停读取新的请求，直到有请求已发送响应；因超时已回复的请求不再计入。
+// 暂停期间客户端断开时取消正在处理的请求；客户端发来的心跳不占用名额，仍会被读取并回复。
+// 对之后建立的连接生效
+func (server *Server) SetMaxInflightPerConn(n int) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	server.maxInflight = n
+}
+
+func (server *Server) getMaxInflightPerConn() int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.maxInflight
+}
+
+// errOverloaded 是超过 SetMaxInflight 的上限时回复的错误
+var errOverloaded = errors.New("rpc server: overloaded")
+
+// SetMaxInflight 限制所有连接上同时处理的请求总数，n <= 0 表示不限制，默认不限制。
+// 与 SetMaxInflightPerConn 不同，超过上限的请求不会等待，而是立即回复 "rpc server: overloaded" 错误；
+// 心跳不计入。立即对所有连接生效
+func (server *Server) SetMaxInflight(n int) {
+	if n < 0 {
+		n = 0
+	}
+	server.maxInflightTotal.Store(int64(n))
+}
+
+// acquireInflight 将全局的处理中请求数加一，超过上限时返回 false 且不计数
+func (server *Server) acquireInflight() bool {
+	n := server.inflightTotal.Add(1)
+	if limit := server.maxInflightTotal.Load(); limit > 0 && n > limit {
+		server.inflightTotal.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (server *Server) releaseInflight() {
+	server.inflightTotal.Add(-1)
+}
+
+// SetIdleTimeout 设置连接的空闲超时时间：等待下一个请求超过 d 时关闭连接，
+// 已在处理的请求仍会发送响应。d <= 0 表示不限制，默认不限制。
+// 只对 net.Conn 类型的连接生效，对之后建立的连接生效
+func (server *Server) SetIdleTimeout(d time.Duration) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if d < 0 {
+		d = 0
+	}
+	server.idleTimeout = d
+}
+
+// SetReadTimeout 设置读取一个请求的最长时间，从读完请求头开始计算，超时后关闭连接，
+// 防止客户端缓慢发送请求体长期占用连接。没有设置 SetIdleTimeout 时，等待下一个请求的时间也受 d 限制。
+// d <= 0 表示不限制，默认不限制。只对 net.Conn 类型的连接生效，对之后建立的连接生效
+func (server *Server) SetReadTimeout(d time.Duration) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.readTimeout = max(d, 0)
+}
+
+func (server *Server) getReadTimeout() time.Duration {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.readTimeout
+}
+
+// SetWriteTimeout 设置每次向连接写入响应的最长时间，客户端长时间不读取响应导致写入阻塞超过 d 时，
+// 写入失败并关闭连接。d <= 0 表示不限制，默认不限制。只对 net.Conn 类型的连接生效，对之后建立的连接生效
+func (server *Server) SetWriteTimeout(d time.Duration) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.writeTimeout = max(d, 0)
+}
+
+func (server *Server) getWriteTimeout() time.Duration {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.writeTimeout
+}
+
+// SetAuthFunc 设置连接的认证函数，握手时用客户端 Option 中的 Token 调用，
+// 返回 false 时回复握手错误并关闭连接。f 为 nil 表示不认证
+func (server *Server) SetAuthFunc(f func(token string) bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.authFunc = f
+}
+
+func (server *Server) getAuthFunc() func(token string) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.authFunc
+}
+
+// SetDrainTimeout 设置连接停止读取请求后等待正在处理的请求完成的最长时间，
+// 超过后记录仍未完成的请求并关闭连接，这些请求的 context 会被取消。
+// d <= 0 表示一直等待，默认一直等待
+func (server *Server) SetDrainTimeout(d time.Duration) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if d < 0 {
+		d = 0
+	}
+	server.drainTimeout = d
+}
+
+func (server *Server) getDrainTimeout() time.Duration {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.drainTimeout
+}
+
+func (server *Server) getIdleTimeout() time.Duration {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.idleTimeout
+}
+
+// setReadDeadline 将连接的读取截止时间设置为 d 之后，d 为 0 时清除截止时间。
+// 设置后再检查一次是否正在关闭，避免覆盖 Shutdown 设置的截止时间
+func (server *Server) setReadDeadline(nc net.Conn, d time.Duration) {
+	var t time.Time
+	if d > 0 {
+		t = time.Now().Add(d)
+	}
+	_ = nc.SetReadDeadline(t)
+	if server.shuttingDown() {
+		_ = nc.SetReadDeadline(time.Now())
+	}
+}
+
+// handshakeConn 让编解码器先读取解码 Option 时被 json.Decoder 预读的字节，
+// 客户端紧跟 Option 发送的第一个请求不会因此丢失。
+// nc 不为 nil 且 writeTimeout 不为 0 时，每次写入前设置连接的写入截止时间
+type handshakeConn struct {
+	io.ReadWriteCloser
+	mu sync.Mutex // 使 peek 与 Read 不会同时读取 r
+	r  *bufio.Reader
+
+	nc           net.Conn
+	writeTimeout time.Duration
+}
+
+func newHandshakeConn(conn io.ReadWriteCloser, dec *json.Decoder) *handshakeConn {
+	r := bufio.NewReader(io.MultiReader(dec.Buffered(), conn))
+	// json.Encoder 会在 Option 之后追加一个换行符，它不属于后续的消息
+	if b, err := r.Peek(1); err == nil && b[0] == '\n' {
+		_, _ = r.Discard(1)
+	}
+	return &handshakeConn{ReadWriteCloser: conn, r: r}
+}
+
+func (c *handshakeConn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.r.Read(p)
+}
+
+// peek 阻塞直到连接上有可读的数据或读取出错，不消费数据。
+// 期间的 Read 等待它返回，编解码器已缓冲的数据不受影响
+func (c *handshakeConn) peek() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.r.Peek(1)
+	return err
+}
+
+func (c *handshakeConn) Write(p []byte) (int, error) {
+	if c.nc != nil && c.writeTimeout > 0 {
+		_ = c.nc.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	return c.ReadWriteCloser.Write(p)
 }
 
 // invalidRequest 是一个占位符，用于响应 argv 时发生错误
 var invalidRequest = struct{}{}
 
-// serveCodec 处理编码器
-func (server *Server) serveCodec(cc codec.Codec) {
-	sending := new(sync.Mutex) // 确保发送完整响应
-	wg := new(sync.WaitGroup)  // 等待所有请求处理完成
+// serveCodec 处理编码器。
+// nc 不为 nil 且 idle 不为 0 时，每次读取请求前刷新连接的读取截止时间；
+// peek 不为 nil 时用于在等待请求名额期间探测连接，见 SetMaxInflightPerConn
+func (server *Server) serveCodec(cc codec.Codec, opt *Option, nc net.Conn, idle time.Duration, peek func() error) {
+	sending := new(sync.Mutex)        // 确保发送完整响应
+	inflight := newInflightRequests() // 等待所有请求处理完成
+	var queue *responseQueue          // 不为 nil 时按请求的读取顺序发送响应
+	if opt.OrderedResponses {
+		queue = newResponseQueue(server, cc, sending)
+	}
+	var slots chan struct{} // 限制同时处理的请求数，nil 表示不限制
+	if n := server.getMaxInflightPerConn(); n > 0 {
+		slots = make(chan struct{}, n)
+	}
+	release := func() {
+		if slots != nil {
+			<-slots
+		}
+	}
+	// 连接级别的 context，连接断开或等待超时后取消，使正在处理的请求可以提前结束
+	ctx, cancel := context.WithCancel(server.connContext(nc, opt))
+	defer cancel()
+	// acquire 为请求获取名额，返回是否已获取。达到上限时等待，等待期间探测连接，
+	// 客户端断开时取消连接级别的 context；readAhead 为 true 时客户端发来数据即返回 false，
+	// 由调用方先读取这个请求，使心跳不必等待名额
+	acquire := func(readAhead bool) bool {
+		if slots == nil {
+			return true
+		}
+		select {
+		case slots <- struct{}{}:
+			return true
+		default:
+		}
+		if peek == nil {
+			slots <- struct{}{}
+			return true
+		}
+		if nc != nil {
+			server.setReadDeadline(nc, 0) // 等待名额期间连接并不空闲
+		}
+		probe := make(chan error, 1)
+		go func() { probe <- peek() }()
+		select {
+		case slots <- struct{}{}:
+			return true // 探测在后台继续，之后的读取等待它返回
+		case err := <-probe:
+			if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+				cancel() // 客户端已断开，正在处理的请求可以提前结束
+			}
+			if readAhead {
+				return false
+			}
+			slots <- struct{}{}
+			return true
+		}
+	}
+	if nc == nil {
+		server.watchInflight(cc, inflight) // 没有 net.Conn 时 Shutdown 在请求处理完后关闭编解码器
+	}
+	defer server.stats.trackConn(cc, nc, opt)()
+	sizer := sizerOf(cc) // 编解码器不报告消息大小时为 nil
+	limiter := server.getRateLimiter()
+	var onHeader func() // 读完请求头后为请求体设置读取超时
+	if d := server.getReadTimeout(); nc != nil && d > 0 {
+		onHeader = func() { server.setReadDeadline(nc, d) }
+	}
+	replay := server.getReplayGuard()
+	identity := server.callerIdentity(ctx) // 调用方标识在连接建立时就已确定
+	// reject 不调用方法，直接以 err 回复请求
+	reject := func(req *request, err error) {
+		setHeaderError(req.h, err) // 设置错误信息
+		if req.h.OneWay {          // 单向请求不发送响应，错误只能记录在日志中
+			server.logger().Errorf("rpc server: one-way request %s failed: %v", req.h.ServiceMethod, err)
+		} else {
+			server.writeResponse(cc, req.h, invalidRequest, sending, queue.reserve()) // 发送响应
+		}
+		server.stats.record(req.h, 0, err)
+	}
 	for {
-		req, err := server.readRequest(cc) // 读取请求
+		acquired := acquire(true) // 达到上限时暂停读取，直到有请求处理完成或客户端发来下一个请求
+		if nc != nil && idle > 0 {
+			server.setReadDeadline(nc, idle)
+		}
+		req, err := server.readRequest(cc, opt.CodecType, onHeader) // 读取请求
+		if req == nil {
+			// 连接已断开时取消连接级别的 context，使正在处理的方法可以通过 ctx 提前结束，响应本就无法送达；
+			// 超时（空闲或 Shutdown）时仍等待请求处理完成
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				cancel()
+			}
+			break // 无法恢复，关闭连接
+		}
+		if req.ping { // 心跳由框架直接回复，不占用名额，不经过拦截器和统计
+			if acquired {
+				release()
+			}
+			server.sendResponse(cc, &codec.Header{ServiceMethod: pongMethod, Seq: req.h.Seq}, invalidRequest, sending)
+			continue
+		}
+		if !acquired {
+			acquire(false)
+		}
+		if err == nil && sizer != nil {
+			server.stats.recordSize(&server.stats.reqSize, req.h.ServiceMethod, sizer.BodySize())
+		}
 		if err != nil {
-			if req == nil {
-				break // 无法恢复，关闭连接
+			release()
+			reject(req, err)
+			continue
+		}
+		if limiter != nil && !limiter(identity) {
+			release()
+			reject(req, errRateLimited)
+			continue
+		}
+		if replay != nil && req.h.OneWay {
+			if err := replay.check(req.h.Nonce, req.h.Timestamp); err != nil {
+				release()
+				reject(req, err)
+				continue
 			}
-			req.h.Error = err.Error()                               // 设置错误信息
-			server.sendResponse(cc, req.h, invalidRequest, sending) // 发送响应
+		}
+		if !server.acquireInflight() { // 全局的处理中请求数已达上限，立即拒绝而不排队
+			release()
+			reject(req, errOverloaded)
 			continue
 		}
-		wg.Add(1)
-		go server.handleRequest(cc, req, sending, wg) // 处理请求
+		if !req.h.OneWay { // 单向请求没有响应，不占用顺序队列中的位置
+			req.slot = queue.reserve()
+		}
+		inflight.add(req)
+		server.dispatch(func(pooled bool) { // 处理请求，worker 池已满时阻塞，不再读取下一个请求
+			defer release()
+			defer server.releaseInflight()
+			server.handleRequest(ctx, cc, req, sending, inflight, opt.HandleTimeout, pooled)
+		})
+	}
+	// 等待所有处理完成，超过 drainTimeout 时放弃等待，避免卡住的方法使连接无法释放
+	if !inflight.wait(server.getDrainTimeout()) {
+		for _, name := range inflight.list() {
+			server.logger().Errorf("rpc server: abandon in-flight request %s after connection closed", name)
+		}
+		cancel()
 	}
-	wg.Wait()      // 等待所有处理完成
 	_ = cc.Close() // 关闭编码器
 }
 
+// inflightRequests 记录一个连接上正在处理的请求
+type inflightRequests struct {
+	wg   sync.WaitGroup
+	mu   sync.Mutex // 保护 reqs
+	reqs map[*request]struct{}
+}
+
+func newInflightRequests() *inflightRequests {
+	return &inflightRequests{reqs: make(map[*request]struct{})}
+}
+
+func (r *inflightRequests) add(req *request) {
+	r.wg.Add(1)
+	r.mu.Lock()
+	r.reqs[req] = struct{}{}
+	r.mu.Unlock()
+}
+
+func (r *inflightRequests) done(req *request) {
+	r.mu.Lock()
+	delete(r.reqs, req)
+	r.mu.Unlock()
+	r.wg.Done()
+}
+
+// idle 返回是否没有正在处理的请求
+func (r *inflightRequests) idle() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.reqs) == 0
+}
+
+// wait 等待所有请求处理完成，timeout 为 0 时一直等待，超时返回 false
+func (r *inflightRequests) wait(timeout time.Duration) bool {
+	if timeout <= 0 {
+		r.wg.Wait()
+		return true
+	}
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// list 返回正在处理的请求，格式为 "ServiceMethod#Seq"
+func (r *inflightRequests) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.reqs))
+	for req := range r.reqs {
+		names = append(names, fmt.Sprintf("%s#%d", req.h.ServiceMethod, req.h.Seq))
+	}
+	sort.Strings(names)
+	return names
+}
+
 // request 存储调用的所有信息
 type request struct {
 	h            *codec.Header // 请求头
 	argv, replyv reflect.Value // 请求参数和响应值
+	mtype        *methodType   // 请求对应的方法
+	svc          *service      // 请求对应的服务
+	ping         bool          // 是否为心跳请求
+	slot         *responseSlot // 按顺序发送响应时的位置，nil 表示完成后立即发送
 }
 
 // readRequestHeader 读取请求头
 func (server *Server) readRequestHeader(cc codec.Codec) (*codec.Header, error) {
 	var h codec.Header
 	if err := cc.ReadHeader(&h); err != nil { // 读取头部信息
-		if err != io.EOF && err != io.ErrUnexpectedEOF {
-			log.Println("rpc server: read header error:", err)
+		switch {
+		case err == io.EOF:
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			// 空闲超时或服务器正在关闭
+			server.logger().Debugf("rpc server: stop reading: %v", err)
+		default:
+			server.logIOError("rpc server: read header error: %v", err)
 		}
 		return nil, err
 	}
 	return &h, nil
 }
 
-// readRequest 读取请求
-func (server *Server) readRequest(cc codec.Codec) (*request, error) {
+// isDisconnect 返回 err 是否由连接断开引起，例如对端关闭或重置了连接、读写已关闭的连接。
+// 这类错误是客户端正常断开的结果，不需要记录为错误
+func isDisconnect(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// logIOError 记录连接读写的错误，连接断开引起的错误只记录为调试日志
+func (server *Server) logIOError(format string, err error) {
+	if isDisconnect(err) {
+		server.logger().Debugf(format, err)
+		return
+	}
+	server.logger().Errorf(format, err)
+}
+
+// readRequest 读取请求，onHeader 不为 nil 时在读完请求头后、读取请求体前调用
+func (server *Server) readRequest(cc codec.Codec, codecType codec.Type, onHeader func()) (*request, error) {
 	h, err := server.readRequestHeader(cc) // 读取请求头
 	if err != nil {
 		return nil, err
 	}
+	if onHeader != nil {
+		onHeader()
+	}
+	if h.ServiceMethod == pingMethod {
+		if err := cc.Discard(); err != nil {
+			return nil, err
+		}
+		return &request{h: h, ping: true}, nil
+	}
 	req := &request{h: h}
-	// TODO: 目前我们不知道请求参数的类型
-	// 第一天，假设它是字符串
-	req.argv = reflect.New(reflect.TypeOf(""))
-	if err = cc.ReadBody(req.argv.Interface()); err != nil { // 读取请求体
-		log.Println("rpc server: read argv err:", err)
+	req.svc, req.mtype, err = server.findService(h.ServiceMethod)
+	if err == nil {
+		err = server.checkServiceCodec(req.svc.name, codecType)
+	}
+	if err != nil {
+		// 请求体尚未读取，需要跳过它，否则下一个 header 会读到请求体的内容
+		if derr := cc.Discard(); derr != nil {
+			server.logIOError("rpc server: discard body error: %v", derr)
+		}
+		return req, err
+	}
+	req.argv = req.mtype.newArgv()
+	if !req.mtype.stream { // 流式方法的 ServerStream 在处理请求时创建
+		req.replyv = req.mtype.newReplyv()
+	}
+
+	// ReadBody 需要指针，argv 为值类型时取其地址
+	argvi := req.argv.Interface()
+	if req.argv.Type().Kind() != reflect.Ptr {
+		argvi = req.argv.Addr().Interface()
+	}
+	// 编解码器在解码失败时已消费掉整个消息体，返回 req 以便针对该序列号回复错误，连接继续可用
+	if err = cc.ReadBody(argvi); err != nil { // 读取请求体
+		server.logIOError("rpc server: read argv err: %v", err)
+		return req, err
 	}
 	return req, nil
 }
 
 // sendResponse 发送响应
 func (server *Server) sendResponse(cc codec.Codec, h *codec.Header, body interface{}, sending *sync.Mutex) {
+	if h.Error != "" && h.ErrorCode == 0 { // 框架产生的错误（如超时）使用默认错误码
+		h.ErrorCode = DefaultErrorCode
+	}
 	sending.Lock()
 	defer sending.Unlock()                    // 释放锁
 	if err := cc.Write(h, body); err != nil { // 写入响应
-		log.Println("rpc server: write response error:", err)
+		server.logIOError("rpc server: write response error: %v", err)
+	} else if sizer := sizerOf(cc); sizer != nil && h.ServiceMethod != pongMethod {
+		server.stats.recordSize(&server.stats.respSize, h.ServiceMethod, sizer.WriteSize())
 	}
 }
 
-// handleRequest 处理请求
-func (server *Server) handleRequest(cc codec.Codec, req *request, sending *sync.Mutex, wg *sync.WaitGroup) {
-	// TODO: 应该调用注册的 RPC 方法以获取正确的响应
-	// 第一天，只打印 argv 并发送一个 hello 消息
-	defer wg.Done() // 完成后减少计数
-	log.Println(req.h, req.argv.Elem())
-	req.replyv = reflect.ValueOf(fmt.Sprintf("geerpc resp %d", req.h.Seq)) // 生成响应
-	server.sendResponse(cc, req.h, req.replyv.Interface(), sending)        // 发送响应
+// handleRequest 处理请求。
+// timeout 不为 0 时，方法调用加上发送响应需在 timeout 内完成，否则回复超时错误，
+// 每个请求只会回复一次，超时后方法返回的结果会被丢弃。
+// pooled 为 true 表示在 worker 池中执行，此时直接在当前 goroutine 中调用方法，方法返回后才返回
+func (server *Server) handleRequest(ctx context.Context, cc codec.Codec, req *request, sending *sync.Mutex, inflight *inflightRequests, timeout time.Duration, pooled bool) {
+	defer inflight.done(req) // 完成后减少计数
+	start := time.Now()
+	server.stats.begin()
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+	// 客户端传来的截止时间与 HandleTimeout 同时生效，以先到者为准。
+	// 截止时间由客户端的时钟计算，双方时钟偏差较大时会提前或推迟超时
+	var deadline time.Time
+	if req.h.Deadline != 0 {
+		deadline = time.Unix(0, req.h.Deadline)
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithDeadline(ctx, deadline)
+		defer cancelDeadline()
+	}
+	if req.h.TraceID != "" || req.h.SpanID != "" {
+		ctx = ContextWithTrace(ctx, req.h.TraceID, req.h.SpanID)
+	}
+	if req.h.Meta != nil {
+		ctx = context.WithValue(ctx, metaKey{}, req.h.Meta)
+	}
+	var stream *serverStream
+	if req.mtype.stream {
+		stream = newServerStream(ctx, server, cc, req.h, sending)
+		req.replyv = reflect.ValueOf(stream)
+	}
+	var once sync.Once
+	finish := func(h *codec.Header, body interface{}, err error, send bool) {
+		once.Do(func() {
+			if stream != nil {
+				// 等待正在发送的消息完成，之后的 Send 都会失败，再发送结束标记
+				stream.close()
+				h.StreamEnd = true
+			}
+			switch {
+			case send && req.h.OneWay: // 单向请求不发送响应，错误只能记录在日志中
+				if err != nil {
+					server.logger().Errorf("rpc server: one-way request %s failed: %v", req.h.ServiceMethod, err)
+				}
+			case send:
+				server.writeResponse(cc, h, body, sending, req.slot)
+			}
+			server.stats.end(h, time.Since(start), err)
+		})
+	}
+	respond := func(h *codec.Header, body interface{}, err error) {
+		finish(h, body, err, true)
+	}
+	// 超时响应使用单独的请求头，避免与仍在执行的方法竞争 req.h
+	timeoutHeader := &codec.Header{ServiceMethod: req.h.ServiceMethod, Seq: req.h.Seq}
+	deadlineExceeded := func() bool { return !deadline.IsZero() && !time.Now().Before(deadline) }
+	if deadlineExceeded() { // 客户端已经放弃等待，不再调用方法，例如请求在 worker 池中排队时已经超时
+		server.logger().Debugf("rpc server: drop stale request %s, deadline exceeded %v ago",
+			req.h.ServiceMethod, time.Since(deadline))
+		timeoutHeader.Error = "rpc server: request deadline exceeded"
+		respond(timeoutHeader, invalidRequest, errors.New(timeoutHeader.Error))
+		return
+	}
+	// abort 在 ctx 结束时回复超时错误，连接已断开时不再回复。
+	// 可能同时在方法的 goroutine 和下面的 select 中调用，只执行一次
+	var abortOnce sync.Once
+	abort := func() {
+		abortOnce.Do(func() {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				timeoutHeader.Error = "rpc server: request handle timeout"
+				if deadlineExceeded() {
+					timeoutHeader.Error = "rpc server: request deadline exceeded"
+				}
+				respond(timeoutHeader, invalidRequest, errors.New(timeoutHeader.Error))
+				return
+			}
+			// 连接已断开，不再发送响应，方法返回的结果会被丢弃
+			finish(timeoutHeader, nil, ctx.Err(), false)
+		})
+	}
+	// call 鉴权后调用方法并回复
+	call := func() {
+		var reply interface{}
+		err := server.authorize(ctx, req.h.ServiceMethod)
+		if err == nil && ctx.Err() != nil { // 调用方法前已经超时或连接已断开
+			abort()
+			return
+		}
+		if err == nil {
+			reply, err = server.invoke(ctx, req) // 经过拦截器调用注册的方法
+		}
+		if err != nil {
+			setHeaderError(req.h, err)
+			respond(req.h, invalidRequest, err)
+			return
+		}
+		if reply == nil { // 拦截器可能不返回响应体
+			reply = invalidRequest
+		}
+		respond(req.h, reply, nil) // 发送响应
+	}
+	if pooled {
+		// 在 worker 中直接调用方法，不再为每个请求启动 goroutine，超时仍按时回复。
+		// worker 在方法返回后才空闲，同时执行的方法数不超过 worker 数
+		stop := context.AfterFunc(ctx, abort)
+		call()
+		stop()
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		call()
+	}()
+
+	select {
+	case <-ctx.Done():
+		abort()
+	case <-done:
+	}
 }
 
+// acceptMaxBackoff 是 Accept 遇到临时错误时退避等待的最长时间
+const acceptMaxBackoff = time.Second
+
 // Accept 在监听器上接受连接并处理请求
-// 为每个传入的连接提供服务
+// 为每个传入的连接提供服务，Shutdown 会关闭 lis 并使 Accept 返回。
+// 遇到临时错误（net.Error 的 Temporary() 为 true）时按指数退避等待后重试，最长等待 1s
 func (server *Server) Accept(lis net.Listener) {
+	if !server.trackListener(lis, true) {
+		_ = lis.Close()
+		return
+	}
+	defer server.trackListener(lis, false)
+	var interval time.Duration // 两次接受连接之间的最短间隔
+	if rate := server.getMaxAcceptsPerSecond(); rate > 0 {
+		interval = time.Second / time.Duration(rate)
+	}
+	var backoff time.Duration
+	var last time.Time
 	for {
+		if interval > 0 {
+			if wait := time.Until(last.Add(interval)); wait > 0 {
+				time.Sleep(wait)
+			}
+			last = time.Now()
+		}
 		conn, err := lis.Accept() // 接受连接
 		if err != nil {
-			log.Println("rpc server: accept error:", err)
+			if server.shuttingDown() {
+				return
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Temporary() {
+				if backoff == 0 {
+					backoff = 5 * time.Millisecond
+				} else if backoff *= 2; backoff > acceptMaxBackoff {
+					backoff = acceptMaxBackoff
+				}
+				server.logger().Errorf("rpc server: accept error: %v; retrying in %v", err, backoff)
+				time.Sleep(backoff)
+				continue
+			}
+			server.logger().Errorf("rpc server: accept error: %v", err)
 			return
 		}
-		go server.ServeConn(conn) // 并发处理连接
+		backoff = 0
+		sem, ok := server.acquireConn()
+		if !ok {
+			server.logger().Errorf("rpc server: too many connections, reject %s", conn.RemoteAddr())
+			_ = conn.Close()
+			continue
+		}
+		go func() {
+			defer server.releaseConn(sem)
+			server.ServeConn(conn) // 并发处理连接
+		}()
+	}
+}
+
+// ListenAndServe 在 network 和 address 上监听，并在后台 goroutine 中调用 Accept，返回实际监听的地址。
+// address 使用端口 0 时由系统选择端口，返回的地址可以直接用于 Dial 或注册到注册中心。
+// 监听器由 Shutdown 关闭
+func (server *Server) ListenAndServe(network, address string) (net.Addr, error) {
+	lis, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	go server.Accept(lis)
+	return lis.Addr(), nil
+}
+
+// ListenAndServe 使用 DefaultServer 监听并在后台处理连接
+func ListenAndServe(network, address string) (net.Addr, error) {
+	return DefaultServer.ListenAndServe(network, address)
+}
+
+// SetMaxConns 限制 Accept 同时服务的连接数，n <= 0 表示不限制。
+// 达到上限时 Accept 会等待已有连接结束，应在 Accept 之前调用
+func (server *Server) SetMaxConns(n int) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if n <= 0 {
+		server.connSem = nil
+		return
+	}
+	server.connSem = make(chan struct{}, n)
+}
+
+// SetMaxAcceptsPerSecond 限制 Accept 每秒最多接受 n 个连接，超出时推迟接受，
+// 连接在系统的监听队列中等待，n <= 0 表示不限制。应在 Accept 之前调用
+func (server *Server) SetMaxAcceptsPerSecond(n int) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.acceptRate = max(n, 0)
+}
+
+func (server *Server) getMaxAcceptsPerSecond() int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.acceptRate
+}
+
+// SetRejectWhenFull 设置连接数达到上限时是否直接关闭新连接，而不是等待
+func (server *Server) SetRejectWhenFull(reject bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.rejectFull = reject
+}
+
+// acquireConn 获取一个连接名额，返回获取到的信号量以便释放。
+// 设置了 rejectFull 且名额已满时返回 false
+func (server *Server) acquireConn() (chan struct{}, bool) {
+	server.mu.Lock()
+	sem, reject := server.connSem, server.rejectFull
+	server.mu.Unlock()
+	if sem == nil {
+		return nil, true
+	}
+	if !reject {
+		sem <- struct{}{}
+		return sem, true
+	}
+	select {
+	case sem <- struct{}{}:
+		return sem, true
+	default:
+		return nil, false
+	}
+}
+
+func (server *Server) releaseConn(sem chan struct{}) {
+	if sem != nil {
+		<-sem
 	}
 }
 
 // Accept 在监听器上接受连接并处理请求
 func Accept(lis net.Listener) { DefaultServer.Accept(lis) }
+
+// ServeTLS 在监听器上接受连接，完成 TLS 握手后再处理 Option 握手和请求。
+// cfg 要求客户端证书时（如 tls.RequireAndVerifyClientCert），处理请求时可以通过 IdentityFromContext 取得客户端身份
+func (server *Server) ServeTLS(lis net.Listener, cfg *tls.Config) {
+	server.Accept(tls.NewListener(lis, cfg))
+}
+
+// RegisterWithRegistry 向 registryAddr 上的注册中心注册 selfAddr（形如 "tcp@127.0.0.1:9999"），
+// 并每隔 interval 发送一次心跳，interval 为 0 时使用注册中心的默认间隔。
+// Shutdown 时停止心跳并立即注销 selfAddr，使客户端不再选中正在关闭的服务器，注销最多等到 Shutdown 的 ctx 结束。
+// 返回第一次心跳的错误，出错时心跳仍在后台按间隔重试，注册中心恢复后自动完成注册
+func (server *Server) RegisterWithRegistry(registryAddr, selfAddr string, interval time.Duration) error {
+	stop, err := registry.StartHeartbeat(registryAddr, selfAddr, interval)
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.shuttingDown() {
+		_ = stop(context.Background())
+		return err
+	}
+	server.deregisters = append(server.deregisters, stop)
+	return err
+}
+
+const (
+	connected        = "200 Connected to Go RPC"
+	defaultRPCPath   = "/_gorpc_"
+	defaultDebugPath = "/debug/gorpc"
+)
+
+// ServeHTTP 实现 http.Handler，响应 CONNECT 请求并把劫持的连接交给 ServeConn
+func (server *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "CONNECT" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = io.WriteString(w, "405 must CONNECT\n")
+		return
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		server.logger().Errorf("rpc hijacking %s: %v", req.RemoteAddr, err)
+		return
+	}
+	_, _ = io.WriteString(conn, "HTTP/1.0 "+connected+"\n\n")
+	server.ServeConn(conn)
+}
+
+// HandleHTTP 在 http.DefaultServeMux 上注册 rpcPath 的 RPC 处理器和 debugPath 的调试页面，
+// 仍需调用 http.Serve 启动 HTTP 服务
+func (server *Server) HandleHTTP(rpcPath, debugPath string) {
+	http.Handle(rpcPath, server)
+	http.Handle(debugPath, debugHTTP{server})
+	server.logger().Infof("rpc server debug path: %s", debugPath)
+}
+
+// HandleHTTP 使用默认路径为 DefaultServer 注册 HTTP 处理器
+func HandleHTTP() {
+	DefaultServer.HandleHTTP(defaultRPCPath, defaultDebugPath)
+}
@@ -0,0 +1,629 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type Foo int
+
+type Args struct{ Num1, Num2 int }
+
+func (f Foo) Sum(args Args, reply *int) error {
+	*reply = args.Num1 + args.Num2
+	return nil
+}
+
+// Sleep 等待 Num1 毫秒后返回 Num1 + Num2
+func (f Foo) Sleep(args Args, reply *int) error {
+	time.Sleep(time.Duration(args.Num1) * time.Millisecond)
+	*reply = args.Num1 + args.Num2
+	return nil
+}
+
+func (f Foo) Div(args Args, reply *int) error {
+	if args.Num2 == 0 {
+		return errors.New("divide by zero")
+	}
+	*reply = args.Num1 / args.Num2
+	return nil
+}
+
+// newTestServer 创建注册了 Foo 和 rcvrs 的服务端，测试结束时关闭
+func newTestServer(t testing.TB, rcvrs ...interface{}) *Server {
+	t.Helper()
+	server := NewServer()
+	for _, rcvr := range append([]interface{}{new(Foo)}, rcvrs...) {
+		if err := server.Register(rcvr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	})
+	return server
+}
+
+// listenTCP 在本机的随机端口上监听，测试结束时关闭
+func listenTCP(t testing.TB) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	return l
+}
+
+// deadAddr 返回一个没有服务端监听的地址
+func deadAddr(t testing.TB) string {
+	l := listenTCP(t)
+	_ = l.Close()
+	return l.Addr().String()
+}
+
+// startServer 启动注册了 Foo 和 rcvrs 的服务端，返回服务端和监听地址，测试结束时关闭
+func startServer(t testing.TB, rcvrs ...interface{}) (*Server, string) {
+	t.Helper()
+	server := newTestServer(t, rcvrs...)
+	l := listenTCP(t)
+	go server.Accept(l)
+	return server, l.Addr().String()
+}
+
+// dialServer 连接 addr，测试结束时关闭客户端
+func dialServer(t testing.TB, addr string, opts ...*Option) *Client {
+	t.Helper()
+	client, err := Dial("tcp", addr, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// dialRaw 连接 addr 并发送 opt，返回以 opt.CodecType 编解码的 codec.Codec，用于直接读写消息
+func dialRaw(t testing.TB, addr string, opt *Option) codec.Codec {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.NewEncoder(conn).Encode(opt); err != nil {
+		t.Fatal(err)
+	}
+	cc := codec.GetCodecFunc(opt.CodecType)(conn)
+	t.Cleanup(func() { _ = cc.Close() })
+	return cc
+}
+
+func TestServerBadBodyKeepsConnection(t *testing.T) {
+	_, addr := startServer(t)
+	cc := dialRaw(t, addr, DefaultOption)
+	// Foo.Sum 的参数是 Args，发送字符串使服务端解码消息体失败
+	if err := cc.Write(&codec.Header{ServiceMethod: "Foo.Sum", Seq: 1}, "bad body"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.Write(&codec.Header{ServiceMethod: "Foo.Sum", Seq: 2}, Args{Num1: 1, Num2: 2}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		var h codec.Header
+		if err := cc.ReadHeader(&h); err != nil {
+			t.Fatal(err)
+		}
+		switch h.Seq {
+		case 1:
+			if h.Error == "" {
+				t.Fatal("expect an error for the bad body")
+			}
+			_ = cc.Discard()
+		case 2:
+			var reply int
+			if err := cc.ReadBody(&reply); err != nil || h.Error != "" || reply != 3 {
+				t.Fatalf("expect the good request to return 3, got %d, %q, %v", reply, h.Error, err)
+			}
+		default:
+			t.Fatalf("unexpected seq %d", h.Seq)
+		}
+	}
+}
+
+func TestServerUnknownServiceMethod(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr)
+	tests := []struct {
+		serviceMethod, want string
+	}{
+		{"Bar.Sum", "rpc: can't find service Bar"},
+		{"Foo.Bar", "rpc: can't find method Bar"},
+		{"FooSum", "ill-formed"},
+		{"Foo.", "ill-formed"},
+		{".Sum", "ill-formed"},
+		{"ns.Foo.Sum", "rpc: can't find service ns.Foo"}, // 以最后一个点分隔服务名和方法名
+	}
+	for _, tt := range tests {
+		err := client.Call(context.Background(), tt.serviceMethod, Args{}, new(int))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("%s: expect error containing %q, got %v", tt.serviceMethod, tt.want, err)
+		}
+	}
+	// 连接没有被关闭
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 1}, &reply); err != nil || reply != 2 {
+		t.Fatalf("expect the connection to stay usable, got %d, %v", reply, err)
+	}
+}
+
+func TestServerHandleTimeout(t *testing.T) {
+	_, addr := startServer(t)
+	client := dialServer(t, addr, &Option{HandleTimeout: 50 * time.Millisecond})
+	start := time.Now()
+	err := client.Call(context.Background(), "Foo.Sleep", Args{Num1: 300}, new(int))
+	if err == nil || !strings.Contains(err.Error(), "rpc server: request handle timeout") {
+		t.Fatalf("expect a handle timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("expect the timeout after about 50ms, took %v", elapsed)
+	}
+	// 方法返回后不会再发送第二个响应，之后的调用正常
+	time.Sleep(300 * time.Millisecond)
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sleep", Args{Num1: 1, Num2: 1}, &reply); err != nil || reply != 2 {
+		t.Fatalf("expect 2, got %d, %v", reply, err)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	ts := httptest.NewServer(newTestServer(t))
+	defer ts.Close()
+
+	client, err := DialHTTP("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3 over HTTP CONNECT, got %d, %v", reply, err)
+	}
+
+	resp, err := http.Get(ts.URL + defaultRPCPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expect 405 for a GET, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerMaxConns(t *testing.T) {
+	server, addr := startServer(t)
+	server.SetMaxConns(1)
+	first := dialServer(t, addr)
+	var reply int
+	if err := first.Call(context.Background(), "Foo.Sum", Args{Num1: 1}, &reply); err != nil {
+		t.Fatal(err)
+	}
+
+	second := dialServer(t, addr)
+	call := second.Go("Foo.Sum", Args{Num1: 2}, &reply, nil)
+	select {
+	case <-call.Done:
+		t.Fatalf("expect the second connection to wait for a free slot, got %v", call.Error)
+	case <-time.After(100 * time.Millisecond):
+	}
+	_ = first.Close()
+	select {
+	case <-call.Done:
+		if call.Error != nil || reply != 2 {
+			t.Fatalf("expect 2, got %d, %v", reply, call.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect the second connection to proceed after the first one closed")
+	}
+}
+
+func TestServerRejectWhenFull(t *testing.T) {
+	server, addr := startServer(t)
+	server.SetMaxConns(1)
+	server.SetRejectWhenFull(true)
+	first := dialServer(t, addr)
+	if err := first.Call(context.Background(), "Foo.Sum", Args{}, new(int)); err != nil {
+		t.Fatal(err)
+	}
+	second := dialServer(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := second.Call(ctx, "Foo.Sum", Args{}, new(int)); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect the second connection to be closed immediately, got %v", err)
+	}
+}
+
+func TestServerMaxBodySize(t *testing.T) {
+	server, addr := startServer(t)
+	if err := server.RegisterFunc("Blob.Len", func(data []byte, reply *int) error {
+		*reply = len(data)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	client := dialServer(t, addr, &Option{MaxBodySize: 1 << 10})
+	var reply int
+	err := client.Call(context.Background(), "Blob.Len", make([]byte, 4<<10), &reply)
+	if err == nil || !strings.Contains(err.Error(), "body too large") {
+		t.Fatalf("expect the oversized body to be rejected, got %v", err)
+	}
+	if err := client.Call(context.Background(), "Blob.Len", make([]byte, 100), &reply); err != nil || reply != 100 {
+		t.Fatalf("expect the next request to be served, got %d, %v", reply, err)
+	}
+}
+
+func TestServerFramedAndUnframed(t *testing.T) {
+	_, addr := startServer(t)
+	for _, typ := range []codec.Type{codec.GobType, codec.JsonType} {
+		for _, framed := range []bool{false, true} {
+			client := dialServer(t, addr, &Option{CodecType: typ, Framed: framed})
+			var reply int
+			if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+				t.Fatalf("%s framed=%v: expect 3, got %d, %v", typ, framed, reply, err)
+			}
+		}
+	}
+}
+
+// waitFor 等待 cond 成立，超过 1s 时测试失败
+func waitFor(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServerIdleTimeout(t *testing.T) {
+	server, addr := startServer(t)
+	server.SetIdleTimeout(100 * time.Millisecond)
+	client := dialServer(t, addr)
+	if err := client.Call(context.Background(), "Foo.Sum", Args{}, new(int)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the idle connection to be closed", func() bool { return !client.IsAvailable() })
+
+	// 不是 net.Conn 的连接不受空闲超时影响
+	c1, c2 := net.Pipe()
+	go server.ServeConn(struct{ io.ReadWriteCloser }{c2})
+	pipeClient, err := NewClient(c1, &Option{MagicNumber: MagicNumber, CodecType: codec.GobType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pipeClient.Close() }()
+	time.Sleep(200 * time.Millisecond)
+	var reply int
+	if err := pipeClient.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 1}, &reply); err != nil || reply != 2 {
+		t.Fatalf("expect a non-net transport to ignore the idle timeout, got %d, %v", reply, err)
+	}
+}
+
+// Hang 的方法一直阻塞，用于测试连接关闭时卡住的请求
+type Hang struct {
+	release   chan struct{} // 关闭后 Forever 返回
+	cancelled chan struct{} // Wait 的 ctx 被取消时关闭
+}
+
+// Forever 忽略 ctx，直到 release 被关闭
+func (h *Hang) Forever(args Args, reply *int) error {
+	<-h.release
+	return nil
+}
+
+// Wait 等待 ctx 被取消
+func (h *Hang) Wait(ctx context.Context, args Args, reply *int) error {
+	<-ctx.Done()
+	close(h.cancelled)
+	return ctx.Err()
+}
+
+func TestServeConnHungRequests(t *testing.T) {
+	hang := &Hang{release: make(chan struct{}), cancelled: make(chan struct{})}
+	defer close(hang.release)
+	server := NewServer()
+	if err := server.Register(hang); err != nil {
+		t.Fatal(err)
+	}
+	logger := &captureLogger{}
+	server.SetLogger(logger)
+	server.SetDrainTimeout(50 * time.Millisecond)
+
+	// serve 在 net.Pipe 上处理连接，返回客户端和 ServeConn 返回时关闭的 channel
+	serve := func() (*Client, chan struct{}) {
+		serverConn, clientConn := net.Pipe()
+		served := make(chan struct{})
+		go func() {
+			server.ServeConn(serverConn)
+			close(served)
+		}()
+		client, err := NewClient(clientConn, DefaultOption)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client, served
+	}
+	wait := func(ch chan struct{}, what string) {
+		t.Helper()
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatalf("expect %s", what)
+		}
+	}
+
+	// 客户端断开时取消连接级别的 context，卡住的方法不会阻止 ServeConn 返回
+	client, served := serve()
+	client.Go("Hang.Forever", Args{}, new(int), nil)
+	client.Go("Hang.Wait", Args{}, new(int), nil)
+	time.Sleep(20 * time.Millisecond) // 等待两个请求开始处理
+	_ = client.Close()
+	wait(served, "ServeConn to return after the client disconnects")
+	wait(hang.cancelled, "the context of the request to be cancelled")
+
+	// 空闲超时关闭连接时等待请求完成，超过 drainTimeout 后记录并放弃卡住的请求
+	server.SetIdleTimeout(30 * time.Millisecond)
+	client, served = serve()
+	defer func() { _ = client.Close() }()
+	client.Go("Hang.Forever", Args{}, new(int), nil)
+	wait(served, "ServeConn to return after the drain timeout")
+	if !logger.contains("abandon in-flight request Hang.Forever") {
+		t.Fatalf("expect the hung request to be logged, got %v", logger.errors)
+	}
+}
+
+// Upper 的参数和返回值都是 protobuf 消息
+type Upper struct{}
+
+func (Upper) Do(args *wrapperspb.StringValue, reply *wrapperspb.StringValue) error {
+	reply.Value = strings.ToUpper(args.GetValue())
+	return nil
+}
+
+func TestServerProtobufCodec(t *testing.T) {
+	_, addr := startServer(t, Upper{})
+	client := dialServer(t, addr, &Option{MagicNumber: MagicNumber, CodecType: codec.ProtobufType})
+	var reply wrapperspb.StringValue
+	if err := client.Call(context.Background(), "Upper.Do", wrapperspb.String("abc"), &reply); err != nil || reply.GetValue() != "ABC" {
+		t.Fatalf("expect ABC, got %q, %v", reply.GetValue(), err)
+	}
+	// Foo.Sum 的参数不是 protobuf 消息，服务端回复错误而不是 panic
+	err := client.Call(context.Background(), "Foo.Sum", wrapperspb.String("abc"), new(wrapperspb.Int64Value))
+	if err == nil || !strings.Contains(err.Error(), "does not implement proto.Message") {
+		t.Fatalf("expect a clear error for a non-proto argument, got %v", err)
+	}
+	if err := client.Call(context.Background(), "Upper.Do", wrapperspb.String("d"), &reply); err != nil || reply.GetValue() != "D" {
+		t.Fatalf("expect the connection to keep working, got %q, %v", reply.GetValue(), err)
+	}
+}
+
+func TestServerMaxInflightPerConn(t *testing.T) {
+	g := new(Gauge)
+	server, addr := startServer(t, g)
+	server.SetMaxInflightPerConn(3)
+	client := dialServer(t, addr)
+
+	const n = 20
+	done := make(chan *Call, n)
+	for i := 0; i < n; i++ {
+		client.Go("Gauge.Hold", Args{Num1: 20}, new(int), done)
+	}
+	for i := 0; i < n; i++ {
+		if call := <-done; call.Error != nil {
+			t.Fatal(call.Error)
+		}
+	}
+	if peak := g.peak.Load(); peak != 3 {
+		t.Fatalf("expect at most 3 concurrent handlers on the connection, got %d", peak)
+	}
+}
+
+func TestServerGlobalMaxInflight(t *testing.T) {
+	g := new(Gauge)
+	server, addr := startServer(t, g)
+	server.SetMaxInflight(4)
+
+	const conns, perConn = 3, 4
+	done := make(chan *Call, conns*perConn)
+	for i := 0; i < conns; i++ {
+		client := dialServer(t, addr)
+		for j := 0; j < perConn; j++ {
+			client.Go("Gauge.Hold", Args{Num1: 200}, new(int), done)
+		}
+	}
+	var ok, overloaded int
+	for i := 0; i < conns*perConn; i++ {
+		call := <-done
+		switch {
+		case call.Error == nil:
+			ok++
+		case strings.Contains(call.Error.Error(), "rpc server: overloaded"):
+			overloaded++
+		default:
+			t.Fatalf("unexpected error %v", call.Error)
+		}
+	}
+	if ok != 4 || overloaded != conns*perConn-4 || g.peak.Load() != 4 {
+		t.Fatalf("expect 4 calls to complete and the rest to be rejected, got %d ok, %d overloaded, peak %d", ok, overloaded, g.peak.Load())
+	}
+}
+
+func TestServerListenAndServe(t *testing.T) {
+	server := newTestServer(t)
+	addr, err := server.ListenAndServe("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port := addr.(*net.TCPAddr).Port; port == 0 {
+		t.Fatalf("expect the bound port to be returned, got %v", addr)
+	}
+	client := dialServer(t, addr.String())
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3 from the returned address, got %d, %v", reply, err)
+	}
+	if _, err := server.ListenAndServe("tcp", addr.String()); err == nil {
+		t.Fatal("expect listening on a bound address to fail")
+	}
+}
+
+func TestServerAlias(t *testing.T) {
+	server, addr := startServer(t, new(Arith))
+	if err := server.Alias("Arith.Add", "Arith.Sum"); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Alias("Legacy.Plus", "Arith.Sum"); err != nil {
+		t.Fatalf("expect an alias under a new service, got %v", err)
+	}
+	if err := server.Alias("Arith.Minus", "Arith.Sub"); err == nil {
+		t.Fatal("expect an alias to a missing method to be rejected")
+	}
+	if err := server.Alias("Arith.Add", "Arith.Sum"); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Fatalf("expect a duplicate alias to be rejected, got %v", err)
+	}
+	if err := server.Alias("Foo.Add", "Arith.Sum"); err == nil {
+		t.Fatal("expect an alias into a service with another receiver to be rejected")
+	}
+
+	client := dialServer(t, addr)
+	for _, name := range []string{"Arith.Sum", "Arith.Add", "Legacy.Plus"} {
+		var reply int
+		if err := client.Call(context.Background(), name, Args{Num1: 2, Num2: 3}, &reply); err != nil || reply != 5 {
+			t.Fatalf("expect %s to return 5, got %d, %v", name, reply, err)
+		}
+	}
+	svc, _ := server.serviceMap.Load("Arith")
+	if n := svc.(*service).method["Sum"].NumCalls(); n != 3 {
+		t.Fatalf("expect every name to share the call count, got %d", n)
+	}
+}
+
+func TestServerRequireCodec(t *testing.T) {
+	server, addr := startServer(t, Upper{})
+	server.RequireCodec("Upper", codec.ProtobufType)
+
+	client := dialServer(t, addr)
+	err := client.Call(context.Background(), "Upper.Do", wrapperspb.String("abc"), new(wrapperspb.StringValue))
+	if err == nil || !strings.Contains(err.Error(), "service Upper requires codec [application/protobuf], but the connection uses application/gob") {
+		t.Fatalf("expect an informative codec rejection, got %v", err)
+	}
+	// 被拒绝的请求体已被跳过，连接上的其他服务不受影响
+	var sum int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &sum); err != nil || sum != 3 {
+		t.Fatalf("expect 3 over gob after the rejection, got %d, %v", sum, err)
+	}
+
+	pb := dialServer(t, addr, &Option{CodecType: codec.ProtobufType})
+	var reply wrapperspb.StringValue
+	if err := pb.Call(context.Background(), "Upper.Do", wrapperspb.String("abc"), &reply); err != nil || reply.GetValue() != "ABC" {
+		t.Fatalf("expect ABC over protobuf, got %q, %v", reply.GetValue(), err)
+	}
+	server.RequireCodec("Upper")
+	if err := client.Call(context.Background(), "Upper.Do", wrapperspb.String("x"), &reply); err != nil && strings.Contains(err.Error(), "requires codec") {
+		t.Fatalf("expect the requirement to be lifted, got %v", err)
+	}
+}
+
+// Spin 模拟一直占用 CPU 的方法，循环检查 ctx 直到其被取消
+type Spin struct {
+	started chan struct{} // 方法开始时发送
+	stopped chan error    // 方法返回时发送 ctx 的错误
+}
+
+func (s *Spin) Loop(ctx context.Context, args Args, reply *int) error {
+	s.started <- struct{}{}
+	for ctx.Err() == nil {
+		*reply++
+		if *reply%1000 == 0 {
+			time.Sleep(time.Microsecond)
+		}
+	}
+	s.stopped <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestServerCancelOnDisconnect(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxInflight int // 每个连接的请求上限，0 表示不限制
+		calls       int
+		running     int // 同时运行的方法数
+	}{
+		{"unlimited", 0, 1, 1},
+		{"all slots taken", 2, 3, 2}, // 第三个请求在等待名额，连接暂停读取
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spin := &Spin{started: make(chan struct{}, tt.calls), stopped: make(chan error, tt.calls)}
+			server, addr := startServer(t, spin)
+			server.SetMaxInflightPerConn(tt.maxInflight)
+			client := dialServer(t, addr)
+			for i := 0; i < tt.calls; i++ {
+				client.Go("Spin.Loop", Args{}, new(int), nil)
+			}
+			for i := 0; i < tt.running; i++ {
+				select {
+				case <-spin.started:
+				case <-time.After(time.Second):
+					t.Fatal("expect the method to start")
+				}
+			}
+
+			_ = client.Close() // 客户端在调用进行中断开
+			start := time.Now()
+			for i := 0; i < tt.running; i++ {
+				select {
+				case err := <-spin.stopped:
+					if !errors.Is(err, context.Canceled) {
+						t.Fatalf("expect the method to see the cancellation, got %v", err)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("expect the method to stop after the client disconnects")
+				}
+			}
+			if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+				t.Fatalf("expect the methods to stop promptly, took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestServerPingWhileSlotsFull(t *testing.T) {
+	server, addr := startServer(t)
+	server.SetMaxInflightPerConn(1)
+	client := dialServer(t, addr)
+	call := client.Go("Foo.Sleep", Args{Num1: 200}, new(int), nil)
+	time.Sleep(20 * time.Millisecond)
+	// 唯一的名额被占用时心跳仍然得到回复
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		t.Fatalf("expect the ping not to wait for a slot, got %v", err)
+	}
+	if err := (<-call.Done).Error; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,209 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/token"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+// methodType 保存一个可被远程调用的方法的完整信息
+type methodType struct {
+	method    reflect.Method // 方法本身，RegisterFunc 注册的函数没有接收者
+	ArgType   reflect.Type   // 第一个参数的类型
+	ReplyType reflect.Type   // 第二个参数的类型，必须为指针；流式方法为 ServerStream
+	stream    bool           // 是否为流式方法
+	withCtx   bool           // 第一个参数是否为 context.Context
+	numCalls  atomic.Uint64  // 方法被调用的次数
+}
+
+// NumCalls 返回方法被调用的次数
+func (m *methodType) NumCalls() uint64 {
+	return m.numCalls.Load()
+}
+
+// newArgv 创建参数实例，参数可以是指针类型也可以是值类型
+func (m *methodType) newArgv() reflect.Value {
+	if m.ArgType.Kind() == reflect.Ptr {
+		return reflect.New(m.ArgType.Elem())
+	}
+	return reflect.New(m.ArgType).Elem()
+}
+
+// newReplyv 创建返回值实例，返回值必须是指针类型
+func (m *methodType) newReplyv() reflect.Value {
+	replyv := reflect.New(m.ReplyType.Elem())
+	// map 和 slice 需要初始化后才能使用
+	switch m.ReplyType.Elem().Kind() {
+	case reflect.Map:
+		replyv.Elem().Set(reflect.MakeMap(m.ReplyType.Elem()))
+	case reflect.Slice:
+		replyv.Elem().Set(reflect.MakeSlice(m.ReplyType.Elem(), 0, 0))
+	}
+	return replyv
+}
+
+// service 表示一个注册到服务器上的服务
+type service struct {
+	name   string                 // 服务名，默认为结构体类型名
+	typ    reflect.Type           // 结构体类型
+	rcvr   reflect.Value          // 结构体实例本身，调用方法时作为第 0 个参数；只包含函数的服务为零值
+	method map[string]*methodType // 所有符合条件的方法
+}
+
+// newService 通过反射构造服务，name 为空时使用类型名。
+// name 可以包含点作为命名空间，如 "cache.ShardA"，调用时以最后一个点分隔服务名和方法名
+func newService(name string, rcvr interface{}) (*service, error) {
+	s := &service{
+		typ:  reflect.TypeOf(rcvr),
+		rcvr: reflect.ValueOf(rcvr),
+	}
+	if s.typ == nil {
+		return nil, errors.New("rpc: can't register nil receiver")
+	}
+	s.name = name
+	if s.name == "" {
+		s.name = reflect.Indirect(s.rcvr).Type().Name()
+	}
+	if s.name == "" {
+		return nil, errors.New("rpc: no service name for type " + s.typ.String())
+	}
+	if err := checkServiceName(s.name); err != nil {
+		return nil, err
+	}
+	s.registerMethods()
+	if len(s.method) == 0 {
+		msg := "rpc: type " + s.typ.String() + " has no exported methods of suitable type"
+		// 方法定义在指针接收者上时，值类型的方法集合中没有这些方法
+		if s.typ.Kind() != reflect.Ptr && reflect.PointerTo(s.typ).NumMethod() > 0 {
+			msg += " (hint: pass a pointer to value of that type)"
+		}
+		return nil, errors.New(msg)
+	}
+	return s, nil
+}
+
+func checkServiceName(name string) error {
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") || strings.Contains(name, "..") ||
+		strings.ContainsAny(name, " \t\r\n") {
+		return errors.New("rpc: invalid service name " + strconv.Quote(name))
+	}
+	return nil
+}
+
+// registerMethods 过滤出形如 func(argType T1, replyType *T2) error 的导出方法，
+// 以及形如 func(argType T1, stream ServerStream) error 的流式方法；
+// 两者都可以在最前面增加一个 context.Context 参数。
+// 参数和返回值的类型必须是导出类型或内置类型，不符合条件的方法被跳过
+func (s *service) registerMethods() {
+	s.method = make(map[string]*methodType)
+	for i := 0; i < s.typ.NumMethod(); i++ {
+		method := s.typ.Method(i)
+		m, err := newMethodType(method, 1)
+		if err != nil {
+			getLogger().Debugf("rpc server: skip method %s.%s: %v", s.name, method.Name, err)
+			continue
+		}
+		s.method[method.Name] = m
+	}
+}
+
+// newMethodType 检查参数和返回值类型，不是可远程调用的方法时返回原因。
+// skip 是参数列表开头需要跳过的参数个数，方法为 1（接收者），函数为 0
+func newMethodType(method reflect.Method, skip int) (*methodType, error) {
+	mType := method.Type
+	if mType.NumOut() != 1 || mType.Out(0) != typeOfError {
+		return nil, errors.New("method must return exactly one value of type error")
+	}
+	withCtx := mType.NumIn() == skip+3 && mType.In(skip) == typeOfContext
+	if withCtx {
+		skip++
+	}
+	if mType.NumIn() != skip+2 {
+		return nil, fmt.Errorf("method has %d arguments, want 2 (or 3 with context.Context first)", mType.NumIn()-skip)
+	}
+	argType, replyType := mType.In(skip), mType.In(skip+1)
+	stream := replyType == typeOfServerStream
+	if replyType.Kind() != reflect.Ptr && !stream {
+		return nil, errors.New("reply type " + replyType.String() + " is not a pointer")
+	}
+	if !isExportedOrBuiltinType(argType) {
+		return nil, errors.New("argument type " + argType.String() + " is not exported")
+	}
+	if !isExportedOrBuiltinType(replyType) {
+		return nil, errors.New("reply type " + replyType.String() + " is not exported")
+	}
+	return &methodType{
+		method:    method,
+		ArgType:   argType,
+		ReplyType: replyType,
+		stream:    stream,
+		withCtx:   withCtx,
+	}, nil
+}
+
+// isExportedOrBuiltinType 返回 t（去掉指针后）是否为导出类型或没有包路径的内置类型
+func isExportedOrBuiltinType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return token.IsExported(t.Name()) || t.PkgPath() == ""
+}
+
+// newFuncService 创建只包含函数 fn 的服务，fn 必须形如 func(argType T1, replyType *T2) error
+// 或 func(argType T1, stream ServerStream) error，可以在最前面增加一个 context.Context 参数
+func newFuncService(serviceName, methodName string, fn interface{}) (*service, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return nil, fmt.Errorf("rpc: RegisterFunc %s.%s: %T is not a function", serviceName, methodName, fn)
+	}
+	if err := checkServiceName(serviceName); err != nil {
+		return nil, err
+	}
+	fType := fv.Type()
+	m, err := newMethodType(reflect.Method{Name: methodName, Type: fType, Func: fv}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("rpc: RegisterFunc %s.%s: function has signature %s (%v), "+
+			"want func([ctx context.Context,] arg T1, reply *T2) error or func([ctx context.Context,] arg T1, stream ServerStream) error",
+			serviceName, methodName, fType, err)
+	}
+	return &service{
+		name:   serviceName,
+		typ:    fType,
+		method: map[string]*methodType{methodName: m},
+	}, nil
+}
+
+// call 通过反射调用方法，方法 panic 时转换为错误返回，避免整个服务端崩溃。
+// 方法接受 context.Context 时传入 ctx
+func (s *service) call(ctx context.Context, m *methodType, argv, replyv reflect.Value) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			getLogger().Errorf("rpc server: %s.%s panicked: %v\n%s", s.name, m.method.Name, r, debug.Stack())
+			err = fmt.Errorf("rpc server: method panicked: %v", r)
+		}
+	}()
+	m.numCalls.Add(1)
+	in := []reflect.Value{argv, replyv}
+	if m.withCtx {
+		in = append([]reflect.Value{reflect.ValueOf(&ctx).Elem()}, in...)
+	}
+	if s.rcvr.IsValid() {
+		in = append([]reflect.Value{s.rcvr}, in...)
+	}
+	returnValues := m.method.Func.Call(in)
+	if errInter := returnValues[0].Interface(); errInter != nil {
+		return errInter.(error)
+	}
+	return nil
+}
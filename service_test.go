@@ -0,0 +1,315 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// unexportedArgs 不是导出类型，使用它的方法不会被注册
+type unexportedArgs struct{ Num int }
+
+func (f Foo) Hidden(args unexportedArgs, reply *int) error { return nil }
+
+func (f Foo) NotPointer(args Args, reply int) error { return nil }
+
+func TestNewService(t *testing.T) {
+	var foo Foo
+	s, err := newService("", &foo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.name != "Foo" {
+		t.Fatalf("expect service name Foo, got %s", s.name)
+	}
+	for _, name := range []string{"Sum", "Sleep", "Div"} {
+		if s.method[name] == nil {
+			t.Fatalf("expect method %s to be registered", name)
+		}
+	}
+	for _, name := range []string{"Hidden", "NotPointer"} {
+		if s.method[name] != nil {
+			t.Fatalf("expect method %s to be skipped", name)
+		}
+	}
+
+	mType := s.method["Sum"]
+	argv, replyv := mType.newArgv(), mType.newReplyv()
+	argv.Set(reflect.ValueOf(Args{Num1: 1, Num2: 3}))
+	if err := s.call(context.Background(), mType, argv, replyv); err != nil || *replyv.Interface().(*int) != 4 {
+		t.Fatalf("expect Sum to return 4, got %v, %v", *replyv.Interface().(*int), err)
+	}
+	if mType.NumCalls() != 1 {
+		t.Fatalf("expect 1 call, got %d", mType.NumCalls())
+	}
+}
+
+func TestServerRegister(t *testing.T) {
+	server := NewServer()
+	if err := server.Register(new(Foo)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Register(new(Foo)); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Fatalf("expect registering Foo twice to fail, got %v", err)
+	}
+	if err := server.RegisterName("Bar", new(Foo)); err != nil {
+		t.Fatalf("expect another instance under a new name, got %v", err)
+	}
+	if err := server.Register(new(unexportedArgs)); err == nil {
+		t.Fatal("expect a type without suitable methods to be rejected")
+	}
+}
+
+func TestServerCallRegisteredMethods(t *testing.T) {
+	server, addr := startServer(t)
+	if err := server.RegisterName("Bar", new(Foo)); err != nil {
+		t.Fatal(err)
+	}
+	client := dialServer(t, addr)
+	var reply int
+	if err := client.Call(context.Background(), "Bar.Sum", Args{Num1: 2, Num2: 3}, &reply); err != nil || reply != 5 {
+		t.Fatalf("expect Bar.Sum to return 5, got %d, %v", reply, err)
+	}
+	if err := client.Call(context.Background(), "Foo.Div", Args{Num1: 6, Num2: 3}, &reply); err != nil || reply != 2 {
+		t.Fatalf("expect Foo.Div to return 2, got %d, %v", reply, err)
+	}
+	if err := client.Call(context.Background(), "Foo.Div", Args{Num1: 1}, &reply); err == nil || !strings.Contains(err.Error(), "divide by zero") {
+		t.Fatalf("expect the method error to be returned, got %v", err)
+	}
+}
+
+type Panicker struct{}
+
+func (p Panicker) Boom(args Args, reply *int) error {
+	panic("boom")
+}
+
+func TestServerMethodPanic(t *testing.T) {
+	_, addr := startServer(t, Panicker{})
+	client := dialServer(t, addr)
+	err := client.Call(context.Background(), "Panicker.Boom", Args{}, new(int))
+	if err == nil || !strings.Contains(err.Error(), "rpc server: method panicked: boom") {
+		t.Fatalf("expect the panic to be returned as an error, got %v", err)
+	}
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect the connection to survive the panic, got %d, %v", reply, err)
+	}
+}
+
+// Cache 是带状态的服务，用于测试同一类型的多个实例
+type Cache struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+type KV struct{ Key, Value string }
+
+func (c *Cache) Set(kv KV, reply *bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[kv.Key] = kv.Value
+	*reply = true
+	return nil
+}
+
+func (c *Cache) Get(key string, reply *string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*reply = c.data[key]
+	return nil
+}
+
+func TestRegisterSameTypeUnderNames(t *testing.T) {
+	server, addr := startServer(t)
+	for _, name := range []string{"CacheA", "CacheB"} {
+		if err := server.RegisterName(name, &Cache{data: make(map[string]string)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := server.RegisterName("CacheA", &Cache{}); err == nil || !strings.Contains(err.Error(), "already defined: CacheA") {
+		t.Fatalf("expect a name collision to be reported, got %v", err)
+	}
+	if err := server.Register(&Cache{}); err != nil {
+		t.Fatalf("expect the type name to be free, got %v", err)
+	}
+	err := server.Register(&Cache{})
+	if err == nil || !strings.Contains(err.Error(), "use RegisterName") {
+		t.Fatalf("expect the error to suggest RegisterName, got %v", err)
+	}
+
+	client := dialServer(t, addr)
+	var ok bool
+	for _, kv := range []struct{ service, value string }{{"CacheA", "a"}, {"CacheB", "b"}} {
+		if err := client.Call(context.Background(), kv.service+".Set", KV{Key: "k", Value: kv.value}, &ok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for service, want := range map[string]string{"CacheA": "a", "CacheB": "b", "Cache": ""} {
+		var got string
+		if err := client.Call(context.Background(), service+".Get", "k", &got); err != nil || got != want {
+			t.Fatalf("expect %s to hold %q, got %q, %v", service, want, got, err)
+		}
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	server, addr := startServer(t)
+	if err := server.RegisterFunc("Math.Add", func(args Args, reply *int) error {
+		*reply = args.Num1 + args.Num2
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterFunc("Math.Neg", func(ctx context.Context, n int, reply *int) error {
+		*reply = -n
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, fn := range map[string]interface{}{
+		"Math.Bad":    func(args Args) error { return nil },
+		"Math.NoPtr":  func(args Args, reply int) error { return nil },
+		"Math.NoErr":  func(args Args, reply *int) {},
+		"Math.NotFn":  42,
+		"NoMethod":    func(args Args, reply *int) error { return nil },
+		"Math.Add":    func(args Args, reply *int) error { return nil },
+		"Foo.Another": func(args Args, reply *int) error { return nil },
+	} {
+		if err := server.RegisterFunc(name, fn); err == nil {
+			t.Fatalf("expect RegisterFunc(%s) to be rejected", name)
+		}
+	}
+
+	client := dialServer(t, addr)
+	var reply int
+	if err := client.Call(context.Background(), "Math.Add", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect Math.Add to return 3, got %d, %v", reply, err)
+	}
+	if err := client.Call(context.Background(), "Math.Neg", 5, &reply); err != nil || reply != -5 {
+		t.Fatalf("expect Math.Neg to return -5, got %d, %v", reply, err)
+	}
+}
+
+// Mixed 同时有接收 context 和不接收 context 的方法
+type Mixed struct{}
+
+func (Mixed) Plain(args Args, reply *int) error {
+	*reply = args.Num1
+	return nil
+}
+
+func (Mixed) WithContext(ctx context.Context, args Args, reply *string) error {
+	traceID, _ := TraceFromContext(ctx)
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("expect a deadline from the client")
+	}
+	*reply = traceID
+	return nil
+}
+
+func TestContextMethods(t *testing.T) {
+	s, err := newService("", Mixed{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.method) != 2 {
+		t.Fatalf("expect both methods to be registered, got %d", len(s.method))
+	}
+
+	_, addr := startServer(t, Mixed{})
+	client := dialServer(t, addr)
+	var n int
+	if err := client.Call(context.Background(), "Mixed.Plain", Args{Num1: 7}, &n); err != nil || n != 7 {
+		t.Fatalf("expect Mixed.Plain to return 7, got %d, %v", n, err)
+	}
+	ctx, cancel := context.WithTimeout(ContextWithTrace(context.Background(), "trace-1", ""), time.Second)
+	defer cancel()
+	var traceID string
+	if err := client.Call(ctx, "Mixed.WithContext", Args{}, &traceID); err != nil || traceID != "trace-1" {
+		t.Fatalf("expect the request context to reach the method, got %q, %v", traceID, err)
+	}
+}
+
+// BenchmarkServiceCallCached 使用注册时缓存的 methodType 调用方法
+func BenchmarkServiceCallCached(b *testing.B) {
+	var foo Foo
+	s, err := newService("", &foo)
+	if err != nil {
+		b.Fatal(err)
+	}
+	args := reflect.ValueOf(Args{Num1: 1, Num2: 2})
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		mType := s.method["Sum"]
+		argv, replyv := mType.newArgv(), mType.newReplyv()
+		argv.Set(args)
+		if err := s.call(ctx, mType, argv, replyv); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkServiceCallUncached 每次调用都通过反射查找方法和参数类型，作为缓存的对照
+func BenchmarkServiceCallUncached(b *testing.B) {
+	var foo Foo
+	rcvr := reflect.ValueOf(&foo)
+	args := reflect.ValueOf(Args{Num1: 1, Num2: 2})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		method, ok := rcvr.Type().MethodByName("Sum")
+		if !ok {
+			b.Fatal("method Sum not found")
+		}
+		argType, replyType := method.Type.In(1), method.Type.In(2)
+		argv, replyv := reflect.New(argType).Elem(), reflect.New(replyType.Elem())
+		argv.Set(args)
+		if err := method.Func.Call([]reflect.Value{rcvr, argv, replyv})[0].Interface(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Partial 只有 Valid 和 ValidPtr 符合注册条件
+type Partial struct{}
+
+func (Partial) Valid(args Args, reply *int) error          { return nil }
+func (Partial) ValidPtr(args *Args, reply *[]int) error    { return nil }
+func (Partial) TooFew(args Args) error                     { return nil }
+func (Partial) TooMany(args Args, reply *int, n int) error { return nil }
+func (Partial) NoError(args Args, reply *int)              {}
+func (Partial) WrongReturn(args Args, reply *int) int      { return 0 }
+func (Partial) UnexportedReply(args Args, reply *unexportedArgs) error {
+	return nil
+}
+func (Partial) private(args Args, reply *int) error { return nil }
+
+func TestNewServiceSkipsInvalidMethods(t *testing.T) {
+	s, err := newService("", Partial{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range s.method {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"Valid", "ValidPtr"}) {
+		t.Fatalf("expect only Valid and ValidPtr to be registered, got %v", names)
+	}
+
+	// 值接收者和指针接收者的方法都会被注册
+	if s, err := newService("", &Partial{}); err != nil || len(s.method) != 2 {
+		t.Fatalf("expect a pointer receiver to expose the same methods, got %v", err)
+	}
+	if _, err := newService("", new(unexportedArgs)); err == nil || !strings.Contains(err.Error(), "unexportedArgs") {
+		t.Fatalf("expect a receiver without suitable methods to be rejected, got %v", err)
+	}
+}
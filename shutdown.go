@@ -0,0 +1,169 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"context"
+	"io"
+	"net"
+	"runtime/debug"
+	"time"
+)
+
+// shutdownPollInterval 是 Shutdown 检查连接是否全部结束的间隔
+const shutdownPollInterval = 10 * time.Millisecond
+
+// shuttingDown 返回服务器是否已开始关闭
+func (server *Server) shuttingDown() bool {
+	return server.inShutdown.Load()
+}
+
+// trackListener 记录或移除监听器，关闭期间不再接受新的监听器
+func (server *Server) trackListener(lis net.Listener, add bool) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.listeners == nil {
+		server.listeners = make(map[net.Listener]struct{})
+	}
+	if add {
+		if server.shuttingDown() {
+			return false
+		}
+		server.listeners[lis] = struct{}{}
+	} else {
+		delete(server.listeners, lis)
+	}
+	return true
+}
+
+// trackConn 记录或移除活跃连接，关闭期间不再接受新的连接
+func (server *Server) trackConn(conn io.Closer, add bool) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.activeConn == nil {
+		server.activeConn = make(map[io.Closer]*inflightRequests)
+	}
+	if add {
+		if server.shuttingDown() {
+			return false
+		}
+		server.activeConn[conn] = nil
+	} else {
+		delete(server.activeConn, conn)
+	}
+	return true
+}
+
+// watchInflight 记录 ServeCodecDirect 的编解码器上正在处理的请求，供 Shutdown 判断何时关闭它
+func (server *Server) watchInflight(cc codec.Codec, inflight *inflightRequests) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if _, ok := server.activeConn[cc]; ok {
+		server.activeConn[cc] = inflight
+	}
+}
+
+// stopReading 让每个连接停止读取新请求，调用时需持有 server.mu。
+// net.Conn 通过读取超时让阻塞在读取上的 serveCodec 立即返回，已在处理的请求不受影响；
+// ServeCodecDirect 的编解码器无法设置超时，在没有正在处理的请求时直接关闭，closed 记录已关闭的编解码器
+func (server *Server) stopReading(closed map[io.Closer]bool) {
+	for conn, inflight := range server.activeConn {
+		if nc, ok := conn.(net.Conn); ok {
+			_ = nc.SetReadDeadline(time.Now())
+		} else if !closed[conn] && (inflight == nil || inflight.idle()) {
+			closed[conn] = true
+			_ = conn.Close()
+		}
+	}
+}
+
+// Shutdown 优雅地关闭服务器：先将健康状态置为 NOT_SERVING 并从 RegisterWithRegistry 注册的注册中心注销，
+// 等待 SetHealthGracePeriod 设置的时间后关闭所有监听器停止接受新连接，
+// 再让每个连接停止读取新请求、处理完已读取的请求后关闭。
+// 所有连接结束后返回 nil；ctx 先结束时强制关闭剩余连接并返回 ctx.Err()。
+// 两种情况下都会在返回前执行 RegisterOnShutdown 注册的回调
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.notServing.Store(true)
+
+	// 先从注册中心注销，使客户端在监听器关闭前就不再选中这个服务器
+	server.mu.Lock()
+	deregisters := server.deregisters
+	server.deregisters = nil
+	server.mu.Unlock()
+	for _, deregister := range deregisters {
+		_ = deregister(ctx) // 注册中心没有响应时最多等到 ctx 结束
+	}
+	// 期间继续正常服务，负载均衡器通过健康检查发现 NOT_SERVING 后不再发送新请求
+	if grace := server.getHealthGracePeriod(); grace > 0 {
+		timer := time.NewTimer(grace)
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+	server.inShutdown.Store(true)
+
+	server.mu.Lock()
+	for lis := range server.listeners {
+		_ = lis.Close()
+		delete(server.listeners, lis)
+	}
+	closed := make(map[io.Closer]bool)
+	server.stopReading(closed)
+	server.mu.Unlock()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if server.numActiveConn() == 0 {
+			server.runOnShutdown()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			server.mu.Lock()
+			for conn := range server.activeConn {
+				_ = conn.Close()
+			}
+			server.mu.Unlock()
+			server.runOnShutdown()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		server.mu.Lock()
+		server.stopReading(closed) // 编解码器上的请求可能刚刚处理完
+		server.mu.Unlock()
+	}
+}
+
+// RegisterOnShutdown 注册在 Shutdown 结束连接后执行的回调，用于释放缓存、数据库连接等资源。
+// 回调按注册的相反顺序执行，每个回调只执行一次，某个回调 panic 不影响其他回调
+func (server *Server) RegisterOnShutdown(f func()) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.onShutdown = append(server.onShutdown, f)
+}
+
+// runOnShutdown 按注册的相反顺序执行并清空 onShutdown
+func (server *Server) runOnShutdown() {
+	server.mu.Lock()
+	callbacks := server.onShutdown
+	server.onShutdown = nil
+	server.mu.Unlock()
+	for i := len(callbacks) - 1; i >= 0; i-- {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					server.logger().Errorf("rpc server: shutdown callback panicked: %v\n%s", r, debug.Stack())
+				}
+			}()
+			callbacks[i]()
+		}()
+	}
+}
+
+func (server *Server) numActiveConn() int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return len(server.activeConn)
+}
@@ -0,0 +1,131 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsInFlightCalls(t *testing.T) {
+	server, addr := startServer(t)
+	client := dialServer(t, addr)
+
+	call := client.Go("Foo.Sleep", Args{Num1: 200, Num2: 1}, new(int), nil)
+	time.Sleep(50 * time.Millisecond) // 等待请求开始处理
+	start := time.Now()
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("expect Shutdown to wait for the in-flight call, took %v", elapsed)
+	}
+	<-call.Done
+	if call.Error != nil || *call.Reply.(*int) != 201 {
+		t.Fatalf("expect the in-flight call to complete, got %v", call.Error)
+	}
+	if c, err := Dial("tcp", addr, &Option{ConnectTimeout: time.Second}); err == nil {
+		_ = c.Close()
+		t.Fatal("expect new dials to be refused after Shutdown")
+	}
+}
+
+func TestShutdownContextDeadline(t *testing.T) {
+	server, addr := startServer(t)
+	client := dialServer(t, addr)
+	client.Go("Foo.Sleep", Args{Num1: 500}, new(int), nil)
+	time.Sleep(20 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect ctx.Err() when the calls don't drain in time, got %v", err)
+	}
+}
+
+func TestShutdownUnresponsiveRegistry(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "DELETE" {
+			<-req.Context().Done() // 注销请求一直得不到响应
+		}
+	}))
+	defer ts.Close()
+
+	server, addr := startServer(t)
+	if err := server.RegisterWithRegistry(ts.URL, "tcp@"+addr, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_ = server.Shutdown(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expect Shutdown to stop waiting for the registry when ctx ends, took %v", elapsed)
+	}
+}
+
+func TestRegisterWithRegistryRetries(t *testing.T) {
+	var deleted atomic.Bool
+	var posts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "POST":
+			if posts.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable) // 第一次心跳失败
+			}
+		case "DELETE":
+			deleted.Store(true)
+		}
+	}))
+	defer ts.Close()
+
+	server, addr := startServer(t)
+	if err := server.RegisterWithRegistry(ts.URL, "tcp@"+addr, 10*time.Millisecond); err == nil {
+		t.Fatal("expect the first heartbeat to fail")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := posts.Load(); n < 2 {
+		t.Fatalf("expect heartbeats to be retried, got %d", n)
+	}
+	_ = server.Shutdown(context.Background())
+	if !deleted.Load() {
+		t.Fatal("expect Shutdown to deregister even though the first heartbeat failed")
+	}
+}
+
+func TestRegisterOnShutdown(t *testing.T) {
+	g := new(Gauge)
+	server, addr := startServer(t, g)
+	logger := &captureLogger{}
+	server.SetLogger(logger)
+	client := dialServer(t, addr)
+	client.Go("Gauge.Hold", Args{Num1: 50}, new(int), nil)
+	waitFor(t, "the call to start", func() bool { return g.cur.Load() == 1 })
+
+	var order []string
+	server.RegisterOnShutdown(func() { order = append(order, "first") })
+	server.RegisterOnShutdown(func() { panic("cleanup failed") })
+	server.RegisterOnShutdown(func() {
+		if g.cur.Load() != 0 {
+			t.Error("expect callbacks to run after the in-flight call completes")
+		}
+		order = append(order, "second")
+	})
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order, []string{"second", "first"}) {
+		t.Fatalf("expect both callbacks to run in reverse order, got %v", order)
+	}
+	if !logger.contains("cleanup failed") {
+		t.Fatalf("expect the panic to be logged, got %v", logger.errors)
+	}
+	// 回调只执行一次
+	_ = server.Shutdown(context.Background())
+	if len(order) != 2 {
+		t.Fatalf("expect callbacks to run once, got %v", order)
+	}
+}
@@ -0,0 +1,234 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets 是延迟直方图各个桶的上界，最后还有一个不设上界的桶
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// SizeBuckets 是消息大小直方图各个桶的上界（字节），最后还有一个不设上界的桶
+var SizeBuckets = []int{64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20}
+
+// Stats 是服务端统计数据的快照
+type Stats struct {
+	TotalRequests uint64            // 已完成的请求数
+	InFlight      int64             // 正在处理的请求数
+	Errors        map[string]uint64 // 各个 ServiceMethod 返回错误的次数，未注册的方法计入 UnknownMethod
+	// Latency[i] 是延迟不超过 LatencyBuckets[i] 的请求数（不累加），
+	// 最后一个元素是超过所有上界的请求数
+	Latency []uint64
+	// RequestSize 和 ResponseSize 是各个 ServiceMethod 的请求消息体和响应大小的直方图，
+	// 未注册的方法计入 UnknownMethod，
+	// 桶与 SizeBuckets 对应，最后一个元素是超过所有上界的个数。
+	// 大小由编解码器报告（见 codec.Sizer），响应包括 header，流式响应的每条消息各计一次
+	RequestSize  map[string][]uint64
+	ResponseSize map[string][]uint64
+	// Connections 是正在服务的连接，按 RemoteAddr 排序
+	Connections []ConnStats
+}
+
+// UnknownMethod 是统计数据中未注册的 ServiceMethod 共用的键，
+// 避免客户端发送任意的方法名使统计数据无限增长
+const UnknownMethod = "<unknown>"
+
+// ConnStats 是一个连接的统计数据
+type ConnStats struct {
+	RemoteAddr string             // 客户端地址，连接不是 net.Conn 时为空
+	Compressor codec.CompressType // 协商的压缩算法，为空表示没有压缩
+	// OriginalBytes 和 CompressedBytes 是该连接上读写的消息体在压缩前后的累计字节数，
+	// 没有达到压缩阈值的消息体按原样计入两者
+	OriginalBytes   uint64
+	CompressedBytes uint64
+}
+
+// CompressionRatio 返回 CompressedBytes 与 OriginalBytes 之比，小于 1 表示压缩减少了传输的字节数。
+// 没有协商压缩算法或还没有消息体时返回 1
+func (cs ConnStats) CompressionRatio() float64 {
+	if cs.OriginalBytes == 0 {
+		return 1
+	}
+	return float64(cs.CompressedBytes) / float64(cs.OriginalBytes)
+}
+
+// connStat 是正在服务的连接在 serverStats 中的记录
+type connStat struct {
+	remoteAddr string
+	compressor codec.CompressType
+	counter    codec.CompressionCounter // 编解码器不统计压缩时为 nil
+}
+
+// serverStats 在处理请求时更新，计数器均为原子操作
+type serverStats struct {
+	total    atomic.Uint64
+	inFlight atomic.Int64
+	errors   sync.Map // ServiceMethod -> *atomic.Uint64
+	latency  []atomic.Uint64
+	reqSize  sync.Map                        // ServiceMethod -> []atomic.Uint64
+	respSize sync.Map                        // ServiceMethod -> []atomic.Uint64
+	conns    sync.Map                        // codec.Codec -> *connStat
+	known    func(serviceMethod string) bool // 报告 ServiceMethod 是否已注册
+
+	mu        sync.RWMutex // 保护 onRequest
+	onRequest []func(h *codec.Header, dur time.Duration, err error)
+}
+
+func newServerStats(known func(serviceMethod string) bool) *serverStats {
+	return &serverStats{latency: make([]atomic.Uint64, len(LatencyBuckets)+1), known: known}
+}
+
+// key 返回 serviceMethod 在统计数据中的键，未注册的方法返回 UnknownMethod
+func (s *serverStats) key(serviceMethod string) string {
+	if s.known(serviceMethod) {
+		return serviceMethod
+	}
+	return UnknownMethod
+}
+
+// begin 在开始处理请求时调用
+func (s *serverStats) begin() {
+	s.inFlight.Add(1)
+}
+
+// end 在请求的响应发送后调用
+func (s *serverStats) end(h *codec.Header, dur time.Duration, err error) {
+	s.inFlight.Add(-1)
+	s.record(h, dur, err)
+}
+
+// record 记录一个已完成的请求，包括没有进入处理阶段的无效请求
+func (s *serverStats) record(h *codec.Header, dur time.Duration, err error) {
+	s.total.Add(1)
+	if err != nil {
+		counter, _ := s.errors.LoadOrStore(s.key(h.ServiceMethod), new(atomic.Uint64))
+		counter.(*atomic.Uint64).Add(1)
+	}
+	i := 0
+	for i < len(LatencyBuckets) && dur > LatencyBuckets[i] {
+		i++
+	}
+	s.latency[i].Add(1)
+
+	s.mu.RLock()
+	callbacks := s.onRequest
+	s.mu.RUnlock()
+	for _, f := range callbacks {
+		f(h, dur, err)
+	}
+}
+
+// recordSize 在 sizes 中 serviceMethod 的直方图上记录一个大小为 n 的消息
+func (s *serverStats) recordSize(sizes *sync.Map, serviceMethod string, n int) {
+	key := s.key(serviceMethod)
+	v, ok := sizes.Load(key)
+	if !ok {
+		v, _ = sizes.LoadOrStore(key, make([]atomic.Uint64, len(SizeBuckets)+1))
+	}
+	i := 0
+	for i < len(SizeBuckets) && n > SizeBuckets[i] {
+		i++
+	}
+	v.([]atomic.Uint64)[i].Add(1)
+}
+
+// sizerOf 返回报告消息大小的编解码器，cc 被 flushCodec 包装时检查其内部的编解码器
+func sizerOf(cc codec.Codec) codec.Sizer {
+	if fc, ok := cc.(*flushCodec); ok {
+		cc = fc.Codec
+	}
+	sizer, _ := cc.(codec.Sizer)
+	return sizer
+}
+
+// trackConn 开始记录连接 cc 的统计数据，返回停止记录的函数
+func (s *serverStats) trackConn(cc codec.Codec, nc net.Conn, opt *Option) (untrack func()) {
+	cs := &connStat{}
+	if nc != nil {
+		cs.remoteAddr = nc.RemoteAddr().String()
+	}
+	inner := cc
+	if fc, ok := cc.(*flushCodec); ok {
+		inner = fc.Codec
+	}
+	if counter, ok := inner.(codec.CompressionCounter); ok && opt.Compressor != "" {
+		cs.compressor, cs.counter = opt.Compressor, counter
+	}
+	s.conns.Store(cc, cs)
+	return func() { s.conns.Delete(cc) }
+}
+
+func (s *serverStats) connSnapshot() []ConnStats {
+	var conns []ConnStats
+	s.conns.Range(func(_, v interface{}) bool {
+		cs := v.(*connStat)
+		st := ConnStats{RemoteAddr: cs.remoteAddr, Compressor: cs.compressor}
+		if cs.counter != nil {
+			st.OriginalBytes, st.CompressedBytes = cs.counter.CompressionCounts()
+		}
+		conns = append(conns, st)
+		return true
+	})
+	sort.Slice(conns, func(i, j int) bool { return conns[i].RemoteAddr < conns[j].RemoteAddr })
+	return conns
+}
+
+func sizeSnapshot(sizes *sync.Map) map[string][]uint64 {
+	m := make(map[string][]uint64)
+	sizes.Range(func(k, v interface{}) bool {
+		buckets := v.([]atomic.Uint64)
+		counts := make([]uint64, len(buckets))
+		for i := range buckets {
+			counts[i] = buckets[i].Load()
+		}
+		m[k.(string)] = counts
+		return true
+	})
+	return m
+}
+
+func (s *serverStats) snapshot() Stats {
+	st := Stats{
+		TotalRequests: s.total.Load(),
+		InFlight:      s.inFlight.Load(),
+		Errors:        make(map[string]uint64),
+		Latency:       make([]uint64, len(s.latency)),
+		RequestSize:   sizeSnapshot(&s.reqSize),
+		ResponseSize:  sizeSnapshot(&s.respSize),
+		Connections:   s.connSnapshot(),
+	}
+	s.errors.Range(func(k, v interface{}) bool {
+		st.Errors[k.(string)] = v.(*atomic.Uint64).Load()
+		return true
+	})
+	for i := range s.latency {
+		st.Latency[i] = s.latency[i].Load()
+	}
+	return st
+}
+
+// Stats 返回服务端统计数据的快照
+func (server *Server) Stats() Stats {
+	return server.stats.snapshot()
+}
+
+// OnRequest 注册一个回调，在每个请求的响应发送后调用，
+// err 为返回给客户端的错误。回调在处理请求的 goroutine 中同步执行，不应阻塞
+func (server *Server) OnRequest(f func(h *codec.Header, dur time.Duration, err error)) {
+	server.stats.mu.Lock()
+	defer server.stats.mu.Unlock()
+	server.stats.onRequest = append(server.stats.onRequest, f)
+}
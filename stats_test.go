@@ -0,0 +1,124 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStatsCounters(t *testing.T) {
+	server, addr := startServer(t)
+	var callbacks atomic.Int32
+	var failed atomic.Int32
+	server.OnRequest(func(h *codec.Header, dur time.Duration, err error) {
+		callbacks.Add(1)
+		if err != nil {
+			failed.Add(1)
+		}
+	})
+	client := dialServer(t, addr)
+	const n, m = 20, 5
+	for i := 0; i < n; i++ {
+		num2 := 1
+		if i < m {
+			num2 = 0 // 除以零返回错误
+		}
+		_ = client.Call(context.Background(), "Foo.Div", Args{Num1: i, Num2: num2}, new(int))
+	}
+
+	// 统计在响应发送之后更新
+	waitFor(t, "all requests to be recorded", func() bool { return server.Stats().TotalRequests == n })
+	st := server.Stats()
+	if st.TotalRequests != n || st.InFlight != 0 || st.Errors["Foo.Div"] != m {
+		t.Fatalf("expect %d requests with %d errors, got %d, %d in flight, %v", n, m, st.TotalRequests, st.InFlight, st.Errors)
+	}
+	var latencies uint64
+	for _, c := range st.Latency {
+		latencies += c
+	}
+	if latencies != n || len(st.Latency) != len(LatencyBuckets)+1 {
+		t.Fatalf("expect %d latencies in %d buckets, got %v", n, len(LatencyBuckets)+1, st.Latency)
+	}
+	if callbacks.Load() != n || failed.Load() != m {
+		t.Fatalf("expect OnRequest to see %d requests with %d errors, got %d, %d", n, m, callbacks.Load(), failed.Load())
+	}
+}
+
+func TestStatsInFlight(t *testing.T) {
+	server, addr := startServer(t)
+	client := dialServer(t, addr)
+	call := client.Go("Foo.Sleep", Args{Num1: 100}, new(int), nil)
+	time.Sleep(30 * time.Millisecond)
+	if n := server.Stats().InFlight; n != 1 {
+		t.Fatalf("expect 1 request in flight, got %d", n)
+	}
+	<-call.Done
+}
+
+func TestStatsUnknownMethods(t *testing.T) {
+	server, addr := startServer(t)
+	client := dialServer(t, addr)
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(context.Background(), "Foo.Div", Args{Num1: 1}, &reply); err == nil {
+		t.Fatal("expect an error when dividing by zero")
+	}
+	for i := 0; i < 20; i++ {
+		_ = client.Call(context.Background(), fmt.Sprintf("Foo.Missing%d", i), Args{}, &reply)
+		_ = client.Call(context.Background(), fmt.Sprintf("Missing%d.Sum", i), Args{}, &reply)
+	}
+
+	waitFor(t, "all requests to be recorded", func() bool { return server.Stats().TotalRequests == 42 })
+	st := server.Stats()
+	if len(st.Errors) != 2 || st.Errors["Foo.Div"] != 1 || st.Errors[UnknownMethod] != 40 {
+		t.Fatalf("expect Foo.Div and 40 calls under %s, got %v", UnknownMethod, st.Errors)
+	}
+	for _, sizes := range []map[string][]uint64{st.RequestSize, st.ResponseSize} {
+		for method := range sizes {
+			if method != "Foo.Sum" && method != "Foo.Div" && method != UnknownMethod {
+				t.Fatalf("expect no size histogram for %s", method)
+			}
+		}
+	}
+	if len(st.ResponseSize) > 0 && st.ResponseSize[UnknownMethod] == nil {
+		t.Fatalf("expect responses to unknown methods under %s, got %v", UnknownMethod, st.ResponseSize)
+	}
+}
+
+// sizeBucket 返回大小为 n 的消息在 SizeBuckets 直方图中的下标
+func sizeBucket(n int) int {
+	i := 0
+	for i < len(SizeBuckets) && n > SizeBuckets[i] {
+		i++
+	}
+	return i
+}
+
+func TestStatsPayloadSizes(t *testing.T) {
+	server, addr := startServer(t, Echo{})
+	client := dialServer(t, addr)
+	// 每个大小都远离桶的边界，分帧和 header 的开销不会让它落到相邻的桶
+	sizes := []int{300, 2000, 10000, 100000}
+	for _, n := range sizes {
+		if err := client.Call(context.Background(), "Echo.Bytes", make([]byte, n), new([]byte)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor(t, "all requests to be recorded", func() bool { return server.Stats().TotalRequests == uint64(len(sizes)) })
+	st := server.Stats()
+	want := make([]uint64, len(SizeBuckets)+1)
+	for _, n := range sizes {
+		want[sizeBucket(n)]++
+	}
+	for name, got := range map[string][]uint64{"request": st.RequestSize["Echo.Bytes"], "response": st.ResponseSize["Echo.Bytes"]} {
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("expect %s sizes in buckets %v, got %v", name, want, got)
+		}
+	}
+}
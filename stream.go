@@ -0,0 +1,223 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// ServerStream 是流式方法发送消息的流，流式方法的签名为
+//
+//	func (t *T) MethodName(argType T1, stream ServerStream) error
+//
+// 每次 Send 发送一条消息，方法返回后流结束，返回的错误会传给客户端
+type ServerStream interface {
+	// Send 发送一条消息，方法返回或请求超时后调用会返回错误
+	Send(msg interface{}) error
+	// Context 返回请求的 context，请求超时后被取消
+	Context() context.Context
+}
+
+var typeOfServerStream = reflect.TypeOf((*ServerStream)(nil)).Elem()
+
+// errStreamClosed 表示流式方法已经返回或请求已超时
+var errStreamClosed = errors.New("rpc server: stream is closed")
+
+// serverStream 将消息作为共享请求序列号的响应发送，StreamIndex 依次递增
+type serverStream struct {
+	ctx     context.Context
+	server  *Server
+	cc      codec.Codec
+	h       codec.Header // 请求头的副本，避免与最终响应竞争
+	sending *sync.Mutex
+
+	mu     sync.Mutex // 保护以下字段，并保证结束标记在所有消息之后发送
+	index  uint64
+	closed bool
+}
+
+var _ ServerStream = (*serverStream)(nil)
+
+func newServerStream(ctx context.Context, server *Server, cc codec.Codec, h *codec.Header, sending *sync.Mutex) *serverStream {
+	return &serverStream{
+		ctx:     ctx,
+		server:  server,
+		cc:      cc,
+		h:       codec.Header{ServiceMethod: h.ServiceMethod, Seq: h.Seq},
+		sending: sending,
+	}
+}
+
+func (s *serverStream) Send(msg interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errStreamClosed
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	s.index++
+	h := s.h
+	h.StreamIndex = s.index
+	s.sending.Lock()
+	defer s.sending.Unlock()
+	if err := s.cc.Write(&h, msg); err != nil {
+		s.server.logIOError("rpc server: write stream message error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// close 使之后的 Send 失败，在发送结束标记前调用
+func (s *serverStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+}
+
+// ClientStream 接收流式方法返回的消息
+type ClientStream struct {
+	ctx     context.Context
+	client  *Client
+	call    *Call
+	msgType reflect.Type // 消息的类型，不含指针
+
+	mu     sync.Mutex
+	queue  []streamMsg
+	notify chan struct{} // 有新消息时写入，容量为 1
+	done   bool
+	err    error // 流结束的原因，正常结束时为 io.EOF
+
+	// limit 大于 0 时队列中最多缓存 limit 条消息，队列已满时 receive 等待 Recv 取走消息，
+	// 期间连接上的其他响应也无法读取。space 在 Recv 取走消息时写入，容量为 1
+	limit int
+	space chan struct{}
+}
+
+type streamMsg struct {
+	msg interface{}
+	err error
+}
+
+// NewStream 调用流式方法，msg 必须为指针，用于指定消息的类型：
+// 每条消息解码到一个新分配的同类型实例中，由 Recv 返回。
+// 流式调用不经过客户端拦截器，ctx 结束时 Recv 返回 ctx.Err()
+func (client *Client) NewStream(ctx context.Context, serviceMethod string, args, msg interface{}) (*ClientStream, error) {
+	return client.newStream(ctx, serviceMethod, args, msg, 0)
+}
+
+// newStream 与 NewStream 相同，limit 大于 0 时限制缓存的消息数
+func (client *Client) newStream(ctx context.Context, serviceMethod string, args, msg interface{}, limit int) (*ClientStream, error) {
+	typ := reflect.TypeOf(msg)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		return nil, errors.New("rpc client: stream message must be a pointer")
+	}
+	stream := &ClientStream{
+		ctx:     ctx,
+		client:  client,
+		msgType: typ.Elem(),
+		notify:  make(chan struct{}, 1),
+		limit:   limit,
+		space:   make(chan struct{}, 1),
+	}
+	stream.call = &Call{
+		ServiceMethod: serviceMethod,
+		Args:          args,
+		Done:          make(chan *Call, 1),
+		stream:        stream,
+		deadline:      deadlineOf(ctx),
+	}
+	stream.call.trace.traceID, stream.call.trace.spanID = TraceFromContext(ctx)
+	stream.call.meta = MetaFromContext(ctx)
+	client.send(stream.call)
+	return stream, nil
+}
+
+// Recv 返回下一条消息，消息类型与 NewStream 的 msg 相同。
+// 流正常结束时返回 io.EOF，服务端方法返回错误时返回该错误
+func (s *ClientStream) Recv() (interface{}, error) {
+	for {
+		s.mu.Lock()
+		if len(s.queue) > 0 {
+			m := s.queue[0]
+			s.queue[0] = streamMsg{}
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			signal(s.space)
+			return m.msg, m.err
+		}
+		if s.done {
+			s.mu.Unlock()
+			return nil, s.err
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.notify:
+		case call := <-s.call.Done:
+			err := call.Error
+			if err == nil {
+				err = io.EOF
+			}
+			s.finish(err)
+		case <-s.ctx.Done():
+			s.client.removeCall(s.call.Seq)
+			s.finish(s.ctx.Err())
+		}
+	}
+}
+
+func (s *ClientStream) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	s.err = err
+}
+
+// receive 在 Client.receive 中调用，读取一条消息放入队列
+func (s *ClientStream) receive(cc codec.Codec) {
+	for s.limit > 0 {
+		s.mu.Lock()
+		full := len(s.queue) >= s.limit && !s.done
+		s.mu.Unlock()
+		if !full {
+			break
+		}
+		select {
+		case <-s.space:
+		case <-s.ctx.Done(): // 调用方不再接收，仍需读取消息体以免连接错位
+			s.limit = 0
+		}
+	}
+	msgv := reflect.New(s.msgType)
+	m := streamMsg{msg: msgv.Interface()}
+	if err := cc.ReadBody(m.msg); err != nil {
+		// 编解码器在解码失败时已消费掉整个消息体，流可以继续
+		m = streamMsg{err: withLayer(errors.New("reading body "+err.Error()), layerOf(err))}
+	}
+	s.mu.Lock()
+	s.queue = append(s.queue, m)
+	s.mu.Unlock()
+	signal(s.notify)
+}
+
+// streamOf 返回 h 对应的流式调用的流，h 不是流中的一条消息时返回 nil
+func (client *Client) streamOf(h *codec.Header) *ClientStream {
+	if h.StreamIndex == 0 || h.StreamEnd || h.Error != "" {
+		return nil
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if call := client.pending[h.Seq]; call != nil {
+		return call.stream
+	}
+	return nil
+}
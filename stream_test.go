@@ -0,0 +1,70 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Counter 是测试用的流式服务
+type Counter struct{}
+
+// Count 依次发送 1 到 n，n 为负数时在发送一条消息后返回错误
+func (c Counter) Count(n int, stream ServerStream) error {
+	if n < 0 {
+		_ = stream.Send(1)
+		return errors.New("count failed")
+	}
+	for i := 1; i <= n; i++ {
+		if err := stream.Send(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestStream(t *testing.T) {
+	_, addr := startServer(t, Counter{})
+	client := dialServer(t, addr)
+	stream, err := client.NewStream(context.Background(), "Counter.Count", 100, new(int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 100; i++ {
+		msg, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if n := *msg.(*int); n != i {
+			t.Fatalf("expect message %d, got %d", i, n)
+		}
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("expect io.EOF at the end, got %v", err)
+	}
+	// 流结束后连接仍可用于普通调用
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, got %d, %v", reply, err)
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	_, addr := startServer(t, Counter{})
+	client := dialServer(t, addr)
+	stream, err := client.NewStream(context.Background(), "Counter.Count", -1, new(int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := stream.Recv(); err != nil || *msg.(*int) != 1 {
+		t.Fatalf("expect the first message, got %v, %v", msg, err)
+	}
+	if _, err := stream.Recv(); err == nil || !strings.Contains(err.Error(), "count failed") {
+		t.Fatalf("expect the method error, got %v", err)
+	}
+	if _, err := client.NewStream(context.Background(), "Counter.Count", 1, 0); err == nil {
+		t.Fatal("expect a non-pointer message to be rejected")
+	}
+}
@@ -0,0 +1,133 @@
+package Go_rpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestCert 生成 CN 为 cn、对 127.0.0.1 有效的自签名证书，返回证书和只信任该证书的证书池
+func newTestCert(t testing.TB, cn string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		DNSNames:              []string{cn},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+// startTLSServer 启动注册了 Foo 的 TLS 服务端，返回监听地址
+func startTLSServer(t testing.TB, cfg *tls.Config) (*Server, string) {
+	t.Helper()
+	server := newTestServer(t)
+	l := listenTCP(t)
+	go server.ServeTLS(l, cfg)
+	return server, l.Addr().String()
+}
+
+func TestServeTLS(t *testing.T) {
+	cert, pool := newTestCert(t, "server")
+	_, addr := startTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	client, err := DialTLS("tcp", addr, &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3 over TLS, got %d, %v", reply, err)
+	}
+
+	// 不信任服务端证书时握手失败
+	if _, err := DialTLS("tcp", addr, &tls.Config{}, &Option{ConnectTimeout: time.Second}); err == nil {
+		t.Fatal("expect an untrusted certificate to be rejected")
+	}
+	// 不使用 TLS 的客户端无法完成调用
+	plain, err := Dial("tcp", addr, &Option{ConnectTimeout: time.Second})
+	if err == nil {
+		defer func() { _ = plain.Close() }()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := plain.Call(ctx, "Foo.Sum", Args{}, &reply); err == nil {
+			t.Fatal("expect a plain client to fail against a TLS server")
+		}
+	}
+}
+
+// Whoami 返回客户端证书中的 CN
+type Whoami struct{}
+
+func (Whoami) CN(ctx context.Context, args Args, reply *string) error {
+	id, ok := IdentityFromContext(ctx)
+	if !ok {
+		return errors.New("no identity")
+	}
+	*reply = id.CommonName
+	return nil
+}
+
+func TestServeTLSClientIdentity(t *testing.T) {
+	serverCert, serverPool := newTestCert(t, "server")
+	clientCert, clientPool := newTestCert(t, "alice")
+	server, addr := startTLSServer(t, &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientPool,
+	})
+	if err := server.Register(Whoami{}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := DialTLS("tcp", addr, &tls.Config{RootCAs: serverPool, Certificates: []tls.Certificate{clientCert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var cn string
+	if err := client.Call(context.Background(), "Whoami.CN", Args{}, &cn); err != nil || cn != "alice" {
+		t.Fatalf("expect the method to see CN alice, got %q, %v", cn, err)
+	}
+
+	// 没有客户端证书的连接在握手时被拒绝
+	anonymous, err := DialTLS("tcp", addr, &tls.Config{RootCAs: serverPool}, &Option{ConnectTimeout: time.Second})
+	if err == nil {
+		defer func() { _ = anonymous.Close() }()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := anonymous.Call(ctx, "Whoami.CN", Args{}, &cn); err == nil {
+			t.Fatal("expect a client without a certificate to be rejected")
+		}
+	}
+}
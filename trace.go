@@ -0,0 +1,22 @@
+package Go_rpc
+
+import "context"
+
+type traceKey struct{}
+
+// traceInfo 是随请求传递的链路追踪标识
+type traceInfo struct {
+	traceID, spanID string
+}
+
+// ContextWithTrace 返回携带链路追踪标识的 ctx。
+// 客户端使用该 ctx 发起调用时，标识会写入请求头，服务端处理请求时可以通过 TraceFromContext 取得
+func ContextWithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, traceKey{}, traceInfo{traceID: traceID, spanID: spanID})
+}
+
+// TraceFromContext 返回 ctx 携带的链路追踪标识，未设置时返回空字符串
+func TraceFromContext(ctx context.Context) (traceID, spanID string) {
+	t, _ := ctx.Value(traceKey{}).(traceInfo)
+	return t.traceID, t.spanID
+}
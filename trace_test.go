@@ -0,0 +1,38 @@
+package Go_rpc
+
+import (
+	"context"
+	"testing"
+)
+
+// Tracer 返回服务端 ctx 中的链路追踪标识
+type Tracer struct{}
+
+type TraceIDs struct{ TraceID, SpanID string }
+
+func (Tracer) Echo(ctx context.Context, args Args, reply *TraceIDs) error {
+	reply.TraceID, reply.SpanID = TraceFromContext(ctx)
+	return nil
+}
+
+func TestTracePropagation(t *testing.T) {
+	_, addr := startServer(t, Tracer{})
+	client := dialServer(t, addr)
+
+	var reply TraceIDs
+	ctx := ContextWithTrace(context.Background(), "trace-1", "span-1")
+	if err := client.Call(ctx, "Tracer.Echo", Args{}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply != (TraceIDs{TraceID: "trace-1", SpanID: "span-1"}) {
+		t.Fatalf("expect the server to see trace-1/span-1, got %+v", reply)
+	}
+
+	reply = TraceIDs{}
+	if err := client.Call(context.Background(), "Tracer.Echo", Args{}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply != (TraceIDs{}) {
+		t.Fatalf("expect empty IDs without a trace, got %+v", reply)
+	}
+}
@@ -0,0 +1,20 @@
+package Go_rpc
+
+import "context"
+
+// TypedClient 是类型安全的调用函数，参数和结果都是具体类型，调用方无需类型断言
+type TypedClient[Arg, Reply any] func(ctx context.Context, arg Arg) (Reply, error)
+
+// NewTypedClient 将 c 上对 serviceMethod 的调用包装为 TypedClient：
+//
+//	sum := NewTypedClient[Args, int](client, "Foo.Sum")
+//	n, err := sum(ctx, Args{Num1: 1, Num2: 2})
+//
+// 调用经过 c.Call，与直接调用 Call 一样经过客户端拦截器
+func NewTypedClient[Arg, Reply any](c *Client, serviceMethod string) TypedClient[Arg, Reply] {
+	return func(ctx context.Context, arg Arg) (Reply, error) {
+		var reply Reply
+		err := c.Call(ctx, serviceMethod, arg, &reply)
+		return reply, err
+	}
+}
@@ -0,0 +1,21 @@
+package Go_rpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTypedClient(t *testing.T) {
+	_, addr := startServer(t, new(Arith))
+	client := dialServer(t, addr)
+
+	sum := NewTypedClient[Args, int](client, "Arith.Sum")
+	if n, err := sum(context.Background(), Args{Num1: 2, Num2: 3}); err != nil || n != 5 {
+		t.Fatalf("expect 5 from the typed client, got %d, %v", n, err)
+	}
+	div := NewTypedClient[Args, int](client, "Foo.Div")
+	if n, err := div(context.Background(), Args{Num1: 1}); err == nil || !strings.Contains(err.Error(), "divide by zero") || n != 0 {
+		t.Fatalf("expect the zero reply and the method error, got %d, %v", n, err)
+	}
+}
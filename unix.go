@@ -0,0 +1,50 @@
+package Go_rpc
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"time"
+)
+
+// ServeUnix 在 path 上监听 Unix 域套接字并处理请求，直到 Shutdown 关闭监听器。
+// 没有进程监听的残留套接字文件会被先删除，监听器关闭后套接字文件也会被删除
+func (server *Server) ServeUnix(path string) error {
+	if err := removeStaleSocket(path); err != nil {
+		return err
+	}
+	lis, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	lis.SetUnlinkOnClose(true)
+	server.Accept(lis)
+	return nil
+}
+
+// removeStaleSocket 删除 path 上无法连接的套接字文件，path 不存在时什么也不做。
+// path 是其他类型的文件或仍有进程在监听时返回错误
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("rpc server: %s exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("rpc server: %s is in use", path)
+	}
+	return os.Remove(path)
+}
+
+// DialUnix 通过 path 上的 Unix 域套接字连接 RPC 服务器
+func DialUnix(path string, opts ...*Option) (*Client, error) {
+	return Dial("unix", path, opts...)
+}
@@ -0,0 +1,76 @@
+package Go_rpc
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// socketPath 返回临时目录中的套接字路径，t.TempDir 的路径可能超过套接字路径的长度限制
+func socketPath(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return filepath.Join(dir, "rpc.sock")
+}
+
+func TestServeUnix(t *testing.T) {
+	path := socketPath(t)
+	// 留下没有进程监听的套接字文件
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	server := newTestServer(t)
+	served := make(chan error, 1)
+	go func() { served <- server.ServeUnix(path) }()
+	waitFor(t, "the socket to accept connections", func() bool {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			_ = conn.Close()
+		}
+		return err == nil
+	})
+
+	client, err := DialUnix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3 over the unix socket, got %d, %v", reply, err)
+	}
+	_ = client.Close()
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expect the socket file to be removed, got %v", err)
+	}
+}
+
+func TestServeUnixRefusesRegularFile(t *testing.T) {
+	path := socketPath(t)
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewServer().ServeUnix(path); err == nil {
+		t.Fatal("expect a regular file not to be removed")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expect the file to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package Go_rpc
+
+// workerPool 是固定数量的 worker goroutine，从 tasks 中依次取出请求处理。
+// task 的参数表示是否在 worker 中执行
+type workerPool struct {
+	tasks chan func(pooled bool)
+	quit  chan struct{} // 关闭后 worker 退出
+}
+
+func newWorkerPool(n int) *workerPool {
+	p := &workerPool{
+		tasks: make(chan func(pooled bool)), // 不带缓冲，所有 worker 忙碌时提交方会阻塞
+		quit:  make(chan struct{}),
+	}
+	for i := 0; i < n; i++ {
+		go p.run()
+	}
+	return p
+}
+
+func (p *workerPool) run() {
+	for {
+		select {
+		case task := <-p.tasks:
+			task(true)
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// submit 等待一个空闲的 worker 执行 task，worker 池已停止时改为在新的 goroutine 中执行
+func (p *workerPool) submit(task func(pooled bool)) {
+	select {
+	case p.tasks <- task:
+	case <-p.quit:
+		go task(false)
+	}
+}
+
+func (p *workerPool) stop() {
+	close(p.quit)
+}
+
+// SetWorkers 使用 n 个固定的 worker 处理所有连接上的请求，n <= 0 表示每个请求使用一个新的 goroutine（默认）。
+// 所有 worker 都在忙碌时，连接不会继续读取下一个请求，直到有 worker 空闲；
+// 心跳不占用 worker。方法直接在 worker 中执行，请求超时时仍按时回复超时错误，
+// 但 worker 在方法返回后才会空闲，应在 Accept 之前调用。
+// worker 取到请求时如果客户端设置的截止时间已过，直接回复超时错误而不调用方法
+func (server *Server) SetWorkers(n int) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.workers != nil {
+		server.workers.stop() // 正在执行的请求不受影响
+		server.workers = nil
+	}
+	if n > 0 {
+		server.workers = newWorkerPool(n)
+	}
+}
+
+// dispatch 在 worker 池或新的 goroutine 中执行 task
+func (server *Server) dispatch(task func(pooled bool)) {
+	server.mu.Lock()
+	workers := server.workers
+	server.mu.Unlock()
+	if workers == nil {
+		go task(false)
+		return
+	}
+	workers.submit(task)
+}
@@ -0,0 +1,126 @@
+package Go_rpc
+
+import (
+	"Go-rpc/codec"
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Gauge 记录同时执行的方法数的峰值
+type Gauge struct{ cur, peak atomic.Int32 }
+
+// Hold 等待 Num1 毫秒后返回
+func (g *Gauge) Hold(args Args, reply *int) error {
+	n := g.cur.Add(1)
+	defer g.cur.Add(-1)
+	for {
+		peak := g.peak.Load()
+		if n <= peak || g.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(time.Duration(args.Num1) * time.Millisecond)
+	return nil
+}
+
+func TestWorkersBoundConcurrency(t *testing.T) {
+	g := new(Gauge)
+	server, addr := startServer(t, g)
+	server.SetWorkers(2)
+	client := dialServer(t, addr)
+
+	done := make(chan *Call, 10)
+	for i := 0; i < 10; i++ {
+		client.Go("Gauge.Hold", Args{Num1: 20}, new(int), done)
+	}
+	for i := 0; i < 10; i++ {
+		if call := <-done; call.Error != nil {
+			t.Fatal(call.Error)
+		}
+	}
+	if peak := g.peak.Load(); peak != 2 {
+		t.Fatalf("expect at most 2 methods running at once, got %d", peak)
+	}
+}
+
+func TestWorkersHandleTimeout(t *testing.T) {
+	server, addr := startServer(t)
+	server.SetWorkers(1)
+	client := dialServer(t, addr, &Option{HandleTimeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	err := client.Call(context.Background(), "Foo.Sleep", Args{Num1: 300}, new(int))
+	if err == nil || !strings.Contains(err.Error(), "handle timeout") {
+		t.Fatalf("expect a handle timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("expect the timeout to be sent while the method still runs, took %v", elapsed)
+	}
+	// 唯一的 worker 在方法返回后才空闲，之后的请求仍能正常处理
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, got %d, %v", reply, err)
+	}
+}
+
+// benchmarkConcurrentRequests 每次迭代同时发出 10000 个请求并等待全部完成，workers 为 0 表示每个请求一个 goroutine
+func benchmarkConcurrentRequests(b *testing.B, workers int) {
+	const concurrency = 10000
+	server, addr := startServer(b)
+	server.SetWorkers(workers)
+	client := dialServer(b, addr)
+	done := make(chan *Call, concurrency)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < concurrency; j++ {
+			client.Go("Foo.Sum", Args{Num1: j, Num2: 1}, new(int), done)
+		}
+		for j := 0; j < concurrency; j++ {
+			if call := <-done; call.Error != nil {
+				b.Fatal(call.Error)
+			}
+		}
+	}
+}
+
+func BenchmarkGoroutinePerRequest(b *testing.B) { benchmarkConcurrentRequests(b, 0) }
+
+func BenchmarkWorkerPool(b *testing.B) { benchmarkConcurrentRequests(b, 64) }
+
+func TestWorkersDropStaleRequests(t *testing.T) {
+	g, events := new(Gauge), new(Events)
+	server, addr := startServer(t, g, events)
+	server.SetWorkers(1)
+	cc := dialRaw(t, addr, DefaultOption)
+
+	// 唯一的 worker 执行 100ms 的方法，之后的请求在队列中等到截止时间过去
+	if err := cc.Write(&codec.Header{ServiceMethod: "Gauge.Hold", Seq: 1}, Args{Num1: 100}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(30 * time.Millisecond).UnixNano()
+	for seq := uint64(2); seq <= 4; seq++ {
+		if err := cc.Write(&codec.Header{ServiceMethod: "Events.Record", Seq: seq, Deadline: deadline}, Args{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		var h codec.Header
+		if err := cc.ReadHeader(&h); err != nil {
+			t.Fatal(err)
+		}
+		_ = cc.ReadBody(nil)
+		switch {
+		case h.Seq == 1 && h.Error != "":
+			t.Fatalf("expect the slow request to complete, got %q", h.Error)
+		case h.Seq != 1 && h.Error != "rpc server: request deadline exceeded":
+			t.Fatalf("expect request %d to be dropped with a deadline error, got %q", h.Seq, h.Error)
+		}
+	}
+	if n := events.n.Load(); n != 0 {
+		t.Fatalf("expect the stale requests not to run, got %d calls", n)
+	}
+}
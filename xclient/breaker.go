@@ -0,0 +1,164 @@
+package xclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// BreakerState 是单个服务实例熔断器的状态
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 正常，请求可以通过
+	BreakerOpen                         // 连续失败次数达到阈值，冷却期内跳过该实例
+	BreakerHalfOpen                     // 冷却期已过，允许一个探测请求通过
+)
+
+// CircuitBreaker 是按服务实例地址区分的熔断器。
+// 连续失败 threshold 次后熔断器打开，cooldown 内该地址不会被选中；
+// 冷却期过后允许一个探测请求，成功则关闭熔断器，失败则重新开始冷却
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time // 便于替换时间来源
+
+	mu     sync.Mutex // 保护 states
+	states map[string]*breakerState
+}
+
+type breakerState struct {
+	failures int       // 连续失败次数
+	openedAt time.Time // 最近一次打开或放行探测请求的时间，零值表示关闭
+}
+
+// NewCircuitBreaker 创建熔断器，threshold <= 0 时熔断器永远不会打开
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		states:    make(map[string]*breakerState),
+	}
+}
+
+// State 返回 addr 的熔断器状态
+func (b *CircuitBreaker) State(addr string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.states[addr]
+	switch {
+	case s == nil || s.openedAt.IsZero():
+		return BreakerClosed
+	case b.now().Sub(s.openedAt) < b.cooldown:
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
+
+// Allow 返回是否可以向 addr 发送请求。
+// 半开状态下放行一个探测请求，并重新开始冷却，使探测结果未知时其他请求仍被跳过
+func (b *CircuitBreaker) Allow(addr string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.states[addr]
+	if s == nil || s.openedAt.IsZero() {
+		return true
+	}
+	now := b.now()
+	if now.Sub(s.openedAt) < b.cooldown {
+		return false
+	}
+	s.openedAt = now
+	return true
+}
+
+// Success 记录一次成功的请求，关闭 addr 的熔断器
+func (b *CircuitBreaker) Success(addr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.states, addr)
+}
+
+// Failure 记录一次失败的请求，连续失败达到阈值或探测失败时打开熔断器
+func (b *CircuitBreaker) Failure(addr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.states[addr]
+	if s == nil {
+		s = &breakerState{}
+		b.states[addr] = s
+	}
+	s.failures++
+	if b.threshold > 0 && s.failures >= b.threshold {
+		s.openedAt = b.now()
+	}
+}
+
+// BreakerDiscovery 包装 Discovery，选择服务实例时跳过熔断器打开的地址
+type BreakerDiscovery struct {
+	Discovery
+	b *CircuitBreaker
+}
+
+var _ WeightedDiscovery = (*BreakerDiscovery)(nil)
+
+// NewBreakerDiscovery 创建 BreakerDiscovery
+func NewBreakerDiscovery(d Discovery, b *CircuitBreaker) *BreakerDiscovery {
+	return &BreakerDiscovery{Discovery: d, b: b}
+}
+
+// Get 按 mode 选择一个熔断器允许通过的服务实例
+func (d *BreakerDiscovery) Get(mode SelectMode) (string, error) {
+	servers, err := d.Discovery.GetAll()
+	if err != nil {
+		return "", err
+	}
+	// 先按原有策略选择，使轮询和随机的分布不受影响
+	for i := 0; i < len(servers); i++ {
+		addr, err := d.Discovery.Get(mode)
+		if err != nil {
+			return "", err
+		}
+		if d.b.Allow(addr) {
+			return addr, nil
+		}
+	}
+	for _, addr := range servers {
+		if d.b.Allow(addr) {
+			return addr, nil
+		}
+	}
+	return "", errors.New("rpc discovery: all servers are unavailable (circuit open)")
+}
+
+// GetWeighted 返回熔断器没有打开的服务实例及其权重，结果是新分配的切片，不会修改 Discovery 的服务列表
+func (d *BreakerDiscovery) GetWeighted() ([]WeightedServer, error) {
+	servers, err := getWeighted(d.Discovery)
+	if err != nil {
+		return nil, err
+	}
+	available := make([]WeightedServer, 0, len(servers))
+	for _, s := range servers {
+		if d.b.State(s.Addr) != BreakerOpen {
+			available = append(available, s)
+		}
+	}
+	return available, nil
+}
+
+// GetAll 返回熔断器没有打开的服务实例，结果是新分配的切片，不会修改 Discovery 的服务列表
+func (d *BreakerDiscovery) GetAll() ([]string, error) {
+	servers, err := d.Discovery.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	available := make([]string, 0, len(servers))
+	for _, addr := range servers {
+		if d.b.State(addr) != BreakerOpen {
+			available = append(available, addr)
+		}
+	}
+	return available, nil
+}
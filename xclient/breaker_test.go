@@ -0,0 +1,108 @@
+package xclient
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewCircuitBreaker(2, time.Second)
+	b.now = func() time.Time { return now }
+
+	b.Failure("a")
+	if b.State("a") != BreakerClosed || !b.Allow("a") {
+		t.Fatal("expect the breaker to stay closed below the threshold")
+	}
+	b.Failure("a")
+	if b.State("a") != BreakerOpen || b.Allow("a") {
+		t.Fatal("expect the breaker to open at the threshold")
+	}
+	now = now.Add(time.Second)
+	if b.State("a") != BreakerHalfOpen {
+		t.Fatal("expect the breaker to be half open after the cooldown")
+	}
+	if !b.Allow("a") || b.Allow("a") {
+		t.Fatal("expect exactly one probe to be allowed")
+	}
+	now = now.Add(time.Second)
+	b.Failure("a") // 探测失败，重新开始冷却
+	if b.State("a") != BreakerOpen {
+		t.Fatal("expect a failed probe to reopen the breaker")
+	}
+	b.Success("a")
+	if b.State("a") != BreakerClosed {
+		t.Fatal("expect a success to close the breaker")
+	}
+}
+
+// sharedDiscovery 的 GetAll 直接返回内部的服务列表，不做拷贝
+type sharedDiscovery struct {
+	*MultiServersDiscovery
+	servers []string
+}
+
+func (d *sharedDiscovery) GetAll() ([]string, error) { return d.servers, nil }
+
+func TestBreakerDiscoveryKeepsUnderlyingList(t *testing.T) {
+	servers := []string{"a", "b", "c"}
+	inner := &sharedDiscovery{MultiServersDiscovery: NewMultiServerDiscovery(servers), servers: servers}
+	b := NewCircuitBreaker(1, time.Minute)
+	d := NewBreakerDiscovery(inner, b)
+	b.Failure("a")
+
+	available, err := d.GetAll()
+	if err != nil || !reflect.DeepEqual(available, []string{"b", "c"}) {
+		t.Fatalf("expect b and c, got %v, %v", available, err)
+	}
+	if !reflect.DeepEqual(servers, []string{"a", "b", "c"}) {
+		t.Fatalf("expect GetAll not to modify the underlying list, got %v", servers)
+	}
+	weighted, err := d.GetWeighted()
+	if err != nil || len(weighted) != 2 || weighted[0].Addr != "b" {
+		t.Fatalf("expect b and c with weights, got %v, %v", weighted, err)
+	}
+	if addr, err := d.Get(RoundRobinSelect); err != nil || addr == "a" {
+		t.Fatalf("expect Get to skip the open breaker, got %s, %v", addr, err)
+	}
+}
+
+func TestXClientBreaker(t *testing.T) {
+	down := deadAddr(t)
+	addrs := append([]string{down}, startNodes(t, 1)...)
+	xopt := &XClientOption{FailureThreshold: 2, Cooldown: time.Second}
+	xc := NewXClient(NewMultiServerDiscovery(addrs), RoundRobinSelect, nil, xopt)
+	defer func() { _ = xc.Close() }()
+	now := time.Now()
+	xc.Breaker().now = func() time.Time { return now }
+
+	// 轮询交替选中两个实例，已停止的实例连续失败两次后熔断
+	failed := 0
+	for i := 0; i < 4; i++ {
+		if err := xc.Call(context.Background(), "Node.Who", 0, new(int)); err != nil {
+			failed++
+		}
+	}
+	if failed != 2 || xc.Breaker().State(down) != BreakerOpen {
+		t.Fatalf("expect the breaker to open after 2 failures, got %d failures, state %v", failed, xc.Breaker().State(down))
+	}
+	for _, id := range who(t, xc, 4) {
+		if id != 0 {
+			t.Fatalf("expect the open address to be skipped, got %d", id)
+		}
+	}
+
+	// 在原地址上启动服务端，冷却结束后的探测成功会关闭熔断器
+	serveNode(t, strings.TrimPrefix(down, "tcp@"), &Node{ID: 1})
+	now = now.Add(time.Second)
+	seen := make(map[int]bool)
+	for _, id := range who(t, xc, 4) {
+		seen[id] = true
+	}
+	if !seen[1] || xc.Breaker().State(down) != BreakerClosed {
+		t.Fatalf("expect the address to recover after the cooldown, got %v, state %v", seen, xc.Breaker().State(down))
+	}
+}
@@ -0,0 +1,134 @@
+package xclient
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// SelectMode 表示负载均衡策略
+type SelectMode int
+
+const (
+	RandomSelect     SelectMode = iota // 随机选择
+	RoundRobinSelect                   // 轮询选择
+	// WeightedRoundRobinSelect 按权重平滑轮询选择，权重由 WeightedDiscovery 提供，
+	// Discovery 没有提供权重时等同于轮询
+	WeightedRoundRobinSelect
+	// LeastLatencySelect 选择平均响应时间最短的实例，响应时间由 XClient 记录，
+	// 因此只能用于 XClient，Discovery.Get 不支持该策略
+	LeastLatencySelect
+	// ConsistentHashSelect 按 WithHashKey 设置的哈希键在一致性哈希环上选择实例，
+	// 没有哈希键时随机选择；只能用于 XClient
+	ConsistentHashSelect
+)
+
+// Discovery 是服务发现的接口
+type Discovery interface {
+	Refresh() error                      // 从注册中心更新服务列表
+	Update(servers []string) error       // 手动更新服务列表
+	Get(mode SelectMode) (string, error) // 根据负载均衡策略选择一个服务实例
+	GetAll() ([]string, error)           // 返回所有服务实例
+}
+
+// MultiServersDiscovery 是不需要注册中心的服务发现，服务列表由用户显式提供
+type MultiServersDiscovery struct {
+	r       *rand.Rand   // 生成随机数
+	mu      sync.RWMutex // 保护以下字段
+	servers []string
+	index   int            // 记录轮询到的位置
+	weights map[string]int // 由 UpdateWeighted 设置的权重，没有设置的实例权重为 1
+	wrr     *smoothWeighted
+}
+
+var _ WeightedDiscovery = (*MultiServersDiscovery)(nil)
+
+// NewMultiServerDiscovery 创建 MultiServersDiscovery 实例
+func NewMultiServerDiscovery(servers []string) *MultiServersDiscovery {
+	d := &MultiServersDiscovery{
+		servers: servers,
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		wrr:     newSmoothWeighted(),
+	}
+	// 初始位置随机，避免每个客户端都从第一个服务开始
+	d.index = d.r.Intn(math.MaxInt32 - 1)
+	return d
+}
+
+// Refresh 对 MultiServersDiscovery 没有意义，直接忽略
+func (d *MultiServersDiscovery) Refresh() error {
+	return nil
+}
+
+// Update 动态更新服务列表
+func (d *MultiServersDiscovery) Update(servers []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.servers = servers
+	return nil
+}
+
+// UpdateWeighted 动态更新服务列表及其权重
+func (d *MultiServersDiscovery) UpdateWeighted(servers []WeightedServer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.servers = make([]string, len(servers))
+	d.weights = make(map[string]int, len(servers))
+	for i, s := range servers {
+		d.servers[i] = s.Addr
+		d.weights[s.Addr] = s.Weight
+	}
+	return nil
+}
+
+// GetWeighted 返回服务列表及其权重
+func (d *MultiServersDiscovery) GetWeighted() ([]WeightedServer, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.weighted(), nil
+}
+
+func (d *MultiServersDiscovery) weighted() []WeightedServer {
+	servers := make([]WeightedServer, len(d.servers))
+	for i, addr := range d.servers {
+		weight, ok := d.weights[addr]
+		if !ok {
+			weight = 1
+		}
+		servers[i] = WeightedServer{Addr: addr, Weight: weight}
+	}
+	return servers
+}
+
+// Get 根据 mode 选择一个服务实例
+func (d *MultiServersDiscovery) Get(mode SelectMode) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := len(d.servers)
+	if n == 0 {
+		return "", errors.New("rpc discovery: no available servers")
+	}
+	switch mode {
+	case RandomSelect:
+		return d.servers[d.r.Intn(n)], nil
+	case RoundRobinSelect:
+		s := d.servers[d.index%n] // 服务列表可能已更新，取模保证安全
+		d.index = (d.index + 1) % n
+		return s, nil
+	case WeightedRoundRobinSelect:
+		return d.wrr.next(d.weighted()), nil
+	default:
+		return "", errors.New("rpc discovery: not supported select mode")
+	}
+}
+
+// GetAll 返回服务列表的副本
+func (d *MultiServersDiscovery) GetAll() ([]string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	servers := make([]string, len(d.servers))
+	copy(servers, d.servers)
+	return servers, nil
+}
@@ -0,0 +1,150 @@
+package xclient
+
+import (
+	Go_rpc "Go-rpc"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SRVResolver 解析 DNS SRV 记录，*net.Resolver 实现了该接口
+type SRVResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*net.SRV, err error)
+}
+
+const (
+	defaultDNSTTL     = 30 * time.Second
+	dnsLookupTimeout  = 5 * time.Second
+	defaultDNSNetwork = "tcp"
+)
+
+// DNSDiscovery 是基于 DNS SRV 记录的服务发现，SRV 记录的目标作为服务实例，
+// 记录的权重用于 WeightedRoundRobinSelect，只使用优先级最高（Priority 最小）的记录。
+// 服务列表距上次解析超过 ttl 时会在 Get/GetAll 之前重新解析；
+// 解析失败时记录日志并继续使用上一次成功解析的服务列表
+type DNSDiscovery struct {
+	*MultiServersDiscovery
+	service, proto, name string
+	ttl                  time.Duration
+	// Resolver 用于解析 SRV 记录，默认为 net.DefaultResolver，应在第一次使用前设置
+	Resolver SRVResolver
+
+	refreshMu  sync.Mutex // 保护 lastUpdate 和 resolved，保证同时只有一个解析
+	lastUpdate time.Time
+	resolved   bool // 是否已成功解析过
+}
+
+var _ WeightedDiscovery = (*DNSDiscovery)(nil)
+
+// NewDNSDiscovery 创建 DNSDiscovery，解析 _service._proto.name 的 SRV 记录，
+// service 和 proto 都为空时直接解析 name。proto 同时作为连接服务实例使用的网络，为空时使用 tcp。
+// ttl 为 0 时使用默认的 30s
+func NewDNSDiscovery(service, proto, name string, ttl time.Duration) *DNSDiscovery {
+	if ttl == 0 {
+		ttl = defaultDNSTTL
+	}
+	return &DNSDiscovery{
+		MultiServersDiscovery: NewMultiServerDiscovery(make([]string, 0)),
+		service:               service,
+		proto:                 proto,
+		name:                  name,
+		ttl:                   ttl,
+		Resolver:              net.DefaultResolver,
+	}
+}
+
+// Update 手动更新服务列表，下一次解析在 ttl 之后
+func (d *DNSDiscovery) Update(servers []string) error {
+	d.refreshMu.Lock()
+	defer d.refreshMu.Unlock()
+	d.lastUpdate = time.Now()
+	d.resolved = true
+	return d.MultiServersDiscovery.Update(servers)
+}
+
+// Refresh 服务列表过期时重新解析 SRV 记录。
+// 解析失败时保留上一次的服务列表，只有从未成功解析过时才返回错误
+func (d *DNSDiscovery) Refresh() error {
+	d.refreshMu.Lock()
+	defer d.refreshMu.Unlock()
+	if d.lastUpdate.Add(d.ttl).After(time.Now()) {
+		return nil
+	}
+	servers, err := d.resolve()
+	if err != nil {
+		Go_rpc.DefaultLogger().Errorf("rpc discovery: resolve SRV %s err: %v", d.name, err)
+		if d.resolved {
+			d.lastUpdate = time.Now() // 使用旧的服务列表直到下一个 ttl，避免每次调用都重新解析
+			return nil
+		}
+		return err
+	}
+	d.lastUpdate = time.Now()
+	d.resolved = true
+	return d.MultiServersDiscovery.UpdateWeighted(servers)
+}
+
+// resolve 解析 SRV 记录，返回优先级最高的目标
+func (d *DNSDiscovery) resolve() ([]WeightedServer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	defer cancel()
+	_, records, err := d.Resolver.LookupSRV(ctx, d.service, d.proto, d.name)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("no SRV records")
+	}
+	network := d.proto
+	if network == "" {
+		network = defaultDNSNetwork
+	}
+	priority := records[0].Priority
+	for _, r := range records {
+		priority = min(priority, r.Priority)
+	}
+	servers := make([]WeightedServer, 0, len(records))
+	for _, r := range records {
+		if r.Priority != priority {
+			continue
+		}
+		weight := int(r.Weight)
+		if weight == 0 { // 权重为 0 的记录被选中的机会很小，但仍然可用
+			weight = 1
+		}
+		host := strings.TrimSuffix(r.Target, ".")
+		servers = append(servers, WeightedServer{
+			Addr:   network + "@" + net.JoinHostPort(host, strconv.Itoa(int(r.Port))),
+			Weight: weight,
+		})
+	}
+	return servers, nil
+}
+
+// Get 刷新服务列表后根据 mode 选择一个服务实例
+func (d *DNSDiscovery) Get(mode SelectMode) (string, error) {
+	if err := d.Refresh(); err != nil {
+		return "", err
+	}
+	return d.MultiServersDiscovery.Get(mode)
+}
+
+// GetWeighted 刷新服务列表后返回所有服务实例及其 SRV 权重
+func (d *DNSDiscovery) GetWeighted() ([]WeightedServer, error) {
+	if err := d.Refresh(); err != nil {
+		return nil, err
+	}
+	return d.MultiServersDiscovery.GetWeighted()
+}
+
+// GetAll 刷新服务列表后返回所有服务实例
+func (d *DNSDiscovery) GetAll() ([]string, error) {
+	if err := d.Refresh(); err != nil {
+		return nil, err
+	}
+	return d.MultiServersDiscovery.GetAll()
+}
@@ -0,0 +1,75 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResolver 返回固定的 SRV 记录，err 不为 nil 时解析失败
+type fakeResolver struct {
+	mu      sync.Mutex
+	records []*net.SRV
+	err     error
+	lookups int
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	if r.err != nil {
+		return "", nil, r.err
+	}
+	return "", r.records, nil
+}
+
+func (r *fakeResolver) fail(err error) {
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+}
+
+func TestDNSDiscovery(t *testing.T) {
+	r := &fakeResolver{records: []*net.SRV{
+		{Target: "a.example.", Port: 8001, Priority: 10, Weight: 3},
+		{Target: "b.example.", Port: 8002, Priority: 10, Weight: 0},
+		{Target: "backup.example.", Port: 8003, Priority: 20, Weight: 1},
+	}}
+	d := NewDNSDiscovery("rpc", "tcp", "example", 20*time.Millisecond)
+	d.Resolver = r
+
+	want := []string{"tcp@a.example:8001", "tcp@b.example:8002"}
+	servers, err := d.GetAll()
+	if err != nil || !reflect.DeepEqual(servers, want) {
+		t.Fatalf("expect the highest priority targets %v, got %v, %v", want, servers, err)
+	}
+	weighted, err := d.GetWeighted()
+	if err != nil || len(weighted) != 2 || weighted[0].Weight != 3 || weighted[1].Weight != 1 {
+		t.Fatalf("expect SRV weights 3 and 1, got %v, %v", weighted, err)
+	}
+	if r.lookups != 1 {
+		t.Fatalf("expect the records to be cached within the ttl, got %d lookups", r.lookups)
+	}
+
+	// ttl 过期后解析失败，继续使用上一次的服务列表
+	r.fail(errors.New("SERVFAIL"))
+	time.Sleep(30 * time.Millisecond)
+	if servers, err := d.GetAll(); err != nil || !reflect.DeepEqual(servers, want) {
+		t.Fatalf("expect the last known good set, got %v, %v", servers, err)
+	}
+	if r.lookups != 2 {
+		t.Fatalf("expect the records to be resolved again after the ttl, got %d lookups", r.lookups)
+	}
+
+	// 从未成功解析过时返回错误
+	fresh := NewDNSDiscovery("rpc", "tcp", "example", 0)
+	fresh.Resolver = r
+	if _, err := fresh.GetAll(); err == nil {
+		t.Fatal("expect an error before the first successful resolution")
+	}
+}
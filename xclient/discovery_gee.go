@@ -0,0 +1,152 @@
+package xclient
+
+import (
+	Go_rpc "Go-rpc"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GeeRegistryDiscovery 是基于注册中心的服务发现，
+// 服务列表距上次更新超过 timeout 时会在 Get/GetAll 之前从注册中心刷新
+type GeeRegistryDiscovery struct {
+	*MultiServersDiscovery
+	registry   string        // 注册中心地址
+	timeout    time.Duration // 服务列表的过期时间
+	lastUpdate time.Time     // 最后一次从注册中心更新服务列表的时间
+	inflight   *refreshCall  // 正在进行的刷新，没有时为 nil
+}
+
+// refreshCall 记录一次正在进行的刷新，done 关闭后 err 为刷新的结果
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+const defaultUpdateTimeout = time.Second * 10
+
+// NewGeeRegistryDiscovery 创建 GeeRegistryDiscovery，timeout 为 0 时使用默认的 10s
+func NewGeeRegistryDiscovery(registerAddr string, timeout time.Duration) *GeeRegistryDiscovery {
+	if timeout == 0 {
+		timeout = defaultUpdateTimeout
+	}
+	d := &GeeRegistryDiscovery{
+		MultiServersDiscovery: NewMultiServerDiscovery(make([]string, 0)),
+		registry:              registerAddr,
+		timeout:               timeout,
+	}
+	return d
+}
+
+// Update 手动更新服务列表
+func (d *GeeRegistryDiscovery) Update(servers []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.servers = servers
+	d.lastUpdate = time.Now()
+	return nil
+}
+
+// registryRequestTimeout 是从注册中心获取服务列表的最长时间
+const registryRequestTimeout = 5 * time.Second
+
+var registryClient = &http.Client{Timeout: registryRequestTimeout}
+
+// Refresh 服务列表过期时从注册中心重新获取。
+// 请求在不持有锁时进行，不影响其他 goroutine 使用当前的服务列表。已有刷新在进行时，
+// 有服务列表则直接返回，还没有服务列表时等待该次刷新完成并返回它的结果；
+// 请求失败或注册中心返回非 2xx 状态时保留上一次的服务列表，过期时间后再重试
+func (d *GeeRegistryDiscovery) Refresh() error {
+	d.mu.Lock()
+	if call := d.inflight; call != nil {
+		empty := len(d.servers) == 0
+		d.mu.Unlock()
+		if !empty {
+			return nil
+		}
+		<-call.done
+		return call.err
+	}
+	if d.lastUpdate.Add(d.timeout).After(time.Now()) {
+		d.mu.Unlock()
+		return nil
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	d.inflight = call
+	d.mu.Unlock()
+
+	Go_rpc.DefaultLogger().Debugf("rpc registry: refresh servers from registry %s", d.registry)
+	servers, err := fetchServers(d.registry)
+	d.mu.Lock()
+	d.inflight = nil
+	d.lastUpdate = time.Now()
+	if err != nil {
+		Go_rpc.DefaultLogger().Errorf("rpc registry refresh err: %v", err)
+	} else {
+		d.servers = servers
+	}
+	d.mu.Unlock()
+	call.err = err
+	close(call.done)
+	return err
+}
+
+// fetchServers 从注册中心获取存活的服务列表
+func fetchServers(registry string) ([]string, error) {
+	resp, err := registryClient.Get(registry)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, errors.New("rpc registry: registry returned " + resp.Status)
+	}
+	list := strings.Split(resp.Header.Get("X-Gorpc-Servers"), ",")
+	servers := make([]string, 0, len(list))
+	for _, server := range list {
+		if server = strings.TrimSpace(server); server != "" {
+			servers = append(servers, server)
+		}
+	}
+	return servers, nil
+}
+
+// refresh 刷新服务列表，刷新失败但仍有上一次的服务列表时继续使用它
+func (d *GeeRegistryDiscovery) refresh() error {
+	err := d.Refresh()
+	if err == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if len(d.servers) > 0 {
+		return nil
+	}
+	return err
+}
+
+// Get 刷新服务列表后根据 mode 选择一个服务实例
+func (d *GeeRegistryDiscovery) Get(mode SelectMode) (string, error) {
+	if err := d.refresh(); err != nil {
+		return "", err
+	}
+	return d.MultiServersDiscovery.Get(mode)
+}
+
+// GetWeighted 刷新服务列表后返回所有服务实例及其权重，注册中心不提供权重，
+// 通过 UpdateWeighted 设置过权重的实例仍使用设置的权重
+func (d *GeeRegistryDiscovery) GetWeighted() ([]WeightedServer, error) {
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+	return d.MultiServersDiscovery.GetWeighted()
+}
+
+// GetAll 刷新服务列表后返回所有服务实例
+func (d *GeeRegistryDiscovery) GetAll() ([]string, error) {
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+	return d.MultiServersDiscovery.GetAll()
+}
@@ -0,0 +1,156 @@
+package xclient
+
+import (
+	Go_rpc "Go-rpc"
+	"Go-rpc/registry"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeRegistry 是返回固定服务列表的注册中心，status 不为 0 时返回该状态码，delay 大于 0 时延迟响应
+type fakeRegistry struct {
+	servers atomic.Value // string
+	status  atomic.Int32
+	delay   atomic.Int64
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	time.Sleep(time.Duration(f.delay.Load()))
+	if status := f.status.Load(); status != 0 {
+		w.WriteHeader(int(status))
+		return
+	}
+	w.Header().Set("X-Gorpc-Servers", f.servers.Load().(string))
+}
+
+func startFakeRegistry(t *testing.T, servers string) (*fakeRegistry, string) {
+	f := &fakeRegistry{}
+	f.servers.Store(servers)
+	ts := httptest.NewServer(f)
+	t.Cleanup(ts.Close)
+	return f, ts.URL
+}
+
+func TestGeeRegistryDiscoveryRefresh(t *testing.T) {
+	f, url := startFakeRegistry(t, "tcp@a:1, tcp@b:1")
+	d := NewGeeRegistryDiscovery(url, 50*time.Millisecond)
+	if servers, err := d.GetAll(); err != nil || !reflect.DeepEqual(servers, []string{"tcp@a:1", "tcp@b:1"}) {
+		t.Fatalf("expect two servers, got %v, %v", servers, err)
+	}
+	f.servers.Store("tcp@a:1,tcp@b:1,tcp@c:1")
+	if servers, _ := d.GetAll(); len(servers) != 2 {
+		t.Fatalf("expect the cached list before the refresh interval, got %v", servers)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if servers, _ := d.GetAll(); len(servers) != 3 {
+		t.Fatalf("expect the new server after the refresh interval, got %v", servers)
+	}
+}
+
+func TestGeeRegistryDiscoveryKeepsLastGoodList(t *testing.T) {
+	f, url := startFakeRegistry(t, "tcp@a:1")
+	d := NewGeeRegistryDiscovery(url, 10*time.Millisecond)
+	if _, err := d.GetAll(); err != nil {
+		t.Fatal(err)
+	}
+	f.status.Store(http.StatusInternalServerError)
+	time.Sleep(20 * time.Millisecond)
+	if err := d.Refresh(); err == nil {
+		t.Fatal("expect Refresh to reject a non-2xx response")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if servers, err := d.GetAll(); err != nil || !reflect.DeepEqual(servers, []string{"tcp@a:1"}) {
+		t.Fatalf("expect the last good list, got %v, %v", servers, err)
+	}
+
+	empty := NewGeeRegistryDiscovery(url, 10*time.Millisecond)
+	if _, err := empty.GetAll(); err == nil {
+		t.Fatal("expect an error when there is no previous list")
+	}
+}
+
+func TestGeeRegistryDiscoverySlowRegistry(t *testing.T) {
+	f, url := startFakeRegistry(t, "tcp@a:1")
+	d := NewGeeRegistryDiscovery(url, 10*time.Millisecond)
+	if _, err := d.GetAll(); err != nil {
+		t.Fatal(err)
+	}
+	f.delay.Store(int64(300 * time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	go func() { _ = d.Refresh() }() // 一个 goroutine 在等待很慢的注册中心
+	time.Sleep(20 * time.Millisecond)
+	start := time.Now()
+	if servers, err := d.GetAll(); err != nil || len(servers) != 1 {
+		t.Fatalf("expect the cached list, got %v, %v", servers, err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("expect GetAll not to wait for the slow refresh, took %v", elapsed)
+	}
+}
+
+func TestGeeRegistryDiscoveryColdStart(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int32
+		wantErr bool
+	}{
+		{"ok", 0, false},
+		{"failed", http.StatusInternalServerError, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, url := startFakeRegistry(t, "tcp@a:1")
+			f.status.Store(tt.status)
+			f.delay.Store(int64(50 * time.Millisecond))
+			d := NewGeeRegistryDiscovery(url, time.Minute)
+			// 还没有服务列表时，并发的调用都等待同一次刷新并得到它的结果
+			errs := make(chan error, 10)
+			for i := 0; i < cap(errs); i++ {
+				go func() {
+					_, err := d.Get(RandomSelect)
+					errs <- err
+				}()
+			}
+			for i := 0; i < cap(errs); i++ {
+				if err := <-errs; (err != nil) != tt.wantErr {
+					t.Fatalf("expect error %v, got %v", tt.wantErr, err)
+				}
+			}
+		})
+	}
+}
+
+func TestShutdownDeregistersFromDiscovery(t *testing.T) {
+	reg := httptest.NewServer(registry.New(time.Minute))
+	defer reg.Close()
+
+	server := Go_rpc.NewServer()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(l)
+	self := "tcp@" + l.Addr().String()
+	// 心跳间隔和注册中心的超时都远长于测试时间，服务器只能因注销而消失
+	if err := server.RegisterWithRegistry(reg.URL, self, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewGeeRegistryDiscovery(reg.URL, 10*time.Millisecond)
+	if servers, err := d.GetAll(); err != nil || len(servers) != 1 || servers[0] != self {
+		t.Fatalf("expect discovery to list %s, got %v, %v", self, servers, err)
+	}
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond) // 等待服务列表过期
+	if servers, err := d.GetAll(); err != nil || len(servers) != 0 {
+		t.Fatalf("expect the shut down server to disappear, got %v, %v", servers, err)
+	}
+}
@@ -0,0 +1,87 @@
+package xclient
+
+import (
+	"context"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultReplicas 是一致性哈希中每个实例的虚拟节点数
+const defaultReplicas = 100
+
+type hashKeyCtxKey struct{}
+
+// WithHashKey 返回携带哈希键的 ctx，ConsistentHashSelect 下相同的键总是选择同一个服务实例
+func WithHashKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKeyCtxKey{}, key)
+}
+
+// hashKeyFromContext 返回 WithHashKey 设置的哈希键
+func hashKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(hashKeyCtxKey{}).(string)
+	return key, ok
+}
+
+// hashRing 是带虚拟节点的一致性哈希环，实例增减时只有相邻区间的键会改变映射
+type hashRing struct {
+	hashes []uint32          // 已排序的虚拟节点哈希值
+	nodes  map[uint32]string // 虚拟节点哈希值 -> 实例地址
+}
+
+func newHashRing(servers []string, replicas int) *hashRing {
+	r := &hashRing{nodes: make(map[uint32]string, len(servers)*replicas)}
+	for _, addr := range servers {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + addr))
+			if _, ok := r.nodes[h]; ok {
+				continue // 哈希冲突时保留先加入的节点
+			}
+			r.nodes[h] = addr
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// walk 从 key 在环上的位置开始顺时针依次访问不同的实例，直到 visit 返回 true
+func (r *hashRing) walk(key string, visit func(addr string) bool) {
+	if len(r.hashes) == 0 {
+		return
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	seen := make(map[string]bool)
+	for i := 0; i < len(r.hashes); i++ {
+		addr := r.nodes[r.hashes[(start+i)%len(r.hashes)]]
+		if seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		if visit(addr) {
+			return
+		}
+	}
+}
+
+// hashRingCache 在服务列表不变时复用已构建的哈希环
+type hashRingCache struct {
+	mu      sync.Mutex // 保护以下字段
+	servers string     // 构建 ring 时的服务列表
+	ring    *hashRing
+}
+
+func (c *hashRingCache) get(servers []string) *hashRing {
+	sorted := append([]string(nil), servers...)
+	sort.Strings(sorted)
+	key := strings.Join(sorted, ",")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ring == nil || c.servers != key {
+		c.servers, c.ring = key, newHashRing(sorted, defaultReplicas)
+	}
+	return c.ring
+}
@@ -0,0 +1,64 @@
+package xclient
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+// lookup 返回 key 在哈希环上选择的实例
+func lookup(r *hashRing, key string) (addr string) {
+	r.walk(key, func(a string) bool {
+		addr = a
+		return true
+	})
+	return addr
+}
+
+func TestHashRingRemapsMinority(t *testing.T) {
+	const keys = 1000
+	before := newHashRing([]string{"a", "b", "c"}, defaultReplicas)
+	after := newHashRing([]string{"a", "b", "c", "d"}, defaultReplicas)
+	moved := 0
+	for i := 0; i < keys; i++ {
+		key := "key-" + strconv.Itoa(i)
+		if lookup(before, key) != lookup(before, key) {
+			t.Fatalf("expect %s to map to the same server", key)
+		}
+		old, cur := lookup(before, key), lookup(after, key)
+		if old == cur {
+			continue
+		}
+		if cur != "d" {
+			t.Fatalf("expect %s to move only to the new server, moved from %s to %s", key, old, cur)
+		}
+		moved++
+	}
+	// 理想情况下约 1/4 的键迁移到新实例
+	if moved == 0 || moved > keys/2 {
+		t.Fatalf("expect a minority of keys to be remapped, got %d of %d", moved, keys)
+	}
+}
+
+func TestXClientConsistentHash(t *testing.T) {
+	xc := NewXClient(NewMultiServerDiscovery(startNodes(t, 3)), ConsistentHashSelect, nil)
+	defer func() { _ = xc.Close() }()
+	seen := make(map[int]bool)
+	for i := 0; i < 20; i++ {
+		ctx := WithHashKey(context.Background(), "user-"+strconv.Itoa(i))
+		var first, second int
+		if err := xc.Call(ctx, "Node.Who", 0, &first); err != nil {
+			t.Fatal(err)
+		}
+		if err := xc.Call(ctx, "Node.Who", 0, &second); err != nil {
+			t.Fatal(err)
+		}
+		if first != second {
+			t.Fatalf("expect the same key to select the same server, got %d and %d", first, second)
+		}
+		seen[first] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("expect keys to spread over the servers, got %v", seen)
+	}
+}
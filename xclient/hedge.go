@@ -0,0 +1,115 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// HedgeConfig 是对冲请求的配置：调用在 Delay 内没有返回时，向另一个服务实例发送相同的请求，
+// 使用最先成功返回的结果并取消其余的调用。只对通过 SetIdempotent 声明为幂等的方法生效
+type HedgeConfig struct {
+	Delay     time.Duration // 发送下一个对冲请求前等待的时间，0 表示不使用对冲请求
+	MaxHedges int           // 除第一个请求外最多发送的对冲请求数，<= 0 时为 1
+}
+
+// SetIdempotent 将 serviceMethods 声明为幂等方法，
+// 设置了 XClientOption.Hedge 时这些方法的调用可以同时发送到多个服务实例
+func (xc *XClient) SetIdempotent(serviceMethods ...string) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	if xc.idempotent == nil {
+		xc.idempotent = make(map[string]bool)
+	}
+	for _, sm := range serviceMethods {
+		xc.idempotent[sm] = true
+	}
+}
+
+// shouldHedge 返回 serviceMethod 的调用是否使用对冲请求
+func (xc *XClient) shouldHedge(serviceMethod string) bool {
+	if xc.xopt.Hedge.Delay <= 0 {
+		return false
+	}
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	return xc.idempotent[serviceMethod]
+}
+
+// errNoHedgeTarget 表示所有实例都已尝试过，不再发送对冲请求
+var errNoHedgeTarget = errors.New("rpc xclient: no untried server for hedging")
+
+type hedgeResult struct {
+	reply interface{}
+	err   error
+}
+
+// hedgedCall 先向一个实例发送请求，每过 Delay 还没有结果时再向一个尚未尝试过的实例发送，
+// 返回第一个成功的结果；服务端返回错误时立即返回该错误，所有请求都因连接错误失败时返回最后一个错误。
+// 每个请求使用单独的 reply，返回前取消其余仍在进行的请求
+func (xc *XClient) hedgedCall(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	maxHedges := xc.xopt.Hedge.MaxHedges
+	if maxHedges <= 0 {
+		maxHedges = 1
+	}
+	results := make(chan hedgeResult, maxHedges+1) // 容量足够所有请求，返回后剩余的请求不会阻塞
+	tried := make(map[string]bool)
+	launch := func() error {
+		rpcAddr, err := xc.selectAddr(ctx, tried)
+		if err != nil {
+			return err
+		}
+		if tried[rpcAddr] { // 没有尚未尝试过的实例，不再发送对冲请求
+			return errNoHedgeTarget
+		}
+		tried[rpcAddr] = true
+		var r interface{}
+		if reply != nil {
+			r = reflect.New(reflect.ValueOf(reply).Elem().Type()).Interface()
+		}
+		go func() {
+			results <- hedgeResult{reply: r, err: xc.call(rpcAddr, ctx, serviceMethod, args, r)}
+		}()
+		return nil
+	}
+	if err := launch(); err != nil {
+		return err
+	}
+	inflight, hedges := 1, 0
+	timer := time.NewTimer(xc.xopt.Hedge.Delay)
+	defer timer.Stop()
+	var lastErr error
+	for {
+		select {
+		case res := <-results:
+			inflight--
+			if res.err == nil {
+				if reply != nil {
+					reflect.ValueOf(reply).Elem().Set(reflect.ValueOf(res.reply).Elem())
+				}
+				return nil
+			}
+			lastErr = res.err
+			if !retryable(res.err) {
+				return unwrapDialError(res.err)
+			}
+			if inflight == 0 { // 所有请求都已失败，还有对冲名额时立即换一个实例
+				if hedges >= maxHedges || launch() != nil {
+					return unwrapDialError(lastErr)
+				}
+				hedges++
+				inflight++
+				timer.Reset(xc.xopt.Hedge.Delay)
+			}
+		case <-timer.C:
+			if hedges < maxHedges && launch() == nil {
+				hedges++
+				inflight++
+				timer.Reset(xc.xopt.Hedge.Delay)
+			}
+		}
+	}
+}
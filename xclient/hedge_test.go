@@ -0,0 +1,61 @@
+package xclient
+
+import (
+	Go_rpc "Go-rpc"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestXClientHedge(t *testing.T) {
+	addrs, nodes := startNodesWith(t, 2)
+	nodes[0].delay.Store(int64(300 * time.Millisecond))
+	d := NewMultiServerDiscovery(addrs)
+	xopt := &XClientOption{Hedge: HedgeConfig{Delay: 20 * time.Millisecond}}
+	xc := NewXClient(d, RoundRobinSelect, nil, xopt)
+	defer func() { _ = xc.Close() }()
+	who(t, xc, 2) // 与两个实例都建立连接
+
+	// 记录慢实例上调用的结果
+	slowErr := make(chan error, 1)
+	xc.mu.Lock()
+	xc.clients[addrs[0]].Use(func(ctx context.Context, serviceMethod string, args, reply interface{}, next Go_rpc.Invoker) error {
+		err := next(ctx, serviceMethod, args, reply)
+		slowErr <- err
+		return err
+	})
+	xc.mu.Unlock()
+	// 让轮询先选中慢实例
+	resetIndex := func() {
+		d.mu.Lock()
+		d.index = 0
+		d.mu.Unlock()
+	}
+
+	// 没有声明为幂等的方法不发送对冲请求
+	resetIndex()
+	var id int
+	if err := xc.Call(context.Background(), "Node.Lag", 0, &id); err != nil || id != 0 {
+		t.Fatalf("expect the slow server to answer without hedging, got %d, %v", id, err)
+	}
+	<-slowErr
+
+	xc.SetIdempotent("Node.Lag")
+	resetIndex()
+	start := time.Now()
+	if err := xc.Call(context.Background(), "Node.Lag", 0, &id); err != nil || id != 1 {
+		t.Fatalf("expect the hedged request to the fast server to win, got %d, %v", id, err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("expect the fast response to be used, took %v", elapsed)
+	}
+	select {
+	case err := <-slowErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expect the slow call to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect the slow call to end")
+	}
+}
@@ -0,0 +1,75 @@
+package xclient
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	latencyAlpha       = 0.3 // EWMA 中最新一次延迟的权重
+	exploreProbability = 0.1 // LeastLatencySelect 随机选择实例的概率，使恢复的实例重新得到请求
+)
+
+// latencyTracker 记录每个服务实例的 EWMA 响应时间
+type latencyTracker struct {
+	mu   sync.Mutex // 保护以下字段
+	ewma map[string]time.Duration
+	r    *rand.Rand
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		ewma: make(map[string]time.Duration),
+		r:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// observe 记录一次调用的响应时间
+func (t *latencyTracker) observe(addr string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	old, ok := t.ewma[addr]
+	if !ok {
+		t.ewma[addr] = d
+		return
+	}
+	t.ewma[addr] = time.Duration(latencyAlpha*float64(d) + (1-latencyAlpha)*float64(old))
+}
+
+// latency 返回 addr 的 EWMA 响应时间，没有数据时返回 false
+func (t *latencyTracker) latency(addr string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d, ok := t.ewma[addr]
+	return d, ok
+}
+
+// order 返回 servers 的选择顺序：还没有数据的实例随机排在最前面，使它们尽快得到测量；
+// 其余实例按响应时间从小到大排列，并以 exploreProbability 的概率把一个随机实例提到最前面
+func (t *latencyTracker) order(servers []string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var cold, warm []string
+	for _, addr := range servers {
+		if _, ok := t.ewma[addr]; ok {
+			warm = append(warm, addr)
+		} else {
+			cold = append(cold, addr)
+		}
+	}
+	t.r.Shuffle(len(cold), func(i, j int) { cold[i], cold[j] = cold[j], cold[i] })
+	// 实例数量通常很少，插入排序即可
+	for i := 1; i < len(warm); i++ {
+		for j := i; j > 0 && t.ewma[warm[j]] < t.ewma[warm[j-1]]; j-- {
+			warm[j], warm[j-1] = warm[j-1], warm[j]
+		}
+	}
+	if len(warm) > 1 && t.r.Float64() < exploreProbability {
+		i := t.r.Intn(len(warm))
+		explore := warm[i]
+		copy(warm[1:i+1], warm[:i])
+		warm[0] = explore
+	}
+	return append(cold, warm...)
+}
@@ -0,0 +1,32 @@
+package xclient
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestXClientLeastLatency(t *testing.T) {
+	addrs, nodes := startNodesWith(t, 2)
+	nodes[0].delay.Store(int64(50 * time.Millisecond))
+	xc := NewXClient(NewMultiServerDiscovery(addrs), LeastLatencySelect, nil)
+	defer func() { _ = xc.Close() }()
+
+	counts := make(map[int]int)
+	for i := 0; i < 50; i++ {
+		var id int
+		if err := xc.Call(context.Background(), "Node.Lag", 0, &id); err != nil {
+			t.Fatal(err)
+		}
+		counts[id]++
+	}
+	// 冷启动时两个实例各被测量一次，之后除了随机探测都选择较快的实例
+	if counts[1] < 40 {
+		t.Fatalf("expect the fast server to get most calls, got %v", counts)
+	}
+	slow, ok0 := xc.Latency(addrs[0])
+	fast, ok1 := xc.Latency(addrs[1])
+	if !ok0 || !ok1 || slow <= fast {
+		t.Fatalf("expect the slow server to have a higher latency, got %v, %v", slow, fast)
+	}
+}
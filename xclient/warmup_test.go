@@ -0,0 +1,41 @@
+package xclient
+
+import (
+	Go_rpc "Go-rpc"
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+func TestXClientWarmup(t *testing.T) {
+	addrs := startNodes(t, 3)
+	d := NewMultiServerDiscovery(append(addrs, deadAddr(t)))
+	var dials atomic.Int32
+	opt := &Go_rpc.Option{Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+		dials.Add(1)
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}}
+	xc := NewXClient(d, RoundRobinSelect, opt)
+	defer func() { _ = xc.Close() }()
+
+	// 已停止的实例连接失败，不影响其他实例的预热
+	if err := xc.Warmup(context.Background()); err != nil {
+		t.Fatalf("expect dial failures to be non-fatal, got %v", err)
+	}
+	xc.mu.Lock()
+	cached := len(xc.clients)
+	xc.mu.Unlock()
+	if cached != 3 || dials.Load() != 4 {
+		t.Fatalf("expect 3 warm connections after 4 dials, got %d, %d", cached, dials.Load())
+	}
+
+	_ = d.Update(addrs)
+	seen := make(map[int]bool)
+	for _, id := range who(t, xc, 6) {
+		seen[id] = true
+	}
+	if len(seen) != 3 || dials.Load() != 4 {
+		t.Fatalf("expect calls to reach all 3 servers over warm connections, got %v after %d dials", seen, dials.Load())
+	}
+}
@@ -0,0 +1,72 @@
+package xclient
+
+import "sync"
+
+// WeightedServer 是带权重的服务实例
+type WeightedServer struct {
+	Addr   string
+	Weight int // 权重，<= 0 时视为 1
+}
+
+// WeightedDiscovery 是可以返回服务实例权重的 Discovery，
+// 没有实现该接口的 Discovery 在 WeightedRoundRobinSelect 下所有实例权重相同
+type WeightedDiscovery interface {
+	Discovery
+	GetWeighted() ([]WeightedServer, error) // 返回所有服务实例及其权重
+}
+
+// getWeighted 返回 d 中所有服务实例的权重
+func getWeighted(d Discovery) ([]WeightedServer, error) {
+	if wd, ok := d.(WeightedDiscovery); ok {
+		return wd.GetWeighted()
+	}
+	servers, err := d.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	weighted := make([]WeightedServer, len(servers))
+	for i, addr := range servers {
+		weighted[i] = WeightedServer{Addr: addr, Weight: 1}
+	}
+	return weighted, nil
+}
+
+// smoothWeighted 实现平滑加权轮询（与 nginx 相同）：
+// 每次选择时所有实例的当前权重加上各自的权重，选出当前权重最大的实例后减去总权重，
+// 使权重高的实例被均匀地穿插选中，而不是连续选中
+type smoothWeighted struct {
+	mu      sync.Mutex     // 保护 current
+	current map[string]int // 地址 -> 当前权重
+}
+
+func newSmoothWeighted() *smoothWeighted {
+	return &smoothWeighted{current: make(map[string]int)}
+}
+
+// next 从 servers 中选择一个实例，servers 不能为空。服务列表变化时，已移除实例的状态被丢弃
+func (w *smoothWeighted) next(servers []WeightedServer) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	total, best := 0, -1
+	seen := make(map[string]bool, len(servers))
+	for i, s := range servers {
+		weight := s.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		w.current[s.Addr] += weight
+		seen[s.Addr] = true
+		if best < 0 || w.current[s.Addr] > w.current[servers[best].Addr] {
+			best = i
+		}
+	}
+	for addr := range w.current {
+		if !seen[addr] {
+			delete(w.current, addr)
+		}
+	}
+	addr := servers[best].Addr
+	w.current[addr] -= total
+	return addr
+}
@@ -0,0 +1,58 @@
+package xclient
+
+import "testing"
+
+func TestWeightedRoundRobin(t *testing.T) {
+	d := NewMultiServerDiscovery(nil)
+	_ = d.UpdateWeighted([]WeightedServer{{Addr: "a", Weight: 5}, {Addr: "b", Weight: 1}, {Addr: "c", Weight: 1}})
+	counts := make(map[string]int)
+	run := 0 // 连续选中 a 的次数，平滑轮询把 a 分散在 b 和 c 之间
+	for i := 0; i < 700; i++ {
+		addr, err := d.Get(WeightedRoundRobinSelect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[addr]++
+		if addr != "a" {
+			run = 0
+		} else if run++; run > 4 {
+			t.Fatalf("expect a to be spread out, got %d in a row", run)
+		}
+	}
+	for addr, want := range map[string]int{"a": 500, "b": 100, "c": 100} {
+		if got := counts[addr]; got < want-10 || got > want+10 {
+			t.Fatalf("expect about %d selections of %s, got %v", want, addr, counts)
+		}
+	}
+}
+
+// plainDiscovery 隐藏 MultiServersDiscovery 的 GetWeighted，模拟不提供权重的 Discovery
+type plainDiscovery struct {
+	Discovery
+}
+
+func TestXClientWeighted(t *testing.T) {
+	addrs := startNodes(t, 3)
+	d := NewMultiServerDiscovery(nil)
+	_ = d.UpdateWeighted([]WeightedServer{{Addr: addrs[0], Weight: 5}, {Addr: addrs[1], Weight: 1}, {Addr: addrs[2], Weight: 1}})
+	xc := NewXClient(d, WeightedRoundRobinSelect, nil)
+	defer func() { _ = xc.Close() }()
+	counts := make(map[int]int)
+	for _, id := range who(t, xc, 70) {
+		counts[id]++
+	}
+	if counts[0] != 50 || counts[1] != 10 || counts[2] != 10 {
+		t.Fatalf("expect 50/10/10 calls, got %v", counts)
+	}
+
+	// 没有实现 WeightedDiscovery 时所有实例权重相同
+	equal := NewXClient(plainDiscovery{NewMultiServerDiscovery(addrs)}, WeightedRoundRobinSelect, nil)
+	defer func() { _ = equal.Close() }()
+	counts = make(map[int]int)
+	for _, id := range who(t, equal, 30) {
+		counts[id]++
+	}
+	if counts[0] != 10 || counts[1] != 10 || counts[2] != 10 {
+		t.Fatalf("expect equal weights without GetWeighted, got %v", counts)
+	}
+}
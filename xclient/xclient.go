@@ -0,0 +1,415 @@
+package xclient
+
+import (
+	Go_rpc "Go-rpc"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// XClientOption 是 XClient 的可选配置
+type XClientOption struct {
+	// MaxRetries 是连接错误时换一个服务实例重试的最大次数，0 表示不重试。
+	// 服务端返回的错误不会重试
+	MaxRetries int
+	// Backoff 返回第 attempt 次重试（从 1 开始）前等待的时间，为 nil 时使用指数退避
+	Backoff func(attempt int) time.Duration
+	// FailureThreshold 是打开某个服务实例熔断器所需的连续连接错误次数，0 表示不使用熔断器
+	FailureThreshold int
+	// Cooldown 是熔断器打开后跳过该实例的时间，之后允许一个探测请求
+	Cooldown time.Duration
+	// Hedge 是幂等方法的对冲请求配置，零值表示不使用对冲请求。使用对冲请求的调用不再按 MaxRetries 重试
+	Hedge HedgeConfig
+}
+
+// XClient 是支持负载均衡的客户端，每次调用通过 Discovery 选择一个服务实例
+type XClient struct {
+	d       Discovery
+	mode    SelectMode
+	opt     *Go_rpc.Option
+	xopt    XClientOption
+	breaker *CircuitBreaker // 未启用熔断时为 nil
+	wrr     *smoothWeighted // WeightedRoundRobinSelect 的选择状态
+	latency *latencyTracker // 每个实例的响应时间，用于 LeastLatencySelect
+	ring    hashRingCache   // ConsistentHashSelect 使用的哈希环
+	mu      sync.Mutex      // 保护 clients 和 idempotent
+	clients map[string]*Go_rpc.Client
+
+	idempotent map[string]bool // 通过 SetIdempotent 声明的幂等方法
+}
+
+var _ io.Closer = (*XClient)(nil)
+
+// NewXClient 创建 XClient，opt 为 nil 时使用默认选项，xopt 最多传入一个
+func NewXClient(d Discovery, mode SelectMode, opt *Go_rpc.Option, xopt ...*XClientOption) *XClient {
+	xc := &XClient{d: d, mode: mode, opt: opt, wrr: newSmoothWeighted(), latency: newLatencyTracker(), clients: make(map[string]*Go_rpc.Client)}
+	if len(xopt) > 0 && xopt[0] != nil {
+		xc.xopt = *xopt[0]
+	}
+	if xc.xopt.Backoff == nil {
+		xc.xopt.Backoff = defaultBackoff
+	}
+	if xc.xopt.FailureThreshold > 0 {
+		xc.breaker = NewCircuitBreaker(xc.xopt.FailureThreshold, xc.xopt.Cooldown)
+		xc.d = NewBreakerDiscovery(d, xc.breaker)
+	}
+	return xc
+}
+
+const (
+	baseBackoff = 100 * time.Millisecond
+	maxBackoff  = 5 * time.Second
+)
+
+// defaultBackoff 从 100ms 开始每次翻倍，最多 5s
+func defaultBackoff(attempt int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// Close 关闭所有缓存的连接
+func (xc *XClient) Close() error {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	for key, client := range xc.clients {
+		// 只关闭连接，忽略错误
+		_ = client.Close()
+		delete(xc.clients, key)
+	}
+	return nil
+}
+
+// dial 返回 rpcAddr 对应的缓存连接，连接不可用时重新建立。
+// 建立连接时不持有锁，不同实例的连接可以同时建立
+func (xc *XClient) dial(rpcAddr string) (*Go_rpc.Client, error) {
+	if client := xc.cached(rpcAddr); client != nil {
+		return client, nil
+	}
+	client, err := Go_rpc.XDial(rpcAddr, xc.opt)
+	if err != nil {
+		return nil, err
+	}
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	if cur, ok := xc.clients[rpcAddr]; ok && cur.IsAvailable() {
+		_ = client.Close() // 其他调用已经建立了连接
+		return cur, nil
+	}
+	xc.clients[rpcAddr] = client
+	return client, nil
+}
+
+// cached 返回 rpcAddr 对应的可用缓存连接，没有时返回 nil，并关闭已不可用的连接
+func (xc *XClient) cached(rpcAddr string) *Go_rpc.Client {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	client, ok := xc.clients[rpcAddr]
+	if ok && !client.IsAvailable() {
+		_ = client.Close()
+		delete(xc.clients, rpcAddr)
+		client = nil
+	}
+	return client
+}
+
+// Warmup 并行地与当前发现的所有服务实例建立连接并缓存，避免第一次调用时才建立连接带来的延迟。
+// 连接失败只记录日志，之后的调用会重新尝试连接。
+// 所有连接建立完成或 ctx 结束时返回，ctx 结束后仍在进行的连接在后台完成
+func (xc *XClient) Warmup(ctx context.Context) error {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	for _, rpcAddr := range servers {
+		wg.Add(1)
+		go func(rpcAddr string) {
+			defer wg.Done()
+			if _, err := xc.dial(rpcAddr); err != nil {
+				Go_rpc.DefaultLogger().Errorf("rpc xclient: warmup %s err: %v", rpcAddr, err)
+			}
+		}(rpcAddr)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (xc *XClient) call(rpcAddr string, ctx context.Context, serviceMethod string, args, reply interface{}) (err error) {
+	if xc.breaker != nil {
+		defer func() { xc.recordResult(rpcAddr, err) }()
+	}
+	client, err := xc.dial(rpcAddr)
+	if err != nil {
+		return &dialError{err}
+	}
+	start := time.Now()
+	err = client.Call(ctx, serviceMethod, args, reply)
+	// 连接错误和主动取消不能反映实例的响应速度，超时则说明实例很慢
+	if !retryable(err) && !errors.Is(err, context.Canceled) {
+		xc.latency.observe(rpcAddr, time.Since(start))
+	}
+	return err
+}
+
+// Latency 返回 XClient 记录的 rpcAddr 的平均响应时间（EWMA），还没有数据时返回 false
+func (xc *XClient) Latency(rpcAddr string) (time.Duration, bool) {
+	return xc.latency.latency(rpcAddr)
+}
+
+// recordResult 把调用结果记录到熔断器：连接错误计为失败，
+// 服务端返回的错误说明实例可用，计为成功，调用被取消时不记录
+func (xc *XClient) recordResult(rpcAddr string, err error) {
+	switch {
+	case retryable(err):
+		xc.breaker.Failure(rpcAddr)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+	default:
+		xc.breaker.Success(rpcAddr)
+	}
+}
+
+// Breaker 返回 XClient 使用的熔断器，未设置 FailureThreshold 时返回 nil
+func (xc *XClient) Breaker() *CircuitBreaker {
+	return xc.breaker
+}
+
+// dialError 表示连接服务实例失败，总是可以重试
+type dialError struct {
+	err error
+}
+
+func (e *dialError) Error() string { return e.err.Error() }
+func (e *dialError) Unwrap() error { return e.err }
+
+// retryable 返回 err 是否为可以换一个服务实例重试的连接错误
+func retryable(err error) bool {
+	var de *dialError
+	var ne net.Error
+	switch {
+	case errors.As(err, &de):
+		return true
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.As(err, new(Go_rpc.ServerError)):
+		return false
+	}
+	return errors.Is(err, Go_rpc.ErrShutdown) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &ne)
+}
+
+// Call 通过负载均衡选择一个服务实例，调用指定的方法并等待其完成。
+// 设置了 MaxRetries 时，连接错误会等待 Backoff 后优先选择尚未尝试过的实例重试，
+// 等待会超过 ctx 的截止时间时提前返回最后一次的错误。
+// 设置了 Hedge 且方法已声明为幂等时，改为使用对冲请求
+func (xc *XClient) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	if xc.shouldHedge(serviceMethod) {
+		return xc.hedgedCall(ctx, serviceMethod, args, reply)
+	}
+	tried := make(map[string]bool)
+	for attempt := 0; ; attempt++ {
+		rpcAddr, err := xc.selectAddr(ctx, tried)
+		if err != nil {
+			return err
+		}
+		err = xc.call(rpcAddr, ctx, serviceMethod, args, reply)
+		if err == nil || attempt >= xc.xopt.MaxRetries || !retryable(err) {
+			return unwrapDialError(err)
+		}
+		tried[rpcAddr] = true
+		wait := xc.xopt.Backoff(attempt + 1)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return unwrapDialError(err)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return unwrapDialError(err)
+		case <-timer.C:
+		}
+	}
+}
+
+// selectAddr 按负载均衡策略选择服务实例，选中的实例已尝试过时改为选择第一个未尝试过的实例
+func (xc *XClient) selectAddr(ctx context.Context, tried map[string]bool) (string, error) {
+	mode := xc.mode
+	switch mode {
+	case WeightedRoundRobinSelect:
+		return xc.selectWeighted(tried)
+	case LeastLatencySelect:
+		return xc.selectLeastLatency(tried)
+	case ConsistentHashSelect:
+		if key, ok := hashKeyFromContext(ctx); ok {
+			return xc.selectConsistentHash(key, tried)
+		}
+		mode = RandomSelect
+	}
+	rpcAddr, err := xc.d.Get(mode)
+	if err != nil || !tried[rpcAddr] {
+		return rpcAddr, err
+	}
+	if servers, err := xc.d.GetAll(); err == nil {
+		for _, addr := range servers {
+			if !tried[addr] {
+				return addr, nil
+			}
+		}
+	}
+	return rpcAddr, nil
+}
+
+// selectWeighted 按权重平滑轮询选择服务实例，Discovery 没有实现 WeightedDiscovery 时所有实例权重相同。
+// 跳过已尝试过和熔断器不允许通过的实例，所有实例都已尝试过时允许重复选择
+func (xc *XClient) selectWeighted(tried map[string]bool) (string, error) {
+	servers, err := getWeighted(xc.d)
+	if err != nil {
+		return "", err
+	}
+	if len(servers) == 0 {
+		return "", errors.New("rpc discovery: no available servers")
+	}
+	var fallback string
+	for i := 0; i < len(servers); i++ {
+		addr := xc.wrr.next(servers)
+		if tried[addr] {
+			if fallback == "" {
+				fallback = addr
+			}
+			continue
+		}
+		if xc.breaker == nil || xc.breaker.Allow(addr) {
+			return addr, nil
+		}
+	}
+	if fallback != "" {
+		return fallback, nil
+	}
+	return "", errors.New("rpc discovery: all servers are unavailable (circuit open)")
+}
+
+// selectLeastLatency 选择平均响应时间最短的实例，还没有数据的实例优先，以便测量它们的响应时间。
+// 跳过已尝试过和熔断器不允许通过的实例，所有实例都已尝试过时允许重复选择
+func (xc *XClient) selectLeastLatency(tried map[string]bool) (string, error) {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return "", err
+	}
+	if len(servers) == 0 {
+		return "", errors.New("rpc discovery: no available servers")
+	}
+	var fallback string
+	for _, addr := range xc.latency.order(servers) {
+		if tried[addr] {
+			if fallback == "" {
+				fallback = addr
+			}
+			continue
+		}
+		if xc.breaker == nil || xc.breaker.Allow(addr) {
+			return addr, nil
+		}
+	}
+	if fallback != "" {
+		return fallback, nil
+	}
+	return "", errors.New("rpc discovery: all servers are unavailable (circuit open)")
+}
+
+// selectConsistentHash 选择 key 在哈希环上顺时针方向的第一个实例。
+// 跳过已尝试过和熔断器不允许通过的实例，使重试落到环上的下一个实例
+func (xc *XClient) selectConsistentHash(key string, tried map[string]bool) (string, error) {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return "", err
+	}
+	if len(servers) == 0 {
+		return "", errors.New("rpc discovery: no available servers")
+	}
+	var rpcAddr, fallback string
+	xc.ring.get(servers).walk(key, func(addr string) bool {
+		if tried[addr] {
+			if fallback == "" {
+				fallback = addr
+			}
+			return false
+		}
+		if xc.breaker == nil || xc.breaker.Allow(addr) {
+			rpcAddr = addr
+			return true
+		}
+		return false
+	})
+	switch {
+	case rpcAddr != "":
+		return rpcAddr, nil
+	case fallback != "":
+		return fallback, nil
+	}
+	return "", errors.New("rpc discovery: all servers are unavailable (circuit open)")
+}
+
+func unwrapDialError(err error) error {
+	var de *dialError
+	if errors.As(err, &de) {
+		return de.err
+	}
+	return err
+}
+
+// Broadcast 并发地在所有服务实例上调用指定的方法。
+// 任意实例出错时返回第一个错误并取消其余调用；
+// reply 不为 nil 时只保存第一个成功的结果
+func (xc *XClient) Broadcast(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex // 保护 e 和 replyDone
+	var e error
+	replyDone := reply == nil // reply 为 nil 时不需要设置值
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for _, rpcAddr := range servers {
+		wg.Add(1)
+		go func(rpcAddr string) {
+			defer wg.Done()
+			var clonedReply interface{}
+			if reply != nil {
+				clonedReply = reflect.New(reflect.ValueOf(reply).Elem().Type()).Interface()
+			}
+			err := unwrapDialError(xc.call(rpcAddr, ctx, serviceMethod, args, clonedReply))
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && e == nil {
+				e = err
+				cancel() // 有一个调用失败时取消其余未完成的调用
+			}
+			if err == nil && !replyDone {
+				reflect.ValueOf(reply).Elem().Set(reflect.ValueOf(clonedReply).Elem())
+				replyDone = true
+			}
+		}(rpcAddr)
+	}
+	wg.Wait()
+	return e
+}
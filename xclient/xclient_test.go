@@ -0,0 +1,239 @@
+package xclient
+
+import (
+	Go_rpc "Go-rpc"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Node 是测试用的服务，记录自己的编号
+type Node struct {
+	ID    int
+	fails atomic.Int32 // Fail 被调用的次数
+	delay atomic.Int64 // Lag 返回前等待的纳秒数
+}
+
+// Who 返回处理请求的实例编号
+func (n *Node) Who(args int, reply *int) error {
+	*reply = n.ID
+	return nil
+}
+
+// Fail 总是返回错误
+func (n *Node) Fail(args int, reply *int) error {
+	n.fails.Add(1)
+	return errors.New("always fails")
+}
+
+// Lag 等待 delay 后返回实例编号
+func (n *Node) Lag(args int, reply *int) error {
+	time.Sleep(time.Duration(n.delay.Load()))
+	*reply = n.ID
+	return nil
+}
+
+// Work 在编号为 failID 的实例上立即失败，其他实例等待 ms 毫秒或 ctx 结束
+func (n *Node) Work(ctx context.Context, args [2]int, reply *int) error {
+	failID, ms := args[0], args[1]
+	if n.ID == failID {
+		return fmt.Errorf("node %d failed", n.ID)
+	}
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		*reply = n.ID
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startNodes 启动 n 个编号从 0 开始的服务端，返回形如 tcp@127.0.0.1:port 的地址
+func startNodes(t testing.TB, n int) []string {
+	addrs, _ := startNodesWith(t, n)
+	return addrs
+}
+
+// startNodesWith 与 startNodes 相同，同时返回每个服务端上注册的 Node
+func startNodesWith(t testing.TB, n int) ([]string, []*Node) {
+	t.Helper()
+	addrs, nodes := make([]string, n), make([]*Node, n)
+	for i := range addrs {
+		nodes[i] = &Node{ID: i}
+		addrs[i] = serveNode(t, "127.0.0.1:0", nodes[i])
+	}
+	return addrs, nodes
+}
+
+// serveNode 在 addr 上启动注册了 node 的服务端，返回形如 tcp@127.0.0.1:port 的地址，测试结束时关闭
+func serveNode(t testing.TB, addr string, node *Node) string {
+	t.Helper()
+	server := Go_rpc.NewServer()
+	if err := server.Register(node); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(l)
+	t.Cleanup(func() { _ = server.Shutdown(context.Background()) })
+	return "tcp@" + l.Addr().String()
+}
+
+// deadAddr 返回一个没有服务端监听的地址
+func deadAddr(t testing.TB) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = l.Close()
+	return "tcp@" + l.Addr().String()
+}
+
+// who 调用 n 次 Node.Who，返回每次处理请求的实例编号
+func who(t testing.TB, xc *XClient, n int) []int {
+	t.Helper()
+	ids := make([]int, n)
+	for i := range ids {
+		if err := xc.Call(context.Background(), "Node.Who", 0, &ids[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ids
+}
+
+func TestXClientRoundRobin(t *testing.T) {
+	xc := NewXClient(NewMultiServerDiscovery(startNodes(t, 3)), RoundRobinSelect, nil)
+	defer func() { _ = xc.Close() }()
+	ids := who(t, xc, 6)
+	for i := 3; i < len(ids); i++ {
+		if ids[i] != ids[i-3] || ids[i] == ids[i-1] {
+			t.Fatalf("expect round robin over 3 servers, got %v", ids)
+		}
+	}
+	xc.mu.Lock()
+	cached := len(xc.clients)
+	xc.mu.Unlock()
+	if cached != 3 {
+		t.Fatalf("expect one cached client per server, got %d", cached)
+	}
+}
+
+func TestXClientRandom(t *testing.T) {
+	xc := NewXClient(NewMultiServerDiscovery(startNodes(t, 3)), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+	seen := make(map[int]bool)
+	for _, id := range who(t, xc, 60) {
+		seen[id] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("expect random select to reach every server, got %v", seen)
+	}
+}
+
+func TestXClientRedialsBrokenClient(t *testing.T) {
+	addrs := startNodes(t, 1)
+	xc := NewXClient(NewMultiServerDiscovery(addrs), RoundRobinSelect, nil)
+	defer func() { _ = xc.Close() }()
+	who(t, xc, 1)
+	xc.mu.Lock()
+	old := xc.clients[addrs[0]]
+	xc.mu.Unlock()
+	_ = old.Close()
+	who(t, xc, 1)
+	xc.mu.Lock()
+	cur := xc.clients[addrs[0]]
+	xc.mu.Unlock()
+	if cur == old {
+		t.Fatal("expect the closed client to be replaced")
+	}
+}
+
+func TestXClientNoServers(t *testing.T) {
+	xc := NewXClient(NewMultiServerDiscovery(nil), RandomSelect, nil)
+	if err := xc.Call(context.Background(), "Node.Who", 0, new(int)); err == nil {
+		t.Fatal("expect an error without servers")
+	}
+	if _, err := NewMultiServerDiscovery([]string{"tcp@a:1"}).Get(SelectMode(-1)); err == nil {
+		t.Fatal("expect an error for an unknown select mode")
+	}
+}
+
+func TestXClientBroadcast(t *testing.T) {
+	xc := NewXClient(NewMultiServerDiscovery(startNodes(t, 3)), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+
+	var reply int
+	if err := xc.Broadcast(context.Background(), "Node.Work", [2]int{-1, 10}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply < 0 || reply > 2 {
+		t.Fatalf("expect the reply of one server, got %d", reply)
+	}
+	if err := xc.Broadcast(context.Background(), "Node.Work", [2]int{-1, 10}, nil); err != nil {
+		t.Fatalf("expect a nil reply to be allowed, got %v", err)
+	}
+
+	// 实例 1 立即失败，其余实例等待 800ms，Broadcast 应返回错误并取消其余调用
+	start := time.Now()
+	err := xc.Broadcast(context.Background(), "Node.Work", [2]int{1, 800}, &reply)
+	if err == nil || !strings.Contains(err.Error(), "node 1 failed") {
+		t.Fatalf("expect the error of node 1, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Fatalf("expect the other calls to be cancelled, took %v", elapsed)
+	}
+}
+
+func TestXClientRetry(t *testing.T) {
+	addrs := append([]string{deadAddr(t)}, startNodes(t, 1)...)
+	xopt := &XClientOption{MaxRetries: 1, Backoff: func(int) time.Duration { return time.Millisecond }}
+	xc := NewXClient(NewMultiServerDiscovery(addrs), RoundRobinSelect, nil, xopt)
+	defer func() { _ = xc.Close() }()
+	// 轮询必然有一半的调用先选中已停止的服务器，重试后都应成功
+	for _, id := range who(t, xc, 4) {
+		if id != 0 {
+			t.Fatalf("expect the healthy server to answer, got %d", id)
+		}
+	}
+
+	noRetry := NewXClient(NewMultiServerDiscovery(addrs[:1]), RoundRobinSelect, nil)
+	defer func() { _ = noRetry.Close() }()
+	if err := noRetry.Call(context.Background(), "Node.Who", 0, new(int)); err == nil {
+		t.Fatal("expect an error without retries")
+	}
+}
+
+func TestXClientNoRetryOnServerError(t *testing.T) {
+	addrs, nodes := startNodesWith(t, 2)
+	xopt := &XClientOption{MaxRetries: 3, Backoff: func(int) time.Duration { return time.Millisecond }}
+	xc := NewXClient(NewMultiServerDiscovery(addrs), RoundRobinSelect, nil, xopt)
+	defer func() { _ = xc.Close() }()
+	if err := xc.Call(context.Background(), "Node.Fail", 0, new(int)); err == nil {
+		t.Fatal("expect the server error")
+	}
+	if n := nodes[0].fails.Load() + nodes[1].fails.Load(); n != 1 {
+		t.Fatalf("expect application errors not to be retried, got %d calls", n)
+	}
+}
+
+func TestXClientRetryRespectsDeadline(t *testing.T) {
+	xopt := &XClientOption{MaxRetries: 5, Backoff: func(int) time.Duration { return time.Second }}
+	xc := NewXClient(NewMultiServerDiscovery([]string{deadAddr(t)}), RoundRobinSelect, nil, xopt)
+	defer func() { _ = xc.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := xc.Call(ctx, "Node.Who", 0, new(int)); err == nil {
+		t.Fatal("expect an error from the dead server")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expect retries to stop at the ctx deadline, took %v", elapsed)
+	}
+}